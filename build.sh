@@ -1,4 +1,5 @@
 #!/bin/bash
 jenny generate
 protoc -I transport/pb transport/pb/shorter.proto --go_out=plugins=grpc:transport/pb
+protoc -I transport/health transport/health/health.proto --go_out=plugins=grpc:transport/health
 go get ./cmd/...
\ No newline at end of file
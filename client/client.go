@@ -0,0 +1,254 @@
+// Package client talks to shorterd over gRPC or HTTP, returning a v1.Shorter
+// either way.
+//
+// Every call gets a deadline, and calls that are safe to repeat are retried
+// with exponential backoff when the server is unavailable or rate limits
+// them. Shorten and ShortenBatch are made safe to repeat by sending them with
+// an idempotency key, a random one unless the context already carries one
+// set with WithIdempotencyKey. Info, List and Update are safe to repeat as
+// they are. Expand counts a click on the link and Delete fails once the link
+// is gone, so they're only retried when the server turned them away without
+// acting on them.
+//
+// Errors are gRPC statuses whichever transport was used, and can be inspected
+// with status.FromError.
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	grpctransport "github.com/go-kit/kit/transport/grpc"
+	kithttp "github.com/go-kit/kit/transport/http"
+	v1 "github.com/jennyservices/shorter/transport/v1"
+	"golang.org/x/net/http2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/balancer/roundrobin"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// authorizationHeader and authorizationMetadata are where credentials
+	// are sent over HTTP and gRPC.
+	authorizationHeader   = "Authorization"
+	authorizationMetadata = "authorization"
+)
+
+// Client is a v1.Shorter backed by a connection to shorterd.
+type Client struct {
+	v1.Shorter
+	close func() error
+}
+
+// Close releases the client's connections.
+func (c *Client) Close() error {
+	return c.close()
+}
+
+// Option configures a Client.
+type Option func(*options)
+
+type options struct {
+	timeout        time.Duration
+	connectTimeout time.Duration
+	retries        int
+	minBackoff     time.Duration
+	maxBackoff     time.Duration
+	tlsConfig      *tls.Config
+	authorization  string
+	dialOpts       []grpc.DialOption
+	httpClient     *http.Client
+}
+
+// WithTimeout sets how long a call, retries included, can take before it
+// fails with codes.DeadlineExceeded. 0 leaves calls to the context's
+// deadline. It's 10s by default.
+func WithTimeout(d time.Duration) Option {
+	return func(o *options) { o.timeout = d }
+}
+
+// WithConnectTimeout sets how long connecting to the server can take, on top
+// of the deadline calls have. 0, the default, leaves it to the calls'.
+func WithConnectTimeout(d time.Duration) Option {
+	return func(o *options) { o.connectTimeout = d }
+}
+
+// WithRetries sets how many times a failed call is retried, 3 by default.
+func WithRetries(n int) Option {
+	return func(o *options) { o.retries = n }
+}
+
+// WithBackoff sets how long the first retry waits, doubling for every retry
+// after it up to max. It's 100ms up to 5s by default. A server asking to be
+// retried later makes a retry wait longer.
+func WithBackoff(min, max time.Duration) Option {
+	return func(o *options) { o.minBackoff, o.maxBackoff = min, max }
+}
+
+// WithTLS connects over TLS configured by cfg instead of in plaintext.
+func WithTLS(cfg *tls.Config) Option {
+	return func(o *options) { o.tlsConfig = cfg }
+}
+
+// WithAuthorization sends credentials, like "Bearer " followed by a JWT, with
+// every call, as the Authorization header over HTTP and authorization
+// metadata over gRPC.
+func WithAuthorization(credentials string) Option {
+	return func(o *options) { o.authorization = credentials }
+}
+
+// WithDialOptions adds opts to the ones NewGRPC and Dial dial with.
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(o *options) { o.dialOpts = append(o.dialOpts, opts...) }
+}
+
+// WithHTTPClient makes NewHTTP send requests with c, which then has to be
+// configured for TLS itself.
+func WithHTTPClient(c *http.Client) Option {
+	return func(o *options) { o.httpClient = c }
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		timeout:    10 * time.Second,
+		retries:    3,
+		minBackoff: 100 * time.Millisecond,
+		maxBackoff: 5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// NewGRPC returns a client for the gRPC server at target. A target without a
+// scheme, like localhost:8081, is resolved with DNS, and calls are balanced
+// round robin across every address it resolves to.
+func NewGRPC(target string, opts ...Option) (*Client, error) {
+	o := newOptions(opts)
+	conn, err := dial(target, o)
+	if err != nil {
+		return nil, err
+	}
+	endpoints := v1.NewShorterGRPCClient(conn, grpctransport.ClientBefore(
+		func(ctx context.Context, md *metadata.MD) context.Context {
+			if key, ok := ctx.Value(idempotencyKeyContextKey).(string); ok {
+				md.Set(idempotencyMetadata, key)
+			}
+			return ctx
+		},
+	))
+	return &Client{Shorter: wrap(endpoints, o), close: conn.Close}, nil
+}
+
+// Dial connects to the gRPC server at target as NewGRPC does, for calls a
+// Client doesn't make, like ShortenStream. Only the options about connecting
+// and WithAuthorization apply.
+func Dial(target string, opts ...Option) (*grpc.ClientConn, error) {
+	return dial(target, newOptions(opts))
+}
+
+func dial(target string, o *options) (*grpc.ClientConn, error) {
+	if !strings.Contains(target, "://") {
+		target = "dns:///" + target
+	}
+	dialOpts := []grpc.DialOption{grpc.WithBalancerName(roundrobin.Name)}
+	if o.connectTimeout > 0 {
+		dialOpts = append(dialOpts, grpc.WithDialer(func(addr string, timeout time.Duration) (net.Conn, error) {
+			if timeout <= 0 || timeout > o.connectTimeout {
+				timeout = o.connectTimeout
+			}
+			return net.DialTimeout("tcp", addr, timeout)
+		}))
+	}
+	if o.tlsConfig != nil {
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(credentials.NewTLS(o.tlsConfig)))
+	} else {
+		dialOpts = append(dialOpts, grpc.WithInsecure())
+	}
+	if o.authorization != "" {
+		dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(authorization(o.authorization)))
+	}
+	return grpc.Dial(target, append(dialOpts, o.dialOpts...)...)
+}
+
+// authorization sends credentials as the authorization metadata of every call
+// on a connection, streams included.
+type authorization string
+
+func (a authorization) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{authorizationMetadata: string(a)}, nil
+}
+
+// RequireTransportSecurity is false as credentials are sent in plaintext over
+// HTTP too, to servers behind a proxy terminating TLS.
+func (authorization) RequireTransportSecurity() bool { return false }
+
+// NewHTTP returns a client for the HTTP API served under baseURL, like
+// https://sho.rt.
+func NewHTTP(baseURL string, opts ...Option) (*Client, error) {
+	o := newOptions(opts)
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if base.Scheme != "http" && base.Scheme != "https" || base.Host == "" {
+		return nil, fmt.Errorf("%q isn't an http or https URL", baseURL)
+	}
+	httpClient, transport := o.httpClient, (*http.Transport)(nil)
+	if httpClient == nil {
+		transport = &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			DialContext:         (&net.Dialer{Timeout: o.connectTimeout, KeepAlive: 30 * time.Second}).DialContext,
+			TLSClientConfig:     o.tlsConfig,
+			TLSHandshakeTimeout: 10 * time.Second,
+			IdleConnTimeout:     90 * time.Second,
+		}
+		if err := http2.ConfigureTransport(transport); err != nil {
+			return nil, err
+		}
+		httpClient = &http.Client{Transport: transport}
+	}
+	endpoints := v1.NewShorterHTTPClient(base,
+		kithttp.SetClient(httpClient),
+		kithttp.ClientBefore(func(ctx context.Context, r *http.Request) context.Context {
+			if key, ok := ctx.Value(idempotencyKeyContextKey).(string); ok {
+				r.Header.Set(idempotencyHeader, key)
+			}
+			if o.authorization != "" {
+				r.Header.Set(authorizationHeader, o.authorization)
+			}
+			return ctx
+		}),
+	)
+	return &Client{Shorter: wrap(endpoints, o), close: func() error {
+		if transport != nil {
+			transport.CloseIdleConnections()
+		}
+		return nil
+	}}, nil
+}
+
+// wrap adds deadlines, idempotency keys and retries to endpoints.
+func wrap(endpoints v1.ShorterEndpoints, o *options) v1.ShorterEndpoints {
+	keyed := endpoint.Chain(deadline(o.timeout), withKey, retry(o, retryIdempotent))
+	idempotent := endpoint.Chain(deadline(o.timeout), retry(o, retryIdempotent))
+	unprocessed := endpoint.Chain(deadline(o.timeout), retry(o, retryUnprocessed))
+	return v1.ShorterEndpoints{
+		ShortenEndpoint:      keyed(endpoints.ShortenEndpoint),
+		ExpandEndpoint:       unprocessed(endpoints.ExpandEndpoint),
+		ShortenBatchEndpoint: keyed(endpoints.ShortenBatchEndpoint),
+		InfoEndpoint:         idempotent(endpoints.InfoEndpoint),
+		ListEndpoint:         idempotent(endpoints.ListEndpoint),
+		UpdateEndpoint:       idempotent(endpoints.UpdateEndpoint),
+		DeleteEndpoint:       unprocessed(endpoints.DeleteEndpoint),
+	}
+}
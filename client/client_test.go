@@ -0,0 +1,462 @@
+package client
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	jennyhttp "github.com/jennyservices/jenny/http"
+	jennyoptions "github.com/jennyservices/jenny/options"
+	"github.com/jennyservices/shorter/shorter"
+	pb "github.com/jennyservices/shorter/transport/pb"
+	v1 "github.com/jennyservices/shorter/transport/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/status"
+)
+
+// serveGRPC serves svc over gRPC on a loopback port until stop is called.
+func serveGRPC(t *testing.T, svc v1.Shorter) (addr string, stop func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	s := grpc.NewServer()
+	pb.RegisterShorterServer(s, v1.NewShorterGRPCServer(svc))
+	go s.Serve(l)
+	return l.Addr().String(), s.Stop
+}
+
+// clients serves svc over gRPC and HTTP, returning a client for each
+// transport. close stops it all.
+func clients(t *testing.T, svc v1.Shorter, opts ...Option) (clients map[string]*Client, close func()) {
+	grpcAddr, stopGRPC := serveGRPC(t, svc)
+	httpServer := httptest.NewServer(v1.NewShorterHTTPServer(svc, jennyoptions.WithErrorEncoder(shorter.EncodeError)))
+
+	grpcClient, err := NewGRPC(grpcAddr, opts...)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	httpClient, err := NewHTTP(httpServer.URL, opts...)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	return map[string]*Client{"grpc": grpcClient, "http": httpClient}, func() {
+		grpcClient.Close()
+		httpClient.Close()
+		stopGRPC()
+		httpServer.Close()
+	}
+}
+
+func TestClient(t *testing.T) {
+	cs, close := clients(t, shorter.New(shorter.NewMemoryStore()))
+	defer close()
+
+	ctx := context.Background()
+	for name, c := range cs {
+		short, err := c.Shorten(ctx, v1.URL{Addr: "https://example.com/" + name, MaxClicks: 10})
+		if err != nil {
+			t.Logf("%s: %v", name, err)
+			t.FailNow()
+		}
+		long, err := c.Expand(ctx, short.Addr)
+		if err != nil {
+			t.Logf("%s: %v", name, err)
+			t.FailNow()
+		}
+		if long.Addr != "https://example.com/"+name || long.MaxClicks != 10 {
+			t.Logf("%s: expected %s to expand to what was shortened, got %+v", name, short.Addr, long)
+			t.Fail()
+		}
+
+		_, err = c.Expand(ctx, "missing")
+		if status.Code(err) != codes.NotFound {
+			t.Logf("%s: expected NotFound, got %v", name, err)
+			t.Fail()
+		}
+
+		_, err = c.Shorten(ctx, v1.URL{Addr: "gopher://example.com"})
+		st, _ := status.FromError(err)
+		var violations []*pb.FieldViolation
+		for _, detail := range st.Details() {
+			if br, ok := detail.(*pb.BadRequest); ok {
+				violations = br.FieldViolations
+			}
+		}
+		if st.Code() != codes.InvalidArgument || len(violations) != 1 || violations[0].Field != "addr" {
+			t.Logf("%s: expected InvalidArgument about addr, got %v with %v", name, err, violations)
+			t.Fail()
+		}
+
+		results, err := c.ShortenBatch(ctx, []v1.URL{{Addr: "https://example.org"}, {Addr: "gopher://example.org"}})
+		if err != nil {
+			t.Logf("%s: %v", name, err)
+			t.FailNow()
+		}
+		if len(results) != 2 || results[0].URL == nil || results[1].Error == nil || results[1].Error.Status != http.StatusBadRequest {
+			t.Logf("%s: expected the second URL of the batch to fail, got %+v", name, results)
+			t.Fail()
+		}
+
+		// Conflict and AliasTaken are both 409s over HTTP
+		keyed := WithIdempotencyKey(ctx, "conflict-"+name)
+		if _, err := c.Shorten(keyed, v1.URL{Addr: "https://example.net"}); err != nil {
+			t.Logf("%s: %v", name, err)
+			t.FailNow()
+		}
+		if _, err := c.Shorten(keyed, v1.URL{Addr: "https://example.net/other"}); status.Code(err) != codes.Aborted {
+			t.Logf("%s: expected Aborted for a reused idempotency key, got %v", name, err)
+			t.Fail()
+		}
+
+		l, err := c.Update(ctx, short.Addr, v1.URL{Redirect: http.StatusPermanentRedirect})
+		if err != nil {
+			t.Logf("%s: %v", name, err)
+			t.FailNow()
+		}
+		if l.Redirect != http.StatusPermanentRedirect || l.MaxClicks != 10 {
+			t.Logf("%s: expected only the redirect to change, got %+v", name, l)
+			t.Fail()
+		}
+		if l, err := c.Info(ctx, short.Addr); err != nil || l.Clicks != 1 {
+			t.Logf("%s: expected %s to have been followed once, got %+v, %v", name, short.Addr, l, err)
+			t.Fail()
+		}
+		page, err := c.List(ctx, "", 1000)
+		if err != nil {
+			t.Logf("%s: %v", name, err)
+			t.FailNow()
+		}
+		listed := false
+		for _, l := range page.Links {
+			listed = listed || l.Code == short.Addr
+		}
+		if !listed {
+			t.Logf("%s: expected %s to be listed, got %+v", name, short.Addr, page)
+			t.Fail()
+		}
+		if _, err := c.Delete(ctx, short.Addr); err != nil {
+			t.Logf("%s: %v", name, err)
+			t.FailNow()
+		}
+		if _, err := c.Info(ctx, short.Addr); status.Code(err) != codes.NotFound {
+			t.Logf("%s: expected NotFound once deleted, got %v", name, err)
+			t.Fail()
+		}
+	}
+}
+
+func TestClientShortURL(t *testing.T) {
+	cs, close := clients(t, shorter.New(shorter.NewMemoryStore(), shorter.WithBaseURL("https://sho.rt")))
+	defer close()
+
+	ctx := context.Background()
+	for name, c := range cs {
+		short, err := c.Shorten(ctx, v1.URL{Addr: "https://example.com/" + name})
+		if err != nil {
+			t.Logf("%s: %v", name, err)
+			t.FailNow()
+		}
+		if long, err := c.Expand(ctx, short.Addr); err != nil || long.Addr != "https://example.com/"+name {
+			t.Logf("%s: expected %s to expand, got %+v, %v", name, short.Addr, long, err)
+			t.Fail()
+		}
+		if _, err := c.Info(ctx, short.Addr); err != nil {
+			t.Logf("%s: %v", name, err)
+			t.Fail()
+		}
+		if _, err := c.Update(ctx, short.Addr, v1.URL{MaxClicks: 5}); err != nil {
+			t.Logf("%s: %v", name, err)
+			t.Fail()
+		}
+		if _, err := c.Delete(ctx, short.Addr); err != nil {
+			t.Logf("%s: %v", name, err)
+			t.Fail()
+		}
+
+		for _, code := range []string{"a/b", "a?b", "50%", "a%2Fb"} {
+			if _, err := c.Expand(ctx, code); status.Code(err) != codes.NotFound {
+				t.Logf("%s: %q: expected NotFound, got %v", name, code, err)
+				t.Fail()
+			}
+		}
+	}
+}
+
+// unavailable is what flakyShorter fails with.
+type unavailable struct{}
+
+func (unavailable) Error() string              { return "try again" }
+func (unavailable) StatusCode() int            { return http.StatusServiceUnavailable }
+func (unavailable) GRPCStatus() *status.Status { return status.New(codes.Unavailable, "try again") }
+
+// flakyShorter fails the first failures calls to each operation, recording
+// the idempotency keys they were made with.
+type flakyShorter struct {
+	v1.Shorter
+	failures int
+
+	mu    sync.Mutex
+	calls map[string]int
+	keys  map[string][]string
+}
+
+func (f *flakyShorter) call(ctx context.Context, op string) error {
+	key := ""
+	if h, ok := ctx.Value(jennyhttp.ContextKeyRequestHeaders).(http.Header); ok {
+		key = h.Get(idempotencyHeader)
+	} else if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get(idempotencyMetadata)) > 0 {
+		key = md.Get(idempotencyMetadata)[0]
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls[op]++
+	f.keys[op] = append(f.keys[op], key)
+	if f.calls[op] <= f.failures {
+		return unavailable{}
+	}
+	return nil
+}
+
+func (f *flakyShorter) Shorten(ctx context.Context, long v1.URL) (*v1.URL, error) {
+	if err := f.call(ctx, "Shorten"); err != nil {
+		return nil, err
+	}
+	return &v1.URL{Addr: "abc"}, nil
+}
+
+func (f *flakyShorter) Expand(ctx context.Context, code string) (*v1.URL, error) {
+	if err := f.call(ctx, "Expand"); err != nil {
+		return nil, err
+	}
+	return &v1.URL{Addr: "https://example.com"}, nil
+}
+
+func (f *flakyShorter) ShortenBatch(ctx context.Context, longs []v1.URL) ([]v1.BatchResult, error) {
+	if err := f.call(ctx, "ShortenBatch"); err != nil {
+		return nil, err
+	}
+	return []v1.BatchResult{{URL: &v1.URL{Addr: "abc"}}}, nil
+}
+
+func TestRetry(t *testing.T) {
+	for _, transport := range []string{"grpc", "http"} {
+		svc := &flakyShorter{failures: 2, calls: map[string]int{}, keys: map[string][]string{}}
+		cs, close := clients(t, svc, WithBackoff(time.Millisecond, 10*time.Millisecond))
+		c := cs[transport]
+
+		if _, err := c.Shorten(context.Background(), v1.URL{Addr: "https://example.com"}); err != nil {
+			t.Logf("%s: expected Shorten to be retried until it worked, got %v", transport, err)
+			t.Fail()
+		}
+		keys := svc.keys["Shorten"]
+		if len(keys) != 3 || keys[0] == "" || keys[0] != keys[1] || keys[1] != keys[2] {
+			t.Logf("%s: expected 3 attempts with the same idempotency key, got %q", transport, keys)
+			t.Fail()
+		}
+
+		ctx := WithIdempotencyKey(context.Background(), "mine")
+		if _, err := c.ShortenBatch(ctx, []v1.URL{{Addr: "https://example.com"}}); err != nil {
+			t.Logf("%s: expected ShortenBatch to be retried until it worked, got %v", transport, err)
+			t.Fail()
+		}
+		if keys := svc.keys["ShortenBatch"]; len(keys) != 3 || keys[2] != "mine" {
+			t.Logf("%s: expected 3 attempts with the key given, got %q", transport, keys)
+			t.Fail()
+		}
+
+		// expanding counts a click, so it isn't retried
+		if _, err := c.Expand(context.Background(), "abc"); status.Code(err) != codes.Unavailable {
+			t.Logf("%s: expected Unavailable, got %v", transport, err)
+			t.Fail()
+		}
+		if n := svc.calls["Expand"]; n != 1 {
+			t.Logf("%s: expected Expand to be called once, got %d", transport, n)
+			t.Fail()
+		}
+		close()
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	svc := &flakyShorter{failures: 10, calls: map[string]int{}, keys: map[string][]string{}}
+	cs, close := clients(t, svc, WithRetries(2), WithBackoff(time.Millisecond, time.Millisecond))
+	defer close()
+
+	if _, err := cs["grpc"].Shorten(context.Background(), v1.URL{Addr: "https://example.com"}); status.Code(err) != codes.Unavailable {
+		t.Logf("expected Unavailable, got %v", err)
+		t.Fail()
+	}
+	if n := svc.calls["Shorten"]; n != 3 {
+		t.Logf("expected 3 attempts, got %d", n)
+		t.Fail()
+	}
+}
+
+// stuckShorter never answers until the call is canceled.
+type stuckShorter struct {
+	v1.Shorter
+}
+
+func (stuckShorter) Shorten(ctx context.Context, long v1.URL) (*v1.URL, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (stuckShorter) Expand(ctx context.Context, code string) (*v1.URL, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (stuckShorter) ShortenBatch(ctx context.Context, longs []v1.URL) ([]v1.BatchResult, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// timedOutShorter runs out of time on the server, whatever the client's
+// deadline.
+type timedOutShorter struct {
+	v1.Shorter
+}
+
+func (timedOutShorter) ShortenBatch(ctx context.Context, longs []v1.URL) ([]v1.BatchResult, error) {
+	return nil, context.DeadlineExceeded
+}
+
+func TestServerDeadline(t *testing.T) {
+	cs, close := clients(t, timedOutShorter{})
+	defer close()
+
+	for name, c := range cs {
+		_, err := c.ShortenBatch(context.Background(), []v1.URL{{Addr: "https://example.com"}})
+		if status.Code(err) != codes.DeadlineExceeded {
+			t.Logf("%s: expected DeadlineExceeded, got %v", name, err)
+			t.Fail()
+		}
+	}
+}
+
+func TestDeadline(t *testing.T) {
+	cs, close := clients(t, stuckShorter{}, WithTimeout(50*time.Millisecond))
+	defer close()
+
+	for name, c := range cs {
+		start := time.Now()
+		_, err := c.Expand(context.Background(), "abc")
+		if status.Code(err) != codes.DeadlineExceeded {
+			t.Logf("%s: expected DeadlineExceeded, got %v", name, err)
+			t.Fail()
+		}
+		if took := time.Since(start); took > 5*time.Second {
+			t.Logf("%s: expected the call to give up after 50ms, took %v", name, took)
+			t.Fail()
+		}
+	}
+}
+
+// authorizationShorter expands every code to the credentials it was called
+// with.
+type authorizationShorter struct {
+	v1.Shorter
+}
+
+func (authorizationShorter) Expand(ctx context.Context, code string) (*v1.URL, error) {
+	credentials := ""
+	if h, ok := ctx.Value(jennyhttp.ContextKeyRequestHeaders).(http.Header); ok {
+		credentials = h.Get(authorizationHeader)
+	} else if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get(authorizationMetadata)) > 0 {
+		credentials = md.Get(authorizationMetadata)[0]
+	}
+	return &v1.URL{Addr: credentials}, nil
+}
+
+func TestAuthorization(t *testing.T) {
+	cs, close := clients(t, authorizationShorter{}, WithAuthorization("Bearer token"))
+	defer close()
+
+	for name, c := range cs {
+		long, err := c.Expand(context.Background(), "abc")
+		if err != nil || long.Addr != "Bearer token" {
+			t.Logf("%s: expected the credentials to be sent, got %v, %v", name, long, err)
+			t.Fail()
+		}
+	}
+}
+
+// staticResolver resolves every target to addrs.
+type staticResolver struct {
+	addrs []resolver.Address
+}
+
+func (r *staticResolver) Build(_ resolver.Target, cc resolver.ClientConn, _ resolver.BuildOption) (resolver.Resolver, error) {
+	cc.NewAddress(r.addrs)
+	return r, nil
+}
+
+func (r *staticResolver) Scheme() string                       { return "static" }
+func (r *staticResolver) ResolveNow(resolver.ResolveNowOption) {}
+func (r *staticResolver) Close()                               {}
+
+// countingShorter counts the calls it answers.
+type countingShorter struct {
+	stuckShorter
+	mu    *sync.Mutex
+	calls *int
+}
+
+func (c countingShorter) Expand(ctx context.Context, code string) (*v1.URL, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	*c.calls++
+	return &v1.URL{Addr: "https://example.com"}, nil
+}
+
+func TestRoundRobin(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		calls [2]int
+		r     = &staticResolver{}
+	)
+	for i := range calls {
+		addr, stop := serveGRPC(t, countingShorter{mu: &mu, calls: &calls[i]})
+		defer stop()
+		r.addrs = append(r.addrs, resolver.Address{Addr: addr})
+	}
+	resolver.Register(r)
+
+	c, err := NewGRPC("static:///shorterd", WithDialOptions(grpc.WithBlock()))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	defer c.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := c.Expand(context.Background(), "abc"); err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+		mu.Lock()
+		both := calls[0] > 0 && calls[1] > 0
+		mu.Unlock()
+		if both {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Logf("expected calls to go to both servers, got %v", calls)
+			t.FailNow()
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	mathrand "math/rand"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/golang/protobuf/ptypes"
+	pb "github.com/jennyservices/shorter/transport/pb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// idempotencyHeader and idempotencyMetadata are where shorter reads an
+	// idempotency key from, over HTTP and gRPC.
+	idempotencyHeader   = "Idempotency-Key"
+	idempotencyMetadata = "idempotency-key"
+)
+
+type contextKey int
+
+const idempotencyKeyContextKey contextKey = iota
+
+// WithIdempotencyKey returns a context that makes Shorten and ShortenBatch
+// calls made with it send key as their idempotency key, so that calling again
+// with the same key returns what the first call minted, even across
+// processes.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyContextKey, key)
+}
+
+// withKey gives calls an idempotency key of their own if they don't come with
+// one, so retrying them is safe. shorterd only remembers keys sent for a link
+// it had already minted for a day, so these don't pile up.
+func withKey(next endpoint.Endpoint) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		if _, ok := ctx.Value(idempotencyKeyContextKey).(string); !ok {
+			key := make([]byte, 16)
+			if _, err := rand.Read(key); err != nil {
+				return nil, err
+			}
+			ctx = WithIdempotencyKey(ctx, hex.EncodeToString(key))
+		}
+		return next(ctx, request)
+	}
+}
+
+// deadline fails calls that take longer than timeout, unless it's 0.
+func deadline(timeout time.Duration) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		if timeout <= 0 {
+			return next
+		}
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			return next(ctx, request)
+		}
+	}
+}
+
+// retryIdempotent reports whether a call that's safe to repeat is worth
+// retrying after failing with err.
+func retryIdempotent(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted:
+		return true
+	}
+	return false
+}
+
+// retryUnprocessed reports whether err says the server turned a call away
+// without acting on it.
+func retryUnprocessed(err error) bool {
+	return status.Code(err) == codes.ResourceExhausted
+}
+
+// retry retries calls failing with an error retryable accepts, as many times
+// as o allows. Retries back off exponentially with jitter, waiting longer if
+// the server asked to be retried later. It gives up early, returning the last
+// error, once waiting would outlast the call's deadline.
+func retry(o *options, retryable func(error) bool) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			backoff := o.minBackoff
+			for attempt := 0; ; attempt++ {
+				resp, err := next(ctx, request)
+				if err == nil || attempt >= o.retries || !retryable(err) {
+					return resp, err
+				}
+
+				wait := backoff/2 + time.Duration(mathrand.Int63n(int64(backoff/2)+1))
+				if after := retryAfter(err); after > wait {
+					wait = after
+				}
+				if d, ok := ctx.Deadline(); ok && time.Until(d) < wait {
+					return nil, err
+				}
+				timer := time.NewTimer(wait)
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					timer.Stop()
+					return nil, err
+				}
+
+				if backoff *= 2; backoff > o.maxBackoff {
+					backoff = o.maxBackoff
+				}
+			}
+		}
+	}
+}
+
+// retryAfter returns how long the server asked to wait before retrying the
+// call that failed with err, or 0 if it didn't say.
+func retryAfter(err error) time.Duration {
+	st, ok := status.FromError(err)
+	if !ok {
+		return 0
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*pb.RetryInfo); ok {
+			if d, err := ptypes.Duration(info.RetryDelay); err == nil {
+				return d
+			}
+		}
+	}
+	return 0
+}
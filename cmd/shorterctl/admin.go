@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jennyservices/shorter/shorter"
+)
+
+// adminStore is what admin needs of the store it opens.
+type adminStore interface {
+	shorter.Store
+	CheckIndex() []shorter.IndexProblem
+	RepairIndex() ([]shorter.IndexProblem, error)
+	LogProblems() []shorter.LogProblem
+	Salvage() ([]shorter.LogProblem, error)
+	Close() error
+}
+
+// openStore opens the store shorterd keeps in dir read-only, failing if
+// shorterd has it open. Windows doesn't lock the data directory, so there it's
+// up to whoever runs admin to stop shorterd first.
+func openStore(dir string) (adminStore, error) {
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, fmt.Errorf("%s isn't a directory", dir)
+	}
+	store, err := shorter.OpenFileStoreReadOnly(dir)
+	if err == shorter.ErrLocked {
+		return nil, fmt.Errorf("%s is locked, stop shorterd before running admin commands on it", dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+func adminCommand() *command {
+	var (
+		dataDir     string
+		dropCorrupt bool
+	)
+	return &command{
+		name:    "admin",
+		args:    "dump | verify | repair | count",
+		summary: "Dump, check, repair or count the links in shorterd's data directory while shorterd is stopped",
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&dataDir, "data-dir", "", "directory shorterd keeps links in, its -data-dir")
+			fs.BoolVar(&dropCorrupt, "drop-corrupt", false, "let repair drop the corrupt records of the log, which verify lists")
+		},
+		noProfile: true,
+		run: func(ctx context.Context, e *env, args []string) error {
+			if err := oneArg(args, "action"); err != nil {
+				return err
+			}
+			action := args[0]
+			switch action {
+			case "dump", "verify", "repair", "count":
+			default:
+				return usageError(fmt.Sprintf("unknown action %q, expected dump, verify, repair or count", action))
+			}
+			if dataDir == "" {
+				return usageError("-data-dir is required")
+			}
+			store, err := openStore(dataDir)
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+
+			logProblems := store.LogProblems()
+			switch action {
+			case "dump", "count":
+				if len(logProblems) > 0 {
+					fmt.Fprintf(e.stderr, "warning: skipped %d problems in the log, shorterctl admin verify lists them\n", len(logProblems))
+				}
+				if action == "count" {
+					return e.printCount(ctx, store)
+				}
+				links, err := store.List(ctx, "", 0)
+				if err != nil {
+					return err
+				}
+				return e.printStoredLinks(links)
+			case "verify":
+				problems := store.CheckIndex()
+				if err := e.printProblems(logProblems, problems); err != nil {
+					return err
+				}
+				if len(logProblems) > 0 || len(problems) > 0 {
+					return fmt.Errorf("found %d log and %d index problems, shorterctl admin repair fixes them", len(logProblems), len(problems))
+				}
+				fmt.Fprintln(e.stderr, "the log replays cleanly and indexes are consistent with the links")
+				return nil
+			default:
+				if n := corrupt(logProblems); n > 0 && !dropCorrupt {
+					if err := e.printProblems(logProblems, nil); err != nil {
+						return err
+					}
+					return fmt.Errorf("found %d corrupt records in the log, repair -drop-corrupt drops them and keeps the records after them", n)
+				}
+				salvaged, err := store.Salvage()
+				if err != nil {
+					return err
+				}
+				fixed, err := store.RepairIndex()
+				if err != nil {
+					return err
+				}
+				if err := e.printProblems(salvaged, fixed); err != nil {
+					return err
+				}
+				fmt.Fprintf(e.stderr, "fixed %d log and %d index problems\n", len(salvaged), len(fixed))
+				return nil
+			}
+		},
+	}
+}
+
+// printStoredLinks prints links as the store has them, owners included.
+func (e *env) printStoredLinks(links []shorter.Link) error {
+	rows := make([][]string, len(links))
+	for i, l := range links {
+		var expiresAt *time.Time
+		if !l.ExpiresAt.IsZero() {
+			expiresAt = &l.ExpiresAt
+		}
+		rows[i] = []string{l.Code, l.URL, dash(l.Owner), redirect(l.Redirect), expires(expiresAt), strconv.Itoa(l.Clicks), count(l.MaxClicks)}
+	}
+	return e.print(links, []string{"CODE", "LONG", "OWNER", "REDIRECT", "EXPIRES", "CLICKS", "MAX CLICKS"}, rows)
+}
+
+// printCount prints how many links store has, how many of them expired and
+// how many owners they have between them.
+func (e *env) printCount(ctx context.Context, store shorter.Store) error {
+	links, err := store.List(ctx, "", 0)
+	if err != nil {
+		return err
+	}
+	var counts struct {
+		Links   int `json:"links"`
+		Expired int `json:"expired"`
+		Owners  int `json:"owners"`
+	}
+	owners := map[string]bool{}
+	now := time.Now()
+	for _, l := range links {
+		counts.Links++
+		if l.Expired(now) {
+			counts.Expired++
+		}
+		if l.Owner != "" && !owners[l.Owner] {
+			owners[l.Owner] = true
+			counts.Owners++
+		}
+	}
+	row := []string{strconv.Itoa(counts.Links), strconv.Itoa(counts.Expired), strconv.Itoa(counts.Owners)}
+	return e.print(counts, []string{"LINKS", "EXPIRED", "OWNERS"}, [][]string{row})
+}
+
+// corrupt returns how many of problems are corrupt records.
+func corrupt(problems []shorter.LogProblem) int {
+	n := 0
+	for _, p := range problems {
+		if p.Problem == shorter.ProblemCorrupt {
+			n++
+		}
+	}
+	return n
+}
+
+// printProblems prints the problems found with the log and the indexes, as a
+// table for each that has any or as one document with both.
+func (e *env) printProblems(log []shorter.LogProblem, index []shorter.IndexProblem) error {
+	if log == nil {
+		log = []shorter.LogProblem{}
+	}
+	if index == nil {
+		index = []shorter.IndexProblem{}
+	}
+	if e.output != "table" {
+		return e.print(struct {
+			Log   []shorter.LogProblem   `json:"log"`
+			Index []shorter.IndexProblem `json:"index"`
+		}{log, index}, nil, nil)
+	}
+
+	if len(log) > 0 {
+		rows := make([][]string, len(log))
+		for i, p := range log {
+			rows[i] = []string{strconv.FormatInt(p.Offset, 10), dash(p.Op), dash(p.Code), p.Problem, dash(p.Detail)}
+		}
+		if err := e.print(nil, []string{"OFFSET", "OP", "CODE", "PROBLEM", "DETAIL"}, rows); err != nil {
+			return err
+		}
+	}
+	if len(index) > 0 {
+		if len(log) > 0 {
+			fmt.Fprintln(e.stdout)
+		}
+		rows := make([][]string, len(index))
+		for i, p := range index {
+			rows[i] = []string{p.Index, dash(p.Owner), p.Entry, dash(p.Code), dash(strings.Join(p.Links, ",")), p.Problem}
+		}
+		return e.print(nil, []string{"INDEX", "OWNER", "ENTRY", "CODE", "LINKS", "PROBLEM"}, rows)
+	}
+	return nil
+}
+
+// dash formats s for tables, a dash for empty.
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jennyservices/shorter/shorter"
+)
+
+func TestAdmin(t *testing.T) {
+	dir, remove := tempDir(t)
+	defer remove()
+	ctx := context.Background()
+
+	store, err := shorter.NewFileStore(dir, 0)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	// deleting b points the long URL back to a
+	store.Put(ctx, shorter.Link{Code: "a", URL: "https://golang.org/"})
+	store.Put(ctx, shorter.Link{Code: "b", URL: "https://golang.org/"})
+	store.Delete(ctx, "b")
+	store.Put(ctx, shorter.Link{Code: "c", URL: "https://blog.golang.org/", Owner: "alice", MaxClicks: 1, Clicks: 1})
+
+	if _, errOut, status := shorterctl("", "admin", "-data-dir", dir, "count"); status != exitError || !strings.Contains(errOut, "stop shorterd") {
+		t.Logf("expected a store in use to be refused, got %d: %s", status, errOut)
+		t.Fail()
+	}
+	store.Close()
+
+	for _, tc := range []struct {
+		args   []string
+		status int
+	}{
+		{[]string{"admin", "count"}, exitUsage},
+		{[]string{"admin", "-data-dir", dir, "fsck"}, exitUsage},
+		{[]string{"admin", "-data-dir", dir + "/missing", "count"}, exitError},
+		{[]string{"admin", "-data-dir", dir, "verify"}, exitOK},
+		{[]string{"admin", "-data-dir", dir, "repair"}, exitOK},
+	} {
+		_, errOut, status := shorterctl("", tc.args...)
+		if status != tc.status {
+			t.Logf("%q: expected exit status %d, got %d: %s", tc.args, tc.status, status, errOut)
+			t.Fail()
+		}
+	}
+
+	out, errOut, status := shorterctl("", "-output", "json", "admin", "-data-dir", dir, "dump")
+	var links []shorter.Link
+	if err := json.Unmarshal([]byte(out), &links); err != nil || status != exitOK || len(links) != 2 || links[1].Owner != "alice" {
+		t.Logf("expected both links to be dumped, got %d: %s%s", status, out, errOut)
+		t.Fail()
+	}
+
+	out, errOut, status = shorterctl("", "-output", "json", "admin", "-data-dir", dir, "count")
+	var counts struct{ Links, Expired, Owners int }
+	if err := json.Unmarshal([]byte(out), &counts); err != nil || status != exitOK || counts.Links != 2 || counts.Expired != 1 || counts.Owners != 1 {
+		t.Logf("expected 2 links, 1 expired and 1 owner, got %d: %s%s", status, out, errOut)
+		t.Fail()
+	}
+}
+
+func TestAdminCorruptLog(t *testing.T) {
+	dir, remove := tempDir(t)
+	defer remove()
+	ctx := context.Background()
+	path := filepath.Join(dir, "links.log")
+
+	store, err := shorter.NewFileStore(dir, 0)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	store.Put(ctx, shorter.Link{Code: "a", URL: "https://golang.org/"})
+	middle, _ := os.Stat(path)
+	store.Put(ctx, shorter.Link{Code: "b", URL: "https://blog.golang.org/"})
+	store.Put(ctx, shorter.Link{Code: "c", URL: "https://go.dev/"})
+	store.Close()
+
+	// flip a byte in the middle of b and leave half a record at the end
+	log, _ := ioutil.ReadFile(path)
+	log[middle.Size()+10] ^= 0xff
+	log = append(log, 0, 0, 0, 0x40, 1, 2)
+	ioutil.WriteFile(path, log, 0644)
+
+	for _, action := range []string{"dump", "count", "verify"} {
+		shorterctl("", "admin", "-data-dir", dir, action)
+		if after, _ := ioutil.ReadFile(path); !bytes.Equal(after, log) {
+			t.Logf("%s: expected the log to be left as it was", action)
+			t.Fail()
+		}
+	}
+
+	out, errOut, status := shorterctl("", "-output", "json", "admin", "-data-dir", dir, "dump")
+	var links []shorter.Link
+	if err := json.Unmarshal([]byte(out), &links); err != nil || status != exitOK || len(links) != 2 || !strings.Contains(errOut, "skipped 3 problems") {
+		t.Logf("expected the links around the corrupt record to be dumped, got %d: %s%s", status, out, errOut)
+		t.Fail()
+	}
+
+	out, errOut, status = shorterctl("", "-output", "json", "admin", "-data-dir", dir, "verify")
+	var problems struct{ Log []shorter.LogProblem }
+	if err := json.Unmarshal([]byte(out), &problems); err != nil || status != exitError || len(problems.Log) != 3 || problems.Log[0].Offset != middle.Size() {
+		t.Logf("expected the corrupt record, what follows it and the torn tail to be reported, got %d: %s%s", status, out, errOut)
+		t.Fail()
+	}
+
+	if _, errOut, status := shorterctl("", "admin", "-data-dir", dir, "repair"); status != exitError || !strings.Contains(errOut, "-drop-corrupt") {
+		t.Logf("expected repair to refuse to drop corrupt records unless asked to, got %d: %s", status, errOut)
+		t.Fail()
+	}
+	for _, args := range [][]string{{"-drop-corrupt", "repair"}, {"verify"}} {
+		if _, errOut, status := shorterctl("", append([]string{"admin", "-data-dir", dir}, args...)...); status != exitOK {
+			t.Logf("%q: expected exit status %d, got %d: %s", args, exitOK, status, errOut)
+			t.Fail()
+		}
+	}
+}
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	v1 "github.com/jennyservices/shorter/transport/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// bulkHeader names the columns of the CSV bulk writes, and of its checkpoint.
+var bulkHeader = []string{"row", "url", "alias", "tag", "short", "error"}
+
+// bulkRow is a URL read by bulk and what became of it.
+type bulkRow struct {
+	// row is the line or CSV record the URL was read from, counting from 1.
+	row             int
+	url, alias, tag string
+
+	short, err string
+	// final is set once the row doesn't need shortening again, whether it
+	// was or failed for good.
+	final bool
+}
+
+func (r *bulkRow) record() []string {
+	return []string{strconv.Itoa(r.row), r.url, r.alias, r.tag, r.short, r.err}
+}
+
+func bulkCommand() *command {
+	var (
+		format      string
+		out         string
+		checkpoint  string
+		concurrency int
+		rate        float64
+		s           settings
+	)
+	return &command{
+		name:    "bulk",
+		args:    "[FILE]",
+		summary: "Shorten every URL in a file, or stdin, writing a CSV of the short links",
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&format, "format", "", "input format: lines, a URL per line, or csv, with url, alias and tag columns; csv for .csv files and lines otherwise by default")
+			fs.StringVar(&out, "out", "", "file to write the results to instead of stdout")
+			fs.StringVar(&checkpoint, "checkpoint", "", "file recording the URLs done, so running again with it resumes where an interrupted run left off")
+			fs.IntVar(&concurrency, "concurrency", 8, "how many URLs to shorten at once")
+			fs.Float64Var(&rate, "rate", 0, "how many URLs to shorten per second at most, no limit when 0")
+			s.register(fs)
+		},
+		run: func(ctx context.Context, e *env, args []string) error {
+			if len(args) > 1 {
+				return usageError("expected a single file to read, or none for stdin")
+			}
+			if concurrency < 1 || rate < 0 {
+				return usageError("-concurrency must be at least 1 and -rate can't be negative")
+			}
+			in, name := e.stdin, ""
+			if len(args) == 1 && args[0] != "-" {
+				f, err := os.Open(args[0])
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				in, name = f, args[0]
+			}
+			if format == "" {
+				format = "lines"
+				if strings.EqualFold(filepath.Ext(name), ".csv") {
+					format = "csv"
+				}
+			}
+			var (
+				rows []*bulkRow
+				err  error
+			)
+			switch format {
+			case "lines":
+				rows, err = readLines(in)
+			case "csv":
+				rows, err = readCSV(in)
+			default:
+				return usageError(fmt.Sprintf("-format must be lines or csv, not %q", format))
+			}
+			if err != nil {
+				return err
+			}
+
+			done := 0
+			if checkpoint != "" {
+				if done, err = resume(checkpoint, rows); err != nil {
+					return err
+				}
+			}
+			w := e.stdout
+			if out != "" {
+				f, err := os.Create(out)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				w = f
+			}
+			c, err := e.dial()
+			if err != nil {
+				return err
+			}
+
+			b := &bulk{
+				shorter:     c,
+				template:    s.url(""),
+				concurrency: concurrency,
+				rate:        rate,
+			}
+			if checkpoint != "" {
+				if b.checkpoint, err = openCheckpoint(checkpoint); err != nil {
+					return err
+				}
+				defer b.checkpoint.Close()
+			}
+			stop := make(chan os.Signal, 1)
+			signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+			defer signal.Stop(stop)
+			if err := b.run(ctx, rows, csv.NewWriter(w), stop); err != nil {
+				return err
+			}
+
+			shortened, failed, left := 0, 0, 0
+			for _, r := range rows {
+				switch {
+				case r.short != "":
+					shortened++
+				case r.err != "":
+					failed++
+				default:
+					left++
+				}
+			}
+			fmt.Fprintf(e.stderr, "%d URLs, %d shortened, %d failed, %d left", len(rows), shortened, failed, left)
+			if done > 0 {
+				fmt.Fprintf(e.stderr, ", %d of them by an earlier run according to %s", done, checkpoint)
+			}
+			fmt.Fprintln(e.stderr)
+			if failed > 0 || left > 0 {
+				return fmt.Errorf("%d URLs weren't shortened", failed+left)
+			}
+			return nil
+		},
+	}
+}
+
+// readLines reads a URL from every non-blank line of r.
+func readLines(r io.Reader) ([]*bulkRow, error) {
+	var rows []*bulkRow
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		if url := strings.TrimSpace(scanner.Text()); url != "" {
+			rows = append(rows, &bulkRow{row: n, url: url})
+		}
+	}
+	return rows, scanner.Err()
+}
+
+// readCSV reads a URL from every record of r, along with the alias to give
+// it and a tag copied to the results as is. A header naming url, alias and
+// tag columns, in any order, says where they are, otherwise they're the
+// first three.
+func readCSV(r io.Reader) ([]*bulkRow, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	columns := map[string]int{"url": 0, "alias": 1, "tag": 2}
+	var rows []*bulkRow
+	for n := 1; ; n++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if n == 1 && isHeader(record) {
+			columns = map[string]int{"url": -1, "alias": -1, "tag": -1}
+			for i, name := range record {
+				name = strings.ToLower(strings.TrimSpace(name))
+				if _, ok := columns[name]; ok {
+					columns[name] = i
+				}
+			}
+			continue
+		}
+		field := func(name string) string {
+			if i := columns[name]; i >= 0 && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		if url := field("url"); url != "" {
+			rows = append(rows, &bulkRow{row: n, url: url, alias: field("alias"), tag: field("tag")})
+		}
+	}
+}
+
+func isHeader(record []string) bool {
+	for _, name := range record {
+		if strings.EqualFold(strings.TrimSpace(name), "url") {
+			return true
+		}
+	}
+	return false
+}
+
+// resume marks the rows the checkpoint at path records as done, returning
+// how many there were. A checkpoint that doesn't exist yet records none.
+func resume(path string, rows []*bulkRow) (int, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	byRow := make(map[int]*bulkRow, len(rows))
+	for _, r := range rows {
+		byRow[r.row] = r
+	}
+	cr := csv.NewReader(f)
+	cr.FieldsPerRecord = len(bulkHeader)
+	done := 0
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return done, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("reading checkpoint %s: %v", path, err)
+		}
+		if record[0] == bulkHeader[0] {
+			continue
+		}
+		n, err := strconv.Atoi(record[0])
+		r, ok := byRow[n]
+		if err != nil || !ok || r.url != record[1] {
+			return 0, fmt.Errorf("checkpoint %s doesn't match the input at row %s, was it made for another file?", path, record[0])
+		}
+		if !r.final {
+			done++
+		}
+		r.short, r.err, r.final = record[4], record[5], true
+	}
+}
+
+// openCheckpoint opens the checkpoint at path to append rows to, writing the
+// header first if it's new.
+func openCheckpoint(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if fi, err := f.Stat(); err != nil || fi.Size() > 0 {
+		return f, err
+	}
+	cw := csv.NewWriter(f)
+	cw.Write(bulkHeader)
+	cw.Flush()
+	return f, cw.Error()
+}
+
+// bulk shortens rows concurrently, at a limited rate.
+type bulk struct {
+	shorter     v1.Shorter
+	template    v1.URL
+	concurrency int
+	rate        float64
+	checkpoint  *os.File
+}
+
+// run shortens every row that isn't final yet and writes every row to w, in
+// the order they were read, once it's known what became of it. Receiving
+// from stop stops it from starting on more rows, leaving them out of w.
+func (b *bulk) run(ctx context.Context, rows []*bulkRow, w *csv.Writer, stop <-chan os.Signal) error {
+	var tick <-chan time.Time
+	if b.rate > 0 {
+		t := time.NewTicker(time.Duration(float64(time.Second) / b.rate))
+		defer t.Stop()
+		tick = t.C
+	}
+
+	// rows already final are left alone, so reading this is safe while the
+	// others are being shortened
+	skip := make([]bool, len(rows))
+	for i, r := range rows {
+		skip[i] = r.final
+	}
+
+	todo := make(chan int)
+	finished := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < b.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range todo {
+				b.shorten(ctx, rows[i])
+				finished <- i
+			}
+		}()
+	}
+	go func() {
+		defer close(todo)
+		for i := range rows {
+			if skip[i] {
+				continue
+			}
+			if tick != nil {
+				select {
+				case <-tick:
+				case <-stop:
+					return
+				}
+			}
+			select {
+			case todo <- i:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+
+	var cw *csv.Writer
+	if b.checkpoint != nil {
+		cw = csv.NewWriter(b.checkpoint)
+	}
+	ready := make([]bool, len(rows))
+	next := 0
+	flush := func(all bool) {
+		for ; next < len(rows) && (ready[next] || skip[next] || all); next++ {
+			if ready[next] || skip[next] {
+				w.Write(rows[next].record())
+			}
+		}
+		w.Flush()
+	}
+	w.Write(bulkHeader)
+	flush(false)
+	for i := range finished {
+		ready[i] = true
+		if cw != nil && rows[i].final {
+			cw.Write(rows[i].record())
+			cw.Flush()
+		}
+		flush(false)
+	}
+	flush(true)
+	if cw != nil && cw.Error() != nil {
+		return cw.Error()
+	}
+	return w.Error()
+}
+
+// shorten shortens r, marking it final unless it failed in a way that might
+// not happen again.
+func (b *bulk) shorten(ctx context.Context, r *bulkRow) {
+	long := b.template
+	long.Addr, long.Alias = r.url, r.alias
+	short, err := b.shorter.Shorten(ctx, long)
+	if err == nil {
+		r.short, r.final = short.Addr, true
+		return
+	}
+	st, _ := status.FromError(err)
+	r.err = st.Message()
+	switch st.Code() {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Canceled:
+	default:
+		r.final = true
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "github.com/jennyservices/shorter/transport/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func tempDir(t *testing.T) (dir string, remove func()) {
+	dir, err := ioutil.TempDir("", "shorterctl")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func readResults(t *testing.T, s string) [][]string {
+	records, err := csv.NewReader(strings.NewReader(s)).ReadAll()
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	return records
+}
+
+func TestBulkCSV(t *testing.T) {
+	servers, stop := serve(t)
+	defer stop()
+	dir, remove := tempDir(t)
+	defer remove()
+
+	in := filepath.Join(dir, "urls.csv")
+	ioutil.WriteFile(in, []byte("tag,url,alias\nhome,https://golang.org,golang\n,,\nbad,gopher://example.com,\nblog,https://blog.golang.org,\n"), 0644)
+	out, errOut, code := shorterctl("", append(servers["http"], "bulk", "-concurrency", "2", in)...)
+	if code != exitError {
+		t.Logf("expected the bad URL to fail the run, got %d: %s", code, errOut)
+		t.Fail()
+	}
+
+	results := readResults(t, out)
+	if len(results) != 4 || strings.Join(results[0], ",") != strings.Join(bulkHeader, ",") {
+		t.Logf("expected a header and 3 results, got %q", results)
+		t.FailNow()
+	}
+	if r := results[1]; r[0] != "2" || r[1] != "https://golang.org" || r[2] != "golang" || r[3] != "home" || r[4] != "golang" || r[5] != "" {
+		t.Logf("expected the first row to get its alias, got %q", r)
+		t.Fail()
+	}
+	if r := results[2]; r[0] != "4" || r[4] != "" || r[5] == "" {
+		t.Logf("expected the bad URL to fail, got %q", r)
+		t.Fail()
+	}
+	if r := results[3]; r[0] != "5" || r[3] != "blog" || r[4] == "" {
+		t.Logf("expected the last row to be shortened, got %q", r)
+		t.Fail()
+	}
+}
+
+func TestBulkResume(t *testing.T) {
+	servers, stop := serve(t)
+	defer stop()
+	dir, remove := tempDir(t)
+	defer remove()
+
+	checkpoint := filepath.Join(dir, "checkpoint")
+	ioutil.WriteFile(checkpoint, []byte("row,url,alias,tag,short,error\n1,https://golang.org,,,made-earlier,\n"), 0644)
+	input := "https://golang.org\n\nhttps://blog.golang.org\n"
+	out, errOut, code := shorterctl(input, append(servers["grpc"], "bulk", "-checkpoint", checkpoint)...)
+	if code != exitOK {
+		t.Logf("expected the rest to be shortened, got %d: %s", code, errOut)
+		t.FailNow()
+	}
+	results := readResults(t, out)
+	if len(results) != 3 || results[1][4] != "made-earlier" || results[2][0] != "3" || results[2][4] == "" {
+		t.Logf("expected the first URL to come from the checkpoint and the second to be shortened, got %q", results)
+		t.Fail()
+	}
+
+	saved, _ := ioutil.ReadFile(checkpoint)
+	if records := readResults(t, string(saved)); len(records) != 3 {
+		t.Logf("expected the checkpoint to record both URLs, got %q", records)
+		t.Fail()
+	}
+
+	_, errOut, code = shorterctl("https://example.com\n", append(servers["grpc"], "bulk", "-checkpoint", checkpoint)...)
+	if code != exitError || !strings.Contains(errOut, "doesn't match") {
+		t.Logf("expected a checkpoint made for other URLs to be refused, got %d: %s", code, errOut)
+		t.Fail()
+	}
+}
+
+// unavailableShorter fails to shorten the URLs in down with Unavailable.
+type unavailableShorter struct {
+	v1.Shorter
+	down map[string]bool
+}
+
+func (s unavailableShorter) Shorten(ctx context.Context, long v1.URL) (*v1.URL, error) {
+	if s.down[long.Addr] {
+		return nil, status.Error(codes.Unavailable, "try again")
+	}
+	return &v1.URL{Addr: "short"}, nil
+}
+
+func TestBulkRetriesUnavailable(t *testing.T) {
+	dir, remove := tempDir(t)
+	defer remove()
+	checkpoint := filepath.Join(dir, "checkpoint")
+
+	rows := []*bulkRow{{row: 1, url: "https://a.example"}, {row: 2, url: "https://b.example"}, {row: 3, url: "https://c.example"}}
+	f, err := openCheckpoint(checkpoint)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	b := &bulk{
+		shorter:     unavailableShorter{down: map[string]bool{"https://b.example": true}},
+		concurrency: 1,
+		rate:        50,
+		checkpoint:  f,
+	}
+	var out strings.Builder
+	start := time.Now()
+	if err := b.run(context.Background(), rows, csv.NewWriter(&out), nil); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	f.Close()
+	if took := time.Since(start); took < 50*time.Millisecond {
+		t.Logf("expected 3 URLs at 50 a second to take at least 50ms, took %v", took)
+		t.Fail()
+	}
+	if results := readResults(t, out.String()); len(results) != 4 || results[2][5] != "try again" {
+		t.Logf("expected every URL in the results, got %q", results)
+		t.Fail()
+	}
+
+	// the unavailable URL is shortened again on resume, the others aren't
+	for _, r := range rows {
+		r.short, r.err, r.final = "", "", false
+	}
+	done, err := resume(checkpoint, rows)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if done != 2 || rows[1].final {
+		t.Logf("expected only the URLs that worked to be done, got %d", done)
+		t.Fail()
+	}
+}
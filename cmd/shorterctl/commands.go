@@ -0,0 +1,468 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/jennyservices/shorter/client"
+	pb "github.com/jennyservices/shorter/transport/pb"
+	v1 "github.com/jennyservices/shorter/transport/v1"
+)
+
+// command is a shorterctl subcommand.
+type command struct {
+	name    string
+	args    string
+	summary string
+	// flags registers the command's own flags on fs.
+	flags func(fs *flag.FlagSet)
+	// noProfile leaves the global flags as they were given, rather than
+	// filling them in from a profile.
+	noProfile bool
+	run       func(ctx context.Context, e *env, args []string) error
+}
+
+// commands returns every command, in the order usage lists them. Their flags
+// are bound to variables of their own, so it returns new ones every call.
+func commands() []*command {
+	return []*command{
+		shortenCommand(),
+		bulkCommand(),
+		expandCommand(),
+		infoCommand(),
+		listCommand(),
+		updateCommand(),
+		deleteCommand(),
+		loginCommand(),
+		profileCommand(),
+		adminCommand(),
+		completionCommand(),
+	}
+}
+
+func lookup(name string) *command {
+	for _, c := range commands() {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// flagSet returns the flags c parses, its own and the global ones.
+func (c *command) flagSet(g *globals) *flag.FlagSet {
+	fs := flag.NewFlagSet("shorterctl "+c.name, flag.ContinueOnError)
+	c.flags(fs)
+	g.register(fs)
+	return fs
+}
+
+func (c *command) usage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: shorterctl %s [flags] %s\n\n%s.\n", c.name, c.args, c.summary)
+	own := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	c.flags(own)
+	n := 0
+	own.VisitAll(func(*flag.Flag) { n++ })
+	if n > 0 {
+		fmt.Fprint(w, "\nFlags:\n")
+		own.SetOutput(w)
+		own.PrintDefaults()
+	}
+	fmt.Fprint(w, "\nThe global flags listed by shorterctl help are accepted too.\n")
+}
+
+// oneArg checks the command was given exactly one argument, named what.
+func oneArg(args []string, what string) error {
+	if len(args) != 1 {
+		return usageError(fmt.Sprintf("expected a single %s, got %d arguments", what, len(args)))
+	}
+	return nil
+}
+
+// settings are the flags shorten and update set a link's settings with.
+type settings struct {
+	redirect  optionalInt
+	expires   expiry
+	maxClicks optionalInt
+}
+
+func (s *settings) register(fs *flag.FlagSet) {
+	fs.Var(&s.redirect, "redirect", "HTTP `status` to redirect with: 301, 302, 307 or 308, or 0 for the server default")
+	fs.Var(&s.expires, "expires", "when the link stops working, as a `time` like 2006-01-02T15:04:05Z, a duration from now like 72h or never")
+	fs.Var(&s.maxClicks, "max-clicks", "the `number` of times the link can be followed, 0 for no limit")
+}
+
+func (s *settings) url(addr string) v1.URL {
+	return v1.URL{Addr: addr, Redirect: s.redirect.n, ExpiresAt: s.expires.t, MaxClicks: s.maxClicks.n}
+}
+
+// given reports whether any of the settings was set.
+func (s *settings) given() bool {
+	return s.redirect.set || s.expires.t != nil || s.expires.never || s.maxClicks.set
+}
+
+// clear returns the settings that were set to their default, which update
+// has to ask for explicitly.
+func (s *settings) clear() []string {
+	var fields []string
+	if s.redirect.set && s.redirect.n == 0 {
+		fields = append(fields, "redirect")
+	}
+	if s.expires.never {
+		fields = append(fields, "expires_at")
+	}
+	if s.maxClicks.set && s.maxClicks.n == 0 {
+		fields = append(fields, "max_clicks")
+	}
+	return fields
+}
+
+// optionalInt is a flag.Value for an int that remembers whether it was set,
+// so that setting it to 0 can be told from leaving it out.
+type optionalInt struct {
+	n   int
+	set bool
+}
+
+func (o *optionalInt) Set(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("%q isn't a number", s)
+	}
+	o.n, o.set = n, true
+	return nil
+}
+
+func (o *optionalInt) String() string {
+	if o == nil {
+		return "0"
+	}
+	return strconv.Itoa(o.n)
+}
+
+// expiry is a flag.Value for when a link expires.
+type expiry struct {
+	t     *time.Time
+	never bool
+}
+
+func (e *expiry) Set(s string) error {
+	if s == "never" {
+		e.t, e.never = nil, true
+		return nil
+	}
+	e.never = false
+	if d, err := time.ParseDuration(s); err == nil {
+		t := time.Now().Add(d)
+		e.t = &t
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return fmt.Errorf("%q is neither a time nor a duration", s)
+	}
+	e.t = &t
+	return nil
+}
+
+func (e *expiry) String() string {
+	if e != nil && e.never {
+		return "never"
+	}
+	if e == nil || e.t == nil {
+		return ""
+	}
+	return e.t.Format(time.RFC3339)
+}
+
+func shortenCommand() *command {
+	var (
+		alias  string
+		stream bool
+		s      settings
+	)
+	return &command{
+		name:    "shorten",
+		args:    "URL",
+		summary: "Shorten a URL",
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&alias, "alias", "", "code to shorten the URL to instead of a generated one")
+			fs.BoolVar(&stream, "stream", false, "shorten every URL read from stdin, one per line, over a single stream, writing the long and short URL of each separated by a tab")
+			s.register(fs)
+		},
+		run: func(ctx context.Context, e *env, args []string) error {
+			if stream {
+				if len(args) > 0 || alias != "" {
+					return usageError("-stream reads URLs from stdin and can't give them an alias")
+				}
+				return shortenStdin(ctx, e, s.url(""))
+			}
+			if err := oneArg(args, "URL"); err != nil {
+				return err
+			}
+			c, err := e.dial()
+			if err != nil {
+				return err
+			}
+			long := s.url(args[0])
+			long.Alias = alias
+			short, err := c.Shorten(ctx, long)
+			if err != nil {
+				return err
+			}
+			return e.print(short,
+				[]string{"SHORT", "LONG", "REDIRECT", "EXPIRES", "MAX CLICKS"},
+				[][]string{{short.Addr, long.Addr, redirect(short.Redirect), expires(short.ExpiresAt), count(short.MaxClicks)}},
+			)
+		},
+	}
+}
+
+// shortenStdin shortens the URLs on stdin with ShortenStream, giving each
+// the settings of template.
+func shortenStdin(ctx context.Context, e *env, template v1.URL) error {
+	if e.transport != "grpc" {
+		return usageError("-stream needs -transport grpc")
+	}
+	cfg, err := e.tls.config()
+	if err != nil {
+		return err
+	}
+	conn, err := client.Dial(e.grpcTarget(),
+		client.WithConnectTimeout(e.connectTimeout),
+		client.WithTLS(cfg),
+		client.WithAuthorization(e.credentials),
+	)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	u := &pb.URL{Redirect: int32(template.Redirect), MaxClicks: int64(template.MaxClicks)}
+	if template.ExpiresAt != nil {
+		if u.ExpiresAt, err = ptypes.TimestampProto(*template.ExpiresAt); err != nil {
+			return err
+		}
+	}
+	failed, err := shortenStream(ctx, pb.NewShorterClient(conn), u, e.stdin, e.stdout)
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d URLs couldn't be shortened", failed)
+	}
+	return nil
+}
+
+func expandCommand() *command {
+	return &command{
+		name:    "expand",
+		args:    "CODE",
+		summary: "Print the URL behind a code, counting a click on it",
+		flags:   func(*flag.FlagSet) {},
+		run: func(ctx context.Context, e *env, args []string) error {
+			if err := oneArg(args, "code"); err != nil {
+				return err
+			}
+			c, err := e.dial()
+			if err != nil {
+				return err
+			}
+			long, err := c.Expand(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			return e.print(long,
+				[]string{"LONG", "REDIRECT", "EXPIRES", "MAX CLICKS"},
+				[][]string{{long.Addr, redirect(long.Redirect), expires(long.ExpiresAt), count(long.MaxClicks)}},
+			)
+		},
+	}
+}
+
+func infoCommand() *command {
+	return &command{
+		name:    "info",
+		args:    "CODE",
+		summary: "Describe the link behind a code without counting a click",
+		flags:   func(*flag.FlagSet) {},
+		run: func(ctx context.Context, e *env, args []string) error {
+			if err := oneArg(args, "code"); err != nil {
+				return err
+			}
+			c, err := e.dial()
+			if err != nil {
+				return err
+			}
+			l, err := c.Info(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			return e.printLinks(l, *l)
+		},
+	}
+}
+
+func listCommand() *command {
+	var (
+		after string
+		limit int
+		all   bool
+	)
+	return &command{
+		name:    "list",
+		args:    "",
+		summary: "List your links ordered by code",
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&after, "after", "", "list the links after this code")
+			fs.IntVar(&limit, "limit", 0, "how many links to list, the server's default when 0")
+			fs.BoolVar(&all, "all", false, "list every link, a page of -limit links at a time")
+		},
+		run: func(ctx context.Context, e *env, args []string) error {
+			if len(args) > 0 {
+				return usageError("list takes no arguments")
+			}
+			c, err := e.dial()
+			if err != nil {
+				return err
+			}
+			page, err := c.List(ctx, after, limit)
+			if err != nil {
+				return err
+			}
+			for all && page.Next != "" {
+				next, err := c.List(ctx, page.Next, limit)
+				if err != nil {
+					return err
+				}
+				page.Links = append(page.Links, next.Links...)
+				page.Next = next.Next
+			}
+			if err := e.printLinks(page, page.Links...); err != nil {
+				return err
+			}
+			if page.Next != "" && e.output == "table" {
+				fmt.Fprintf(e.stderr, "more links after %s, list them with -after %s or every one with -all\n", page.Next, page.Next)
+			}
+			return nil
+		},
+	}
+}
+
+func updateCommand() *command {
+	var (
+		addr string
+		s    settings
+	)
+	return &command{
+		name:    "update",
+		args:    "CODE",
+		summary: "Change the settings of a link, leaving those not given as they are",
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&addr, "addr", "", "URL the link points to")
+			s.register(fs)
+		},
+		run: func(ctx context.Context, e *env, args []string) error {
+			if err := oneArg(args, "code"); err != nil {
+				return err
+			}
+			if addr == "" && !s.given() {
+				return usageError("nothing to update, set at least one flag")
+			}
+			changes := s.url(addr)
+			changes.Clear = s.clear()
+			c, err := e.dial()
+			if err != nil {
+				return err
+			}
+			l, err := c.Update(ctx, args[0], changes)
+			if err != nil {
+				return err
+			}
+			return e.printLinks(l, *l)
+		},
+	}
+}
+
+func deleteCommand() *command {
+	return &command{
+		name:    "delete",
+		args:    "CODE",
+		summary: "Delete a link",
+		flags:   func(*flag.FlagSet) {},
+		run: func(ctx context.Context, e *env, args []string) error {
+			if err := oneArg(args, "code"); err != nil {
+				return err
+			}
+			c, err := e.dial()
+			if err != nil {
+				return err
+			}
+			l, err := c.Delete(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			return e.printLinks(l, *l)
+		},
+	}
+}
+
+func completionCommand() *command {
+	return &command{
+		name:    "completion",
+		args:    "bash|zsh",
+		summary: "Print a script completing shorterctl's commands and flags in bash or zsh",
+		flags:   func(*flag.FlagSet) {},
+		run: func(_ context.Context, e *env, args []string) error {
+			if err := oneArg(args, "shell"); err != nil {
+				return err
+			}
+			switch args[0] {
+			case "bash":
+				return bashCompletion(e.stdout)
+			case "zsh":
+				return zshCompletion(e.stdout)
+			}
+			return usageError(fmt.Sprintf("can't complete for %q, only bash and zsh", args[0]))
+		},
+	}
+}
+
+// printLinks prints v, made of links, with a row per link in tables.
+func (e *env) printLinks(v interface{}, links ...v1.Link) error {
+	rows := make([][]string, len(links))
+	for i, l := range links {
+		rows[i] = []string{l.Code, l.Addr, redirect(l.Redirect), expires(l.ExpiresAt), strconv.Itoa(l.Clicks), count(l.MaxClicks)}
+	}
+	return e.print(v, []string{"CODE", "LONG", "REDIRECT", "EXPIRES", "CLICKS", "MAX CLICKS"}, rows)
+}
+
+// redirect formats a link's redirect status for tables, where a dash stands
+// for the server's default.
+func redirect(status int) string {
+	if status == 0 {
+		return "-"
+	}
+	return strconv.Itoa(status)
+}
+
+// expires formats when a link expires for tables, a dash for never.
+func expires(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Local().Format(time.RFC3339)
+}
+
+// count formats a link's click limit for tables, a dash for none.
+func count(n int) string {
+	if n == 0 {
+		return "-"
+	}
+	return strconv.Itoa(n)
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+var (
+	// fileFlags are the flags completed with file names.
+	fileFlags = map[string]bool{"tls-cert": true, "tls-key": true, "server-ca": true, "out": true, "checkpoint": true, "config": true, "data-dir": true}
+
+	// flagValues are the flags completed with a fixed set of values.
+	flagValues = map[string][]string{
+		"output":    {"table", "json", "yaml"},
+		"format":    {"lines", "csv"},
+		"transport": {"grpc", "http"},
+	}
+)
+
+// completionFlags returns the flags of fs split into the ones taking a value
+// and the boolean ones, each prefixed with a dash.
+func completionFlags(fs *flag.FlagSet) (valued, boolean []string) {
+	fs.VisitAll(func(f *flag.Flag) {
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+			boolean = append(boolean, "-"+f.Name)
+		} else {
+			valued = append(valued, "-"+f.Name)
+		}
+	})
+	return valued, boolean
+}
+
+func globalFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("shorterctl", flag.ContinueOnError)
+	(&globals{}).register(fs)
+	return fs
+}
+
+// bashCompletion writes a bash script completing shorterctl, meant to be
+// sourced, as in source <(shorterctl completion bash).
+func bashCompletion(w io.Writer) error {
+	var names []string
+	for _, c := range commands() {
+		names = append(names, c.name)
+	}
+	valued, boolean := completionFlags(globalFlagSet())
+	var globalValued []string
+	for _, f := range valued {
+		globalValued = append(globalValued, f, "-"+f)
+	}
+	// after a flag taking a value that isn't one of flagValues, bash falls
+	// back to completing file names
+	var freeform []string
+	seen := map[string]bool{}
+	for _, c := range commands() {
+		valued, _ := completionFlags(c.flagSet(&globals{}))
+		for _, f := range valued {
+			if _, ok := flagValues[f[1:]]; !ok && !seen[f] {
+				seen[f] = true
+				freeform = append(freeform, f, "-"+f)
+			}
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(`# bash completion for shorterctl, load with: source <(shorterctl completion bash)
+_shorterctl() {
+	local cur=${COMP_WORDS[COMP_CWORD]} prev=${COMP_WORDS[COMP_CWORD-1]} cmd= i
+	for ((i = 1; i < COMP_CWORD; i++)); do
+		case ${COMP_WORDS[i]} in
+		` + strings.Join(globalValued, "|") + `) ((i++)) ;;
+		-*) ;;
+		*) cmd=${COMP_WORDS[i]}; break ;;
+		esac
+	done
+	case $prev in
+`)
+	for _, name := range sortedKeys(flagValues) {
+		fmt.Fprintf(&b, "\t-%s|--%s) COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n", name, name, strings.Join(flagValues[name], " "))
+	}
+	fmt.Fprintf(&b, "\t%s) return ;;\n\tesac\n\tcase $cmd in\n", strings.Join(freeform, "|"))
+	fmt.Fprintf(&b, "\t\"\") COMPREPLY=($(compgen -W %q -- \"$cur\")) ;;\n", strings.Join(append(append(names, valued...), boolean...), " "))
+	for _, c := range commands() {
+		valued, boolean := completionFlags(c.flagSet(&globals{}))
+		words := append(valued, boolean...)
+		switch c.name {
+		case "completion":
+			words = append(words, "bash", "zsh")
+		case "profile":
+			words = append(words, "list", "use", "delete")
+		case "admin":
+			words = append(words, "dump", "verify", "repair", "count")
+		}
+		fmt.Fprintf(&b, "\t%s) COMPREPLY=($(compgen -W %q -- \"$cur\")) ;;\n", c.name, strings.Join(words, " "))
+	}
+	b.WriteString("\tesac\n}\ncomplete -o default -F _shorterctl shorterctl\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// zshCompletion writes a zsh completion function for shorterctl, meant to be
+// saved as _shorterctl somewhere in $fpath.
+func zshCompletion(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("#compdef shorterctl\n\n_shorterctl() {\n\tlocal -a commands\n\tcommands=(\n")
+	for _, c := range commands() {
+		fmt.Fprintf(&b, "\t\t%s\n", zshQuote(c.name+":"+c.summary))
+	}
+	b.WriteString("\t)\n\t_arguments -C \\\n")
+	writeZshFlags(&b, globalFlagSet(), "\t\t")
+	b.WriteString("\t\t'1:command:->command' \\\n\t\t'*::argument:->argument'\n")
+	b.WriteString("\tcase $state in\n\tcommand) _describe command commands ;;\n\targument)\n\t\tcase $words[1] in\n")
+	for _, c := range commands() {
+		fmt.Fprintf(&b, "\t\t%s) _arguments \\\n", c.name)
+		writeZshFlags(&b, c.flagSet(&globals{}), "\t\t\t")
+		arg := "'*:" + strings.ToLower(c.args) + ":'"
+		switch c.name {
+		case "completion":
+			arg = "'1:shell:(bash zsh)'"
+		case "bulk":
+			arg = "'1:file:_files'"
+		case "list":
+			arg = "'*: :'"
+		}
+		fmt.Fprintf(&b, "\t\t\t%s ;;\n", arg)
+	}
+	b.WriteString("\t\tesac\n\t\t;;\n\tesac\n}\n\n_shorterctl \"$@\"\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeZshFlags writes an _arguments spec for every flag of fs.
+func writeZshFlags(b *strings.Builder, fs *flag.FlagSet, indent string) {
+	fs.VisitAll(func(f *flag.Flag) {
+		usage := strings.NewReplacer("[", `\[`, "]", `\]`).Replace(f.Usage)
+		spec := "-" + f.Name + "[" + usage + "]"
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); !ok || !b.IsBoolFlag() {
+			switch {
+			case fileFlags[f.Name]:
+				spec += ":file:_files"
+			case flagValues[f.Name] != nil:
+				spec += ":" + f.Name + ":(" + strings.Join(flagValues[f.Name], " ") + ")"
+			default:
+				spec += ":" + f.Name + ":"
+			}
+		}
+		fmt.Fprintf(b, "%s%s \\\n", indent, zshQuote(spec))
+	})
+}
+
+// zshQuote single quotes s for zsh.
+func zshQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
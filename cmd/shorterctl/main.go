@@ -4,30 +4,277 @@ import (
 	"context"
 	"flag"
 	"fmt"
-	"log"
+	"io"
 	"os"
+	"strings"
 	"time"
 
-	pb "github.com/jennyservices/shorter/transport/pb"
+	"github.com/jennyservices/shorter/client"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
 
-	"google.golang.org/grpc"
+// Exit statuses, so scripts can tell what went wrong without parsing
+// messages.
+const (
+	exitOK          = 0
+	exitError       = 1
+	exitUsage       = 2
+	exitNotFound    = 3
+	exitInvalid     = 4
+	exitDenied      = 5
+	exitConflict    = 6
+	exitGone        = 7
+	exitUnavailable = 8
 )
 
 func main() {
-	var (
-		gRPCAddr = flag.String("grpc", ":8081", "gRPC listen address")
-	)
-	flag.Parse()
-	conn, err := grpc.Dial(*gRPCAddr, grpc.WithInsecure(), grpc.WithTimeout(1*time.Second))
-	if err != nil {
-		log.Fatal(err)
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// globals are the flags every command accepts, before or after its name.
+type globals struct {
+	transport      string
+	endpoint       string
+	timeout        time.Duration
+	connectTimeout time.Duration
+	retries        int
+	tls            tlsFlags
+	output         string
+	configFile     string
+	profile        string
+
+	// credentials are what calls are authorized with, from the profile.
+	credentials string
+	// set are the names of the flags given on the command line.
+	set map[string]bool
+}
+
+// register adds the global flags to fs, defaulting to what g already holds so
+// flags given before the command aren't reset by parsing the ones after it.
+func (g *globals) register(fs *flag.FlagSet) {
+	fs.StringVar(&g.transport, "transport", g.transport, "how to talk to shorterd: grpc or http")
+	fs.StringVar(&g.endpoint, "endpoint", g.endpoint, "where shorterd is, host:port for gRPC and a base URL for HTTP; :8081 and http://localhost:8080 by default")
+	fs.DurationVar(&g.timeout, "timeout", g.timeout, "how long a call, retries included, can take")
+	fs.DurationVar(&g.connectTimeout, "connect-timeout", g.connectTimeout, "how long connecting to shorterd can take, no more than -timeout when 0")
+	fs.IntVar(&g.retries, "retries", g.retries, "how many times a call that failed in a way that might not happen again is retried")
+	g.tls.register(fs)
+	fs.StringVar(&g.output, "output", g.output, "output format: table, json or yaml")
+	fs.StringVar(&g.configFile, "config", g.configFile, "file profiles are kept in, shorterctl/profiles.json in the user's config directory by default")
+	fs.StringVar(&g.profile, "profile", g.profile, "profile to fill in the flags not given from, the one login or profile use picked by default; $SHORTERCTL_PROFILE sets it too")
+}
+
+// check reports global flags set to something that can't work.
+func (g *globals) check() error {
+	switch g.output {
+	case "table", "json", "yaml":
+	default:
+		return usageError(fmt.Sprintf("-output must be table, json or yaml, not %q", g.output))
+	}
+	switch g.transport {
+	case "grpc", "http":
+	default:
+		return usageError(fmt.Sprintf("-transport must be grpc or http, not %q", g.transport))
+	}
+	if g.transport == "http" && strings.HasPrefix(g.endpoint, "http://") && g.tls.enabled() {
+		return usageError("TLS needs an https -endpoint")
 	}
-	defer conn.Close()
+	return nil
+}
 
-	client := pb.NewShorterClient(conn)
-	resp, err := client.Shorten(context.Background(), &pb.URL{Addr: os.Args[1]})
+// grpcTarget returns the target to dial shorterd's gRPC server at.
+func (g *globals) grpcTarget() string {
+	if g.endpoint == "" {
+		return ":8081"
+	}
+	return g.endpoint
+}
+
+// baseURL returns the URL shorterd's HTTP API is served under, https if TLS
+// is enabled and the endpoint doesn't say.
+func (g *globals) baseURL() string {
+	switch {
+	case g.endpoint == "":
+		if g.tls.enabled() {
+			return "https://localhost:8080"
+		}
+		return "http://localhost:8080"
+	case strings.Contains(g.endpoint, "://"):
+		return g.endpoint
+	case g.tls.enabled():
+		return "https://" + g.endpoint
+	}
+	return "http://" + g.endpoint
+}
+
+// endpointName returns where shorterd is reached over the transport picked.
+func (g *globals) endpointName() string {
+	if g.transport == "http" {
+		return g.baseURL()
+	}
+	return g.grpcTarget()
+}
+
+// env is what commands run with.
+type env struct {
+	*globals
+	stdin          io.Reader
+	stdout, stderr io.Writer
+
+	client *client.Client
+}
+
+// dial returns the client commands call shorterd with, over the transport
+// -transport picked, connecting on first use.
+func (e *env) dial() (*client.Client, error) {
+	if e.client != nil {
+		return e.client, nil
+	}
+	cfg, err := e.tls.config()
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+	opts := []client.Option{
+		client.WithTimeout(e.timeout),
+		client.WithConnectTimeout(e.connectTimeout),
+		client.WithRetries(e.retries),
+		client.WithTLS(cfg),
+		client.WithAuthorization(e.credentials),
+	}
+	if e.transport == "http" {
+		e.client, err = client.NewHTTP(e.baseURL(), opts...)
+	} else {
+		e.client, err = client.NewGRPC(e.grpcTarget(), opts...)
+	}
+	return e.client, err
+}
+
+// usageError is returned for commands run with the wrong arguments.
+type usageError string
+
+func (e usageError) Error() string { return string(e) }
+
+// run runs the command in args and returns the status to exit with.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	g := &globals{transport: "grpc", timeout: 10 * time.Second, retries: 3, output: "table", profile: os.Getenv("SHORTERCTL_PROFILE")}
+	fs := flag.NewFlagSet("shorterctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	g.register(fs)
+	fs.Usage = func() { usage(stderr, fs) }
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	name, args := fs.Arg(0), fs.Args()[1:]
+	if name == "help" {
+		if len(args) == 0 {
+			fs.SetOutput(stdout)
+			usage(stdout, fs)
+			return exitOK
+		}
+		if c := lookup(args[0]); c != nil {
+			c.usage(stdout)
+			return exitOK
+		}
+		name = args[0]
+	}
+	c := lookup(name)
+	if c == nil {
+		fmt.Fprintf(stderr, "shorterctl: unknown command %q, see shorterctl help\n", name)
+		return exitUsage
+	}
+
+	cfs := c.flagSet(g)
+	cfs.SetOutput(stderr)
+	cfs.Usage = func() { c.usage(stderr) }
+	if err := cfs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	g.set = map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { g.set[f.Name] = true })
+	cfs.Visit(func(f *flag.Flag) { g.set[f.Name] = true })
+	e := &env{globals: g, stdin: stdin, stdout: stdout, stderr: stderr}
+	var err error
+	if !c.noProfile {
+		err = g.useProfile(g.set)
+	}
+	if err == nil {
+		err = g.check()
+	}
+	if err == nil {
+		err = c.run(context.Background(), e, cfs.Args())
+	}
+	if e.client != nil {
+		e.client.Close()
+	}
+	if err == nil {
+		return exitOK
+	}
+	if _, ok := err.(usageError); ok {
+		fmt.Fprintf(stderr, "shorterctl %s: %v\n", c.name, err)
+		c.usage(stderr)
+		return exitUsage
+	}
+	reportError(stderr, err)
+	return exitCode(err)
+}
+
+func usage(w io.Writer, fs *flag.FlagSet) {
+	fmt.Fprint(w, "Usage: shorterctl [flags] <command> [flags] [arguments]\n\nCommands:\n")
+	for _, c := range commands() {
+		fmt.Fprintf(w, "  %-11s %s\n", c.name, c.summary)
+	}
+	fmt.Fprint(w, "\nRun shorterctl help <command> for the flags of a command.\n\nGlobal flags:\n")
+	fs.PrintDefaults()
+	fmt.Fprint(w, `
+Exit status:
+  0  success
+  1  any error not listed below
+  2  wrong usage
+  3  no such link
+  4  invalid arguments
+  5  permission denied
+  6  conflicts with an existing link
+  7  the link has expired
+  8  shorterd is unavailable or too busy, try again later
+`)
+}
+
+// exitCode returns the status to exit with after failing with err.
+func exitCode(err error) int {
+	switch status.Code(err) {
+	case codes.NotFound:
+		return exitNotFound
+	case codes.InvalidArgument, codes.OutOfRange:
+		return exitInvalid
+	case codes.PermissionDenied, codes.Unauthenticated:
+		return exitDenied
+	case codes.AlreadyExists, codes.Aborted:
+		return exitConflict
+	case codes.FailedPrecondition:
+		return exitGone
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted:
+		return exitUnavailable
+	}
+	return exitError
+}
+
+// reportError writes err to w, just the message if it's a status.
+func reportError(w io.Writer, err error) {
+	if st, ok := status.FromError(err); ok {
+		fmt.Fprintf(w, "shorterctl: %s\n", st.Message())
+		return
 	}
-	fmt.Println(resp.Addr)
+	fmt.Fprintf(w, "shorterctl: %v\n", err)
 }
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jennyservices/jenny/options"
+	"github.com/jennyservices/shorter/shorter"
+	pb "github.com/jennyservices/shorter/transport/pb"
+	v1 "github.com/jennyservices/shorter/transport/v1"
+	"google.golang.org/grpc"
+)
+
+// serve serves a shorter service backed by memory over gRPC and HTTP on
+// loopback ports until stop is called. args are the flags to reach it over
+// either transport.
+func serve(t *testing.T) (args map[string][]string, stop func()) {
+	svc := shorter.New(shorter.NewMemoryStore())
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	s := grpc.NewServer()
+	pb.RegisterShorterServer(s, v1.NewShorterGRPCServer(svc))
+	go s.Serve(l)
+	ts := httptest.NewServer(v1.NewShorterHTTPServer(svc, options.WithErrorEncoder(shorter.EncodeError)))
+	return map[string][]string{
+		"grpc": {"-transport", "grpc", "-endpoint", l.Addr().String()},
+		"http": {"-transport", "http", "-endpoint", ts.URL},
+	}, func() {
+		s.Stop()
+		ts.Close()
+	}
+}
+
+// shorterctl runs shorterctl with args, returning what it wrote and the
+// status it exited with. Profiles are left out unless args pass -config.
+func shorterctl(stdin string, args ...string) (stdout, stderr string, status int) {
+	var out, errOut bytes.Buffer
+	args = append([]string{"-config", os.DevNull}, args...)
+	status = run(args, strings.NewReader(stdin), &out, &errOut)
+	return out.String(), errOut.String(), status
+}
+
+func TestCommands(t *testing.T) {
+	servers, stop := serve(t)
+	defer stop()
+
+	for _, transport := range []string{"grpc", "http"} {
+		dial := servers[transport]
+		run := func(stdin string, args ...string) (stdout, stderr string, status int) {
+			return shorterctl(stdin, append(append([]string{}, dial...), args...)...)
+		}
+		alias := "gopher-" + transport
+
+		out, errOut, status := run("", "shorten", "-alias", alias, "-max-clicks", "5", "-expires", "72h", "https://golang.org")
+		if status != exitOK || !strings.Contains(out, alias) {
+			t.Logf("%s: expected %s to be shortened, got %d: %s%s", transport, alias, status, out, errOut)
+			t.FailNow()
+		}
+
+		// global flags can come after the command too
+		out, errOut, status = run("", "expand", "-output", "json", alias)
+		var long v1.URL
+		if err := json.Unmarshal([]byte(out), &long); err != nil || status != exitOK || long.Addr != "https://golang.org/" {
+			t.Logf("%s: expected %s to expand to https://golang.org/ as JSON, got %d: %s%s", transport, alias, status, out, errOut)
+			t.Fail()
+		}
+
+		out, errOut, status = run("", "-output", "yaml", "info", alias)
+		for _, line := range []string{"code: " + alias + "\n", "clicks: 1\n", "max_clicks: 5\n"} {
+			if status != exitOK || !strings.Contains(out, line) {
+				t.Logf("%s: expected %q in the YAML, got %d: %s%s", transport, line, status, out, errOut)
+				t.Fail()
+			}
+		}
+
+		out, errOut, status = run("", "update", "-redirect", "308", alias)
+		if status != exitOK || !strings.Contains(out, "308") {
+			t.Logf("%s: expected the redirect to be updated, got %d: %s%s", transport, status, out, errOut)
+			t.Fail()
+		}
+
+		out, errOut, status = run("", "-output", "yaml", "update", "-max-clicks", "0", "-expires", "never", alias)
+		if status != exitOK || strings.Contains(out, "max_clicks") || strings.Contains(out, "expires_at") {
+			t.Logf("%s: expected the click limit and expiry to be cleared, got %d: %s%s", transport, status, out, errOut)
+			t.Fail()
+		}
+
+		out, errOut, status = run("", "list", "-limit", "1", "-all")
+		if status != exitOK || strings.Count(out, "\n") < 2 {
+			t.Logf("%s: expected a header and the links, got %d: %s%s", transport, status, out, errOut)
+			t.Fail()
+		}
+
+		out, errOut, status = run("", "delete", alias)
+		if status != exitOK || !strings.Contains(out, alias) {
+			t.Logf("%s: expected %s to be deleted, got %d: %s%s", transport, alias, status, out, errOut)
+			t.Fail()
+		}
+	}
+
+	out, errOut, status := shorterctl("https://example.com\nhttps://example.org\n", append(servers["grpc"], "shorten", "-stream")...)
+	if status != exitOK || strings.Count(out, "\n") != 2 {
+		t.Logf("expected two URLs to be shortened from stdin, got %d: %s%s", status, out, errOut)
+		t.Fail()
+	}
+	if _, _, status := shorterctl("", append(servers["http"], "shorten", "-stream")...); status != exitUsage {
+		t.Logf("expected -stream to need gRPC, got %d", status)
+		t.Fail()
+	}
+
+	// lines over bufio's 64KiB default are read, those over maxLineLength
+	// fail the stream rather than leave it waiting for more URLs
+	long := "https://example.com/" + strings.Repeat("a", 100<<10)
+	out, errOut, status = shorterctl(long+"\n", append(servers["grpc"], "shorten", "-stream")...)
+	if status != exitOK || strings.Count(out, "\n") != 1 {
+		t.Logf("expected a URL over 64KiB to be shortened, got %d: %.200s%s", status, out, errOut)
+		t.Fail()
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		tooLong := "https://example.com/" + strings.Repeat("a", maxLineLength)
+		_, errOut, status := shorterctl("https://example.org\n"+tooLong+"\n", append(servers["grpc"], "shorten", "-stream")...)
+		if status != exitError || !strings.Contains(errOut, "reading URL 2") {
+			t.Logf("expected a line over the limit to fail the stream, got %d: %s", status, errOut)
+			t.Fail()
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Log("expected a line over the limit not to leave the stream hanging")
+		t.FailNow()
+	}
+}
+
+func TestHTTPS(t *testing.T) {
+	dir, remove := tempDir(t)
+	defer remove()
+	ts := httptest.NewTLSServer(v1.NewShorterHTTPServer(shorter.New(shorter.NewMemoryStore())))
+	defer ts.Close()
+	ca := filepath.Join(dir, "ca.pem")
+	ioutil.WriteFile(ca, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}), 0644)
+
+	host := strings.TrimPrefix(ts.URL, "https://")
+	for _, tc := range []struct {
+		args   []string
+		status int
+	}{
+		{[]string{"-endpoint", ts.URL}, exitUnavailable},
+		{[]string{"-endpoint", host, "-server-ca", ca}, exitOK},
+		{[]string{"-endpoint", "localhost:" + ts.URL[strings.LastIndex(ts.URL, ":")+1:], "-server-ca", ca, "-tls-server-name", "example.com"}, exitOK},
+		{[]string{"-endpoint", ts.URL, "-tls-skip-verify"}, exitOK},
+		{[]string{"-endpoint", "http://" + host, "-tls"}, exitUsage},
+	} {
+		args := append([]string{"-transport", "http", "-retries", "0"}, tc.args...)
+		_, errOut, status := shorterctl("", append(args, "shorten", "https://golang.org")...)
+		if status != tc.status {
+			t.Logf("%q: expected exit status %d, got %d: %s", tc.args, tc.status, status, errOut)
+			t.Fail()
+		}
+	}
+}
+
+func TestExitStatus(t *testing.T) {
+	servers, stop := serve(t)
+	defer stop()
+	grpcServer := servers["grpc"]
+
+	for _, tc := range []struct {
+		args   []string
+		status int
+	}{
+		{[]string{}, exitUsage},
+		{[]string{"frobnicate"}, exitUsage},
+		{[]string{"info"}, exitUsage},
+		{[]string{"-output", "xml", "info", "abc"}, exitUsage},
+		{[]string{"update", "abc"}, exitUsage},
+		{[]string{"-transport", "smoke-signals", "info", "abc"}, exitUsage},
+		{append(grpcServer, "info", "missing"), exitNotFound},
+		{append(grpcServer, "shorten", "gopher://example.com"), exitInvalid},
+		{append(grpcServer, "shorten", "-alias", "expand", "https://example.com"), exitInvalid},
+		{append(servers["http"], "info", "missing"), exitNotFound},
+		{[]string{"-endpoint", "127.0.0.1:1", "-timeout", "100ms", "info", "abc"}, exitUnavailable},
+		{[]string{"-transport", "http", "-endpoint", "127.0.0.1:1", "-retries", "0", "info", "abc"}, exitUnavailable},
+		{[]string{"help", "list"}, exitOK},
+	} {
+		_, errOut, status := shorterctl("", tc.args...)
+		if status != tc.status {
+			t.Logf("%q: expected exit status %d, got %d: %s", tc.args, tc.status, status, errOut)
+			t.Fail()
+		}
+	}
+}
+
+func TestYAML(t *testing.T) {
+	var b bytes.Buffer
+	page := v1.LinkPage{Links: []v1.Link{{Code: "abc", Addr: "https://example.com"}, {Code: "123"}}}
+	if err := writeYAML(&b, page); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	expected := `links:
+- addr: "https://example.com"
+  clicks: 0
+  code: abc
+  short: ""
+- addr: ""
+  clicks: 0
+  code: "123"
+  short: ""
+`
+	if b.String() != expected {
+		t.Logf("expected\n%s\ngot\n%s", expected, b.String())
+		t.Fail()
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	out, errOut, status := shorterctl("", "completion", "bash")
+	if status != exitOK || !strings.Contains(out, "complete -o default -F _shorterctl shorterctl") {
+		t.Logf("expected a bash completion script, got %d: %s%s", status, out, errOut)
+		t.FailNow()
+	}
+	if bash, err := exec.LookPath("bash"); err == nil {
+		cmd := exec.Command(bash, "-n")
+		cmd.Stdin = strings.NewReader(out)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Logf("expected the script to be valid bash: %v\n%s", err, output)
+			t.Fail()
+		}
+	}
+
+	out, errOut, status = shorterctl("", "completion", "zsh")
+	if status != exitOK || !strings.HasPrefix(out, "#compdef shorterctl\n") {
+		t.Logf("expected a zsh completion function, got %d: %s%s", status, out, errOut)
+		t.FailNow()
+	}
+	if zsh, err := exec.LookPath("zsh"); err == nil {
+		cmd := exec.Command(zsh, "-n")
+		cmd.Stdin = strings.NewReader(out)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Logf("expected the function to be valid zsh: %v\n%s", err, output)
+			t.Fail()
+		}
+	}
+}
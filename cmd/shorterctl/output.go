@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jennyservices/shorter/internal/yaml"
+)
+
+// print writes v, what a command returned, in the format -output asks for.
+// Tables are made of header and rows rather than v.
+func (e *env) print(v interface{}, header []string, rows [][]string) error {
+	switch e.output {
+	case "json":
+		enc := json.NewEncoder(e.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case "yaml":
+		return writeYAML(e.stdout, v)
+	}
+	tw := tabwriter.NewWriter(e.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// writeYAML writes v to w as YAML, with the keys it has as JSON in
+// alphabetical order.
+func writeYAML(w io.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	yamlNode(&b, doc, "")
+	_, err = w.Write(b.Bytes())
+	return err
+}
+
+// yamlNode writes v, which starts where b is at and continues on lines
+// indented by indent.
+func yamlNode(b *bytes.Buffer, v interface{}, indent string) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			b.WriteString("{}\n")
+			return
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for i, k := range keys {
+			if i > 0 {
+				b.WriteString(indent)
+			}
+			b.WriteString(yamlScalar(k) + ":")
+			switch child := v[k].(type) {
+			case map[string]interface{}:
+				if len(child) > 0 {
+					b.WriteString("\n" + indent + "  ")
+					yamlNode(b, child, indent+"  ")
+					continue
+				}
+			case []interface{}:
+				if len(child) > 0 {
+					b.WriteString("\n" + indent)
+					yamlNode(b, child, indent)
+					continue
+				}
+			}
+			b.WriteString(" ")
+			yamlNode(b, v[k], indent+"  ")
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			b.WriteString("[]\n")
+			return
+		}
+		for i, item := range v {
+			if i > 0 {
+				b.WriteString(indent)
+			}
+			b.WriteString("- ")
+			yamlNode(b, item, indent+"  ")
+		}
+	default:
+		b.WriteString(yamlScalar(v) + "\n")
+	}
+}
+
+// yamlScalar formats v, a JSON scalar, as a YAML one.
+func yamlScalar(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	case json.Number:
+		return v.String()
+	case string:
+		return yaml.String(v)
+	}
+	return fmt.Sprint(v)
+}
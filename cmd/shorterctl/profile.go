@@ -0,0 +1,412 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// profiles is the file named profiles are kept in, so the settings and
+// credentials for talking to a shorterd don't have to be given every time.
+type profiles struct {
+	// Current is the profile used when -profile doesn't name one.
+	Current  string              `json:"current,omitempty"`
+	Profiles map[string]*profile `json:"profiles"`
+}
+
+// profile holds the settings of the global flags it's named after, and the
+// credentials calls are made with. Only one of APIKey and JWT is set.
+type profile struct {
+	Transport string     `json:"transport,omitempty"`
+	Endpoint  string     `json:"endpoint,omitempty"`
+	APIKey    string     `json:"api_key,omitempty"`
+	JWT       string     `json:"jwt,omitempty"`
+	TLS       profileTLS `json:"tls"`
+}
+
+type profileTLS struct {
+	Enabled    bool   `json:"enabled,omitempty"`
+	Cert       string `json:"cert,omitempty"`
+	Key        string `json:"key,omitempty"`
+	ServerCA   string `json:"server_ca,omitempty"`
+	ServerName string `json:"server_name,omitempty"`
+	SkipVerify bool   `json:"skip_verify,omitempty"`
+}
+
+// defaultProfilesPath returns where profiles are kept unless -config says
+// otherwise, in the user's config directory.
+func defaultProfilesPath() string {
+	var dir string
+	switch runtime.GOOS {
+	case "windows":
+		dir = os.Getenv("AppData")
+	case "darwin":
+		if home := os.Getenv("HOME"); home != "" {
+			dir = filepath.Join(home, "Library", "Application Support")
+		}
+	default:
+		dir = os.Getenv("XDG_CONFIG_HOME")
+		if home := os.Getenv("HOME"); dir == "" && home != "" {
+			dir = filepath.Join(home, ".config")
+		}
+	}
+	return filepath.Join(dir, "shorterctl", "profiles.json")
+}
+
+// readProfiles reads the profiles at path. A file that doesn't exist yet, or
+// is empty, holds none.
+func readProfiles(path string) (*profiles, error) {
+	pf := &profiles{Profiles: map[string]*profile{}}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) || err == nil && len(bytes.TrimSpace(data)) == 0 {
+		return pf, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, pf); err != nil {
+		return nil, fmt.Errorf("reading profiles %s: %v", path, err)
+	}
+	if pf.Profiles == nil {
+		pf.Profiles = map[string]*profile{}
+	}
+	return pf, nil
+}
+
+// write replaces the profiles at path with pf. As they hold credentials, only
+// the user can read them.
+func (pf *profiles) write(path string) error {
+	data, err := json.MarshalIndent(pf, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), ".profiles")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// useProfile fills in the global flags that weren't set with the profile
+// -profile names, or the current one if it doesn't.
+func (g *globals) useProfile(set map[string]bool) error {
+	pf, err := readProfiles(g.profilesPath())
+	if err != nil {
+		return err
+	}
+	name := g.profile
+	if name == "" {
+		name = pf.Current
+	}
+	p, ok := pf.Profiles[name]
+	if !ok {
+		if g.profile != "" {
+			return usageError(fmt.Sprintf("no profile named %q, see shorterctl profile list", g.profile))
+		}
+		return nil
+	}
+	p.apply(g, set)
+	return nil
+}
+
+func (g *globals) profilesPath() string {
+	if g.configFile != "" {
+		return g.configFile
+	}
+	return defaultProfilesPath()
+}
+
+// apply sets the global flags that weren't set to what p holds for them,
+// along with its credentials.
+func (p *profile) apply(g *globals, set map[string]bool) {
+	str := func(name string, dst *string, v string) {
+		if !set[name] && v != "" {
+			*dst = v
+		}
+	}
+	str("transport", &g.transport, p.Transport)
+	str("endpoint", &g.endpoint, p.Endpoint)
+	str("tls-cert", &g.tls.certFile, p.TLS.Cert)
+	str("tls-key", &g.tls.keyFile, p.TLS.Key)
+	str("server-ca", &g.tls.caFile, p.TLS.ServerCA)
+	str("tls-server-name", &g.tls.serverName, p.TLS.ServerName)
+	if !set["tls"] && p.TLS.Enabled {
+		g.tls.on = true
+	}
+	if !set["tls-skip-verify"] && p.TLS.SkipVerify {
+		g.tls.skipVerify = true
+	}
+	g.credentials = p.authorization()
+}
+
+// authorization returns what calls send as their Authorization header. JWTs
+// and API keys both go as bearer tokens (RFC 6750), which leaves telling
+// them apart to whatever checks them.
+func (p *profile) authorization() string {
+	switch {
+	case p.JWT != "":
+		return "Bearer " + p.JWT
+	case p.APIKey != "":
+		return "Bearer " + p.APIKey
+	}
+	return ""
+}
+
+// credentials describes p's credentials without giving them away.
+func (p *profile) credentials() string {
+	switch {
+	case p.JWT != "":
+		return "jwt"
+	case p.APIKey != "":
+		return "api key"
+	}
+	return "none"
+}
+
+// profileOf returns a profile holding the settings of g, with its file
+// paths made absolute so they still work from another directory.
+func profileOf(g *globals) (*profile, error) {
+	p := &profile{
+		Transport: g.transport,
+		Endpoint:  g.endpoint,
+		TLS: profileTLS{
+			Enabled:    g.tls.on,
+			ServerName: g.tls.serverName,
+			SkipVerify: g.tls.skipVerify,
+		},
+	}
+	for _, f := range []struct {
+		dst *string
+		src string
+	}{
+		{&p.TLS.Cert, g.tls.certFile},
+		{&p.TLS.Key, g.tls.keyFile},
+		{&p.TLS.ServerCA, g.tls.caFile},
+	} {
+		if f.src == "" {
+			continue
+		}
+		abs, err := filepath.Abs(f.src)
+		if err != nil {
+			return nil, err
+		}
+		*f.dst = abs
+	}
+	return p, nil
+}
+
+// isJWT reports whether token looks like a JWT, three base64url segments
+// separated by dots, rather than an API key.
+func isJWT(token string) bool {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	for _, part := range parts {
+		if part == "" || strings.Trim(part, "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_=") != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// readSecret reads a line from r without echoing it when r is a terminal.
+func readSecret(r io.Reader) (string, error) {
+	if f, ok := r.(*os.File); ok {
+		restore, err := echoOff(f)
+		if err != nil {
+			return "", fmt.Errorf("%v, pass -api-key or -jwt or pipe the token in", err)
+		}
+		defer restore()
+	}
+	line, _ := bufio.NewReader(r).ReadString('\n')
+	return line, nil
+}
+
+func loginCommand() *command {
+	var apiKey, jwt string
+	return &command{
+		name:    "login",
+		args:    "[NAME]",
+		summary: "Save the connection flags and credentials as profile NAME, or default, and switch to it",
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&apiKey, "api-key", "", "API key to call shorterd with, read from stdin, unechoed, when neither it nor -jwt is given")
+			fs.StringVar(&jwt, "jwt", "", "JWT to call shorterd with instead of an API key")
+		},
+		noProfile: true,
+		run: func(ctx context.Context, e *env, args []string) error {
+			if len(args) > 1 {
+				return usageError("expected a single profile name")
+			}
+			name := "default"
+			if len(args) == 1 {
+				name = args[0]
+			}
+			if apiKey != "" && jwt != "" {
+				return usageError("-api-key and -jwt can't be used together")
+			}
+			if apiKey == "" && jwt == "" {
+				fmt.Fprintf(e.stderr, "API key or JWT for %s: ", name)
+				line, err := readSecret(e.stdin)
+				fmt.Fprintln(e.stderr)
+				if err != nil {
+					return err
+				}
+				switch token := strings.TrimSpace(line); {
+				case token == "":
+					return errors.New("no credentials given")
+				case isJWT(token):
+					jwt = token
+				default:
+					apiKey = token
+				}
+			}
+
+			path := e.profilesPath()
+			pf, err := readProfiles(path)
+			if err != nil {
+				return err
+			}
+			// flags given now win over what the profile had
+			if old, ok := pf.Profiles[name]; ok {
+				old.apply(e.globals, e.set)
+			}
+			if err := e.check(); err != nil {
+				return err
+			}
+			p, err := profileOf(e.globals)
+			if err != nil {
+				return err
+			}
+			p.APIKey, p.JWT = apiKey, jwt
+			e.credentials = p.authorization()
+
+			// make sure shorterd can be reached before saving the profile.
+			// shorterd doesn't check credentials itself, a proxy in front
+			// of it may turn them away though.
+			c, err := e.dial()
+			if err != nil {
+				return err
+			}
+			if _, err := c.List(ctx, "", 1); err != nil {
+				return err
+			}
+			pf.Profiles[name], pf.Current = p, name
+			if err := pf.write(path); err != nil {
+				return err
+			}
+			fmt.Fprintf(e.stdout, "Reached %s, saved as profile %s\n", e.endpointName(), name)
+			return nil
+		},
+	}
+}
+
+func profileCommand() *command {
+	return &command{
+		name:      "profile",
+		args:      "list | use NAME | delete NAME",
+		summary:   "List the profiles saved by login, pick the one to use or delete one",
+		flags:     func(*flag.FlagSet) {},
+		noProfile: true,
+		run: func(ctx context.Context, e *env, args []string) error {
+			if len(args) == 0 {
+				return usageError("expected list, use or delete")
+			}
+			path := e.profilesPath()
+			pf, err := readProfiles(path)
+			if err != nil {
+				return err
+			}
+			switch action, args := args[0], args[1:]; action {
+			case "list":
+				if len(args) > 0 {
+					return usageError("list takes no arguments")
+				}
+				return e.printProfiles(pf)
+			case "use", "delete":
+				if err := oneArg(args, "profile name"); err != nil {
+					return err
+				}
+				name := args[0]
+				if _, ok := pf.Profiles[name]; !ok {
+					return usageError(fmt.Sprintf("no profile named %q, see shorterctl profile list", name))
+				}
+				if action == "use" {
+					pf.Current = name
+				} else {
+					delete(pf.Profiles, name)
+					if pf.Current == name {
+						pf.Current = ""
+					}
+				}
+				return pf.write(path)
+			default:
+				return usageError(fmt.Sprintf("unknown action %q, expected list, use or delete", action))
+			}
+		},
+	}
+}
+
+// printProfiles prints every profile in pf, but not their credentials.
+func (e *env) printProfiles(pf *profiles) error {
+	type summary struct {
+		Name        string `json:"name"`
+		Current     bool   `json:"current"`
+		Transport   string `json:"transport,omitempty"`
+		Endpoint    string `json:"endpoint,omitempty"`
+		Credentials string `json:"credentials"`
+		TLS         bool   `json:"tls"`
+	}
+	names := make([]string, 0, len(pf.Profiles))
+	for name := range pf.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	summaries := []summary{}
+	var rows [][]string
+	for _, name := range names {
+		p := pf.Profiles[name]
+		tls := p.TLS
+		s := summary{
+			Name:        name,
+			Current:     name == pf.Current,
+			Transport:   p.Transport,
+			Endpoint:    p.Endpoint,
+			Credentials: p.credentials(),
+			TLS:         tls.Enabled || tls.Cert != "" || tls.ServerCA != "" || tls.ServerName != "" || tls.SkipVerify,
+		}
+		summaries = append(summaries, s)
+		current := ""
+		if s.Current {
+			current = "*"
+		}
+		rows = append(rows, []string{current, name, p.Transport, p.Endpoint, s.Credentials, fmt.Sprint(s.TLS)})
+	}
+	return e.print(summaries, []string{"CURRENT", "NAME", "TRANSPORT", "ENDPOINT", "CREDENTIALS", "TLS"}, rows)
+}
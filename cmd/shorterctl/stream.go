@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	pb "github.com/jennyservices/shorter/transport/pb"
+	"google.golang.org/grpc/codes"
+)
+
+// maxLineLength bounds the lines shortenStream reads, so long URLs fit but
+// a file that isn't made of lines doesn't get buffered whole.
+const maxLineLength = 1 << 20
+
+// shortenStream sends every non-empty line of r to ShortenStream, with the
+// settings of template, and writes each result to w as the long URL and the
+// short code, or the error, separated by a tab. Results are written as they
+// arrive, which isn't necessarily the order they were read in. It returns how
+// many URLs couldn't be shortened.
+func shortenStream(ctx context.Context, client pb.ShorterClient, template *pb.URL, r io.Reader, w io.Writer) (int, error) {
+	// reading r failing cancels the stream, as the server would otherwise
+	// wait for more URLs
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := client.ShortenStream(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		mu    sync.Mutex
+		longs = make(map[uint64]string)
+	)
+	sendErr := make(chan error, 1)
+	go func() {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(nil, maxLineLength)
+		var id uint64
+		for scanner.Scan() {
+			addr := strings.TrimSpace(scanner.Text())
+			if addr == "" {
+				continue
+			}
+			id++
+			mu.Lock()
+			longs[id] = addr
+			mu.Unlock()
+			u := *template
+			u.Addr = addr
+			if err := stream.Send(&pb.StreamRequest{Id: id, Url: &u}); err != nil {
+				// the real error comes out of Recv
+				sendErr <- nil
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			sendErr <- fmt.Errorf("reading URL %d: %v", id+1, err)
+			cancel()
+			return
+		}
+		sendErr <- stream.CloseSend()
+	}()
+
+	failed := 0
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			select {
+			case readErr := <-sendErr:
+				if readErr != nil {
+					return failed, readErr
+				}
+			default:
+			}
+			return failed, err
+		}
+
+		mu.Lock()
+		addr := longs[res.Id]
+		delete(longs, res.Id)
+		mu.Unlock()
+
+		if res.Error != nil {
+			failed++
+			fmt.Fprintf(w, "%s\terror: %s: %s\n", addr, codes.Code(res.Error.Code), res.Error.Message)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\n", addr, res.Url.GetAddr())
+	}
+	return failed, <-sendErr
+}
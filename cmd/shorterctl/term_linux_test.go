@@ -0,0 +1,86 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestEchoOff(t *testing.T) {
+	ptm, err := os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+	if err != nil {
+		t.Skip("no pseudo terminals:", err)
+	}
+	defer ptm.Close()
+	if err := unix.IoctlSetPointerInt(int(ptm.Fd()), unix.TIOCSPTLCK, 0); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	n, err := unix.IoctlGetInt(int(ptm.Fd()), unix.TIOCGPTN)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	pts, err := os.OpenFile("/dev/pts/"+strconv.Itoa(n), os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	defer pts.Close()
+
+	echoing := func() bool {
+		termios, err := unix.IoctlGetTermios(int(pts.Fd()), unix.TCGETS)
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+		return termios.Lflag&unix.ECHO != 0
+	}
+	if !echoing() {
+		t.Log("new terminal doesn't echo")
+		t.FailNow()
+	}
+	restore, err := echoOff(pts)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if echoing() {
+		t.Log("terminal still echoes")
+		t.Fail()
+	}
+	restore()
+	if !echoing() {
+		t.Log("echo not turned back on")
+		t.Fail()
+	}
+
+	if _, err := ptm.WriteString("secret\n"); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	line, err := readSecret(pts)
+	if err != nil || line != "secret\n" {
+		t.Logf("readSecret = %q, %v", line, err)
+		t.Fail()
+	}
+	if !echoing() {
+		t.Log("readSecret left echo off")
+		t.Fail()
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	defer r.Close()
+	w.WriteString("piped\n")
+	w.Close()
+	if line, err := readSecret(r); err != nil || line != "piped\n" {
+		t.Logf("readSecret from a pipe = %q, %v", line, err)
+		t.Fail()
+	}
+}
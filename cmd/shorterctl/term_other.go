@@ -0,0 +1,18 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// echoOff can't stop terminals echoing here, so it refuses them, leaving
+// only pipes and files to read secrets from.
+func echoOff(f *os.File) (restore func(), err error) {
+	if fi, err := f.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+		return nil, errors.New("can't turn off echo on this terminal")
+	}
+	return func() {}, nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+)
+
+// tlsFlags are the flags configuring TLS.
+type tlsFlags struct {
+	on         bool
+	certFile   string
+	keyFile    string
+	caFile     string
+	serverName string
+	skipVerify bool
+}
+
+func (t *tlsFlags) register(fs *flag.FlagSet) {
+	fs.BoolVar(&t.on, "tls", t.on, "connect over TLS, implied by the other -tls flags and -server-ca")
+	fs.StringVar(&t.certFile, "tls-cert", t.certFile, "PEM client certificate to present for mutual TLS")
+	fs.StringVar(&t.keyFile, "tls-key", t.keyFile, "PEM private key of -tls-cert")
+	fs.StringVar(&t.caFile, "server-ca", t.caFile, "PEM bundle of CAs the server certificate is checked against, the system's when empty")
+	fs.StringVar(&t.serverName, "tls-server-name", t.serverName, "name the server certificate is checked against instead of the endpoint's host")
+	fs.BoolVar(&t.skipVerify, "tls-skip-verify", t.skipVerify, "don't check the server certificate at all, for testing only")
+}
+
+func (t *tlsFlags) enabled() bool {
+	return t.on || t.certFile != "" || t.keyFile != "" || t.caFile != "" || t.serverName != "" || t.skipVerify
+}
+
+// config returns the TLS configuration for talking to shorterd, or nil to
+// talk in plaintext unless TLS is enabled. The server is verified against the
+// PEM bundle in caFile, or the system roots if it's empty. certFile and
+// keyFile hold the client certificate for mutual TLS.
+func (t *tlsFlags) config() (*tls.Config, error) {
+	if !t.enabled() {
+		return nil, nil
+	}
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         t.serverName,
+		InsecureSkipVerify: t.skipVerify,
+	}
+	if t.certFile != "" || t.keyFile != "" {
+		if t.certFile == "" || t.keyFile == "" {
+			return nil, errors.New("-tls-cert and -tls-key must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(t.certFile, t.keyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	if t.caFile != "" {
+		pem, err := ioutil.ReadFile(t.caFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", t.caFile)
+		}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,522 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jennyservices/shorter/internal/yaml"
+	"github.com/jennyservices/shorter/shorter"
+)
+
+// config is everything shorterd can be configured with. Each setting is read
+// from, in increasing order of precedence: the defaults below, a YAML or JSON
+// file, a SHORTER_* environment variable and a flag. config.example.yaml
+// documents every setting.
+//
+// A setting's key is its section and name joined with a dot, like
+// listen.http. Its environment variable is the key in upper case with dots
+// replaced by underscores, prefixed with SHORTER_, like SHORTER_LISTEN_HTTP.
+// Lists are comma separated in environment variables and flags. Unknown
+// settings in the file are errors, but other SHORTER_* variables are only
+// warned about, as a Kubernetes service named shorter gets SHORTER_PORT and
+// the like injected into every pod.
+type config struct {
+	Listen     listenConfig     `config:"listen"`
+	TLS        tlsConfig        `config:"tls"`
+	BaseURL    string           `config:"base_url"`
+	Redirect   int              `config:"redirect"`
+	Store      storeConfig      `config:"store"`
+	Generator  generatorConfig  `config:"generator"`
+	URLs       urlsConfig       `config:"urls"`
+	Batch      batchConfig      `config:"batch"`
+	Health     healthConfig     `config:"health"`
+	Reflection reflectionConfig `config:"reflection"`
+}
+
+type listenConfig struct {
+	HTTP         string        `config:"http"`
+	GRPC         string        `config:"grpc"`
+	SinglePort   bool          `config:"single_port"`
+	DrainTimeout time.Duration `config:"drain_timeout"`
+}
+
+type tlsConfig struct {
+	Cert        string        `config:"cert"`
+	Key         string        `config:"key"`
+	ClientCA    string        `config:"client_ca"`
+	ReloadEvery time.Duration `config:"reload_every"`
+}
+
+type storeConfig struct {
+	DataDir      string        `config:"data_dir"`
+	CompactEvery time.Duration `config:"compact_every"`
+	ReapEvery    time.Duration `config:"reap_every"`
+}
+
+type generatorConfig struct {
+	Name       string `config:"name"`
+	CodeLength int    `config:"code_length"`
+}
+
+type urlsConfig struct {
+	Schemes   []string `config:"schemes"`
+	SortQuery bool     `config:"sort_query"`
+}
+
+type batchConfig struct {
+	Max         int `config:"max"`
+	Parallelism int `config:"parallelism"`
+}
+
+type healthConfig struct {
+	Enabled bool          `config:"enabled"`
+	Every   time.Duration `config:"every"`
+}
+
+type reflectionConfig struct {
+	Enabled bool `config:"enabled"`
+}
+
+func defaultConfig() *config {
+	c := &config{Redirect: http.StatusFound}
+	c.Listen.HTTP = ":8080"
+	c.Listen.GRPC = ":8081"
+	c.Listen.DrainTimeout = 30 * time.Second
+	c.TLS.ReloadEvery = 10 * time.Second
+	c.Store.CompactEvery = time.Hour
+	c.Store.ReapEvery = time.Minute
+	c.Generator.Name = "hash"
+	c.Generator.CodeLength = 7
+	c.URLs.Schemes = []string{"http", "https"}
+	c.Batch.Max = 1000
+	c.Batch.Parallelism = 8
+	c.Health.Enabled = true
+	c.Health.Every = 5 * time.Second
+	return c
+}
+
+// flags maps the flags shorterd has always had onto the settings they set.
+var flags = []struct {
+	name, key, usage string
+}{
+	{"addr", "listen.http", "HTTP listen address"},
+	{"grpc", "listen.grpc", "gRPC listen address"},
+	{"single-port", "listen.single_port", "serve HTTP/1.1, h2c and gRPC all on -addr instead of using -grpc"},
+	{"drain-timeout", "listen.drain_timeout", "how long requests in flight get to finish on SIGINT or SIGTERM before they're cut off"},
+	{"tls-cert", "tls.cert", "PEM certificate to serve TLS with on every listener, plaintext when empty"},
+	{"tls-key", "tls.key", "PEM private key of -tls-cert"},
+	{"client-ca", "tls.client_ca", "PEM bundle of CAs clients must present a certificate from, needs -tls-cert"},
+	{"tls-reload-every", "tls.reload_every", "how often the TLS files are checked for changes, they're also reloaded on SIGHUP"},
+	{"base-url", "base_url", "URL short links are returned under, like https://sho.rt, bare codes are returned when empty"},
+	{"redirect", "redirect", "HTTP status short links redirect with, one of 301, 302, 307 or 308"},
+	{"data-dir", "store.data_dir", "directory links are kept in, links are only kept in memory when empty"},
+	{"compact-every", "store.compact_every", "how often the log in -data-dir is checked for compaction"},
+	{"reap-every", "store.reap_every", "how often expired links are purged, 0 never purges them"},
+	{"generator", "generator.name", "how codes are minted: hash, sequential or random"},
+	{"code-length", "generator.code_length", "length of the codes minted by the random generator"},
+	{"schemes", "urls.schemes", "comma separated schemes URLs can be shortened with"},
+	{"sort-query", "urls.sort_query", "sort query parameters by name when canonicalising URLs"},
+	{"max-batch", "batch.max", "most URLs a single ShortenBatch call can shorten"},
+	{"batch-parallelism", "batch.parallelism", "how many URLs of a batch are shortened concurrently"},
+	{"health", "health.enabled", "serve grpc.health.v1.Health on the gRPC port"},
+	{"health-every", "health.every", "how often the store is checked for readiness when -health is set"},
+	{"reflection", "reflection.enabled", "serve gRPC server reflection on the gRPC port"},
+}
+
+// envPrefix prefixes the environment variables settings are read from.
+const envPrefix = "SHORTER_"
+
+// loadConfig layers the file at the path given with -config or SHORTER_CONFIG,
+// the environment and the flags in args over the defaults, and validates the
+// result. It also reports whether -print-config was given.
+func loadConfig(args []string, environ []string) (*config, bool, error) {
+	c := defaultConfig()
+	fs := flag.NewFlagSet("shorterd", flag.ContinueOnError)
+	var (
+		path        = fs.String("config", "", "YAML or JSON file to read settings from, also read from "+envPrefix+"CONFIG")
+		printConfig = fs.Bool("print-config", false, "print the settings in effect, with secrets redacted, and exit")
+	)
+	fields := c.settings()
+	var setFlags []*flagValue
+	for _, f := range flags {
+		s := fields.get(f.key)
+		fs.Var(&flagValue{name: f.name, setting: s, def: s.String(), setFlags: &setFlags}, f.name, f.usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, false, err
+	}
+	if fs.NArg() > 0 {
+		return nil, false, fmt.Errorf("unexpected arguments %q", fs.Args())
+	}
+
+	env := make(map[string]string)
+	for _, kv := range environ {
+		if i := strings.Index(kv, "="); i > 0 && strings.HasPrefix(kv[:i], envPrefix) {
+			env[kv[:i]] = kv[i+1:]
+		}
+	}
+	if *path == "" {
+		*path = env[envPrefix+"CONFIG"]
+	}
+	delete(env, envPrefix+"CONFIG")
+
+	if *path != "" {
+		values, err := readConfigFile(*path)
+		if err != nil {
+			return nil, false, err
+		}
+		for key, value := range values {
+			s := fields.get(key)
+			if s == nil {
+				return nil, false, fmt.Errorf("%s: unknown setting %q", *path, key)
+			}
+			if err := s.set(value); err != nil {
+				return nil, false, fmt.Errorf("%s: %s: %v", *path, key, err)
+			}
+		}
+	}
+
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s := fields.byEnv(name)
+		if s == nil {
+			log.Printf("ignoring unknown environment variable %s", name)
+			continue
+		}
+		if err := s.set(env[name]); err != nil {
+			return nil, false, fmt.Errorf("%s: %v", name, err)
+		}
+	}
+
+	for _, f := range setFlags {
+		if err := f.setting.set(f.value); err != nil {
+			return nil, false, fmt.Errorf("-%s: %v", f.name, err)
+		}
+	}
+
+	if err := c.validate(); err != nil {
+		return nil, false, err
+	}
+	return c, *printConfig, nil
+}
+
+// validate reports every setting that doesn't make sense at once.
+func (c *config) validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(c.Listen.HTTP != "", "listen.http can't be empty")
+	check(c.Listen.SinglePort || c.Listen.GRPC != "", "listen.grpc can't be empty unless listen.single_port is set")
+	check(c.Listen.DrainTimeout >= 0, "listen.drain_timeout can't be negative")
+	check(c.TLS.Cert == "" == (c.TLS.Key == ""), "tls.cert and tls.key must be set together")
+	check(c.TLS.ClientCA == "" || c.TLS.Cert != "", "tls.client_ca needs tls.cert and tls.key")
+	if c.BaseURL != "" {
+		u, err := url.Parse(c.BaseURL)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" && u.RawQuery == "" && u.Fragment == "",
+			"base_url %q must be an absolute http or https URL without a query or fragment", c.BaseURL)
+	}
+	check(shorter.ValidRedirect(c.Redirect), "redirect %d isn't one of 301, 302, 307 or 308", c.Redirect)
+	check(c.Store.CompactEvery >= 0, "store.compact_every can't be negative")
+	check(c.Store.ReapEvery >= 0, "store.reap_every can't be negative")
+	switch c.Generator.Name {
+	case "hash", "sequential":
+	case "random":
+		check(c.Generator.CodeLength > 0, "generator.code_length must be positive, got %d", c.Generator.CodeLength)
+	default:
+		check(false, "generator.name %q isn't one of hash, sequential or random", c.Generator.Name)
+	}
+	check(len(c.URLs.Schemes) > 0, "urls.schemes can't be empty")
+	check(c.Batch.Max > 0, "batch.max must be positive, got %d", c.Batch.Max)
+	check(c.Batch.Parallelism > 0, "batch.parallelism must be positive, got %d", c.Batch.Parallelism)
+	check(!c.Health.Enabled || c.Health.Every > 0, "health.every must be positive when health.enabled is set")
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration:\n\t" + strings.Join(problems, "\n\t"))
+	}
+	return nil
+}
+
+// print writes c to w as YAML that loadConfig reads back, redacting secrets.
+func (c *config) print(w io.Writer) error {
+	return c.settings().print(w)
+}
+
+// readConfigFile reads the settings in the YAML or JSON file at path, JSON
+// being told apart by its .json extension.
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var values map[string]string
+	if filepath.Ext(path) == ".json" {
+		values, err = parseJSON(data)
+	} else {
+		values, err = parseYAML(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return values, nil
+}
+
+// parseJSON flattens a JSON object into settings keyed like config's.
+func parseJSON(data []byte) (map[string]string, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc map[string]interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	values := make(map[string]string)
+	var flatten func(key string, v interface{}) error
+	flatten = func(key string, v interface{}) error {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for k, child := range v {
+				if key != "" {
+					k = key + "." + k
+				}
+				if err := flatten(k, child); err != nil {
+					return err
+				}
+			}
+		case []interface{}:
+			items := make([]string, len(v))
+			for i, item := range v {
+				s, ok := item.(string)
+				if !ok {
+					return fmt.Errorf("%s: lists can only hold strings", key)
+				}
+				items[i] = s
+			}
+			values[key] = strings.Join(items, ",")
+		case string:
+			values[key] = v
+		case json.Number:
+			values[key] = v.String()
+		case bool:
+			values[key] = strconv.FormatBool(v)
+		case nil:
+		default:
+			return fmt.Errorf("%s: unexpected %T", key, v)
+		}
+		return nil
+	}
+	if err := flatten("", doc); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// flagValue records the value a flag was set to so it can be applied after
+// the file and environment.
+type flagValue struct {
+	name     string
+	setting  *setting
+	def      string
+	value    string
+	isSet    bool
+	setFlags *[]*flagValue
+}
+
+func (f *flagValue) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.def
+}
+
+func (f *flagValue) Set(s string) error {
+	if !f.isSet {
+		*f.setFlags = append(*f.setFlags, f)
+		f.isSet = true
+	}
+	f.value = s
+	return nil
+}
+
+func (f *flagValue) IsBoolFlag() bool {
+	return f.setting.isBool()
+}
+
+// setting is a single field of a config.
+type setting struct {
+	key    string
+	value  reflect.Value
+	secret bool
+}
+
+type settings []*setting
+
+// settings lists every field of c, walking into its sections.
+func (c *config) settings() settings {
+	return settingsOf(c)
+}
+
+// settingsOf lists the fields of the struct ptr points to, walking into the
+// structs it holds. Fields tagged secret are redacted when printed.
+func settingsOf(ptr interface{}) settings {
+	var all settings
+	var walk func(prefix string, v reflect.Value)
+	walk = func(prefix string, v reflect.Value) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			key := prefix + f.Tag.Get("config")
+			if f.Type.Kind() == reflect.Struct {
+				walk(key+".", v.Field(i))
+				continue
+			}
+			_, secret := f.Tag.Lookup("secret")
+			all = append(all, &setting{key: key, value: v.Field(i), secret: secret})
+		}
+	}
+	walk("", reflect.ValueOf(ptr).Elem())
+	return all
+}
+
+// print writes ss to w as YAML, grouping settings by section.
+func (ss settings) print(w io.Writer) error {
+	var (
+		buf     bytes.Buffer
+		section string
+	)
+	for _, s := range ss {
+		indent := ""
+		if i := strings.Index(s.key, "."); i > 0 {
+			if s.key[:i] != section {
+				section = s.key[:i]
+				fmt.Fprintf(&buf, "%s:\n", section)
+			}
+			indent = "  "
+		} else {
+			section = ""
+		}
+		value := s.yaml()
+		if s.secret && !s.isZero() {
+			value = `"<redacted>"`
+		}
+		fmt.Fprintf(&buf, "%s%s: %s\n", indent, s.name(), value)
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// byEnv returns the setting read from the environment variable name.
+func (ss settings) byEnv(name string) *setting {
+	for _, s := range ss {
+		if envPrefix+strings.ToUpper(strings.Replace(s.key, ".", "_", -1)) == name {
+			return s
+		}
+	}
+	return nil
+}
+
+func (ss settings) get(key string) *setting {
+	for _, s := range ss {
+		if s.key == key {
+			return s
+		}
+	}
+	return nil
+}
+
+func (s *setting) name() string {
+	return s.key[strings.LastIndex(s.key, ".")+1:]
+}
+
+func (s *setting) isBool() bool {
+	return s.value.Kind() == reflect.Bool
+}
+
+func (s *setting) isZero() bool {
+	return reflect.DeepEqual(s.value.Interface(), reflect.Zero(s.value.Type()).Interface())
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func (s *setting) set(raw string) error {
+	raw = strings.TrimSpace(raw)
+	switch {
+	case s.value.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		s.value.SetInt(int64(d))
+	case s.value.Kind() == reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("%q isn't a number", raw)
+		}
+		s.value.SetInt(int64(n))
+	case s.value.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%q isn't true or false", raw)
+		}
+		s.value.SetBool(b)
+	case s.value.Kind() == reflect.String:
+		s.value.SetString(raw)
+	case s.value.Kind() == reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		s.value.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %v", s.value.Type())
+	}
+	return nil
+}
+
+// String formats s the way set parses it.
+func (s *setting) String() string {
+	switch v := s.value.Interface().(type) {
+	case []string:
+		return strings.Join(v, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// yaml formats s as a YAML value.
+func (s *setting) yaml() string {
+	switch v := s.value.Interface().(type) {
+	case string:
+		return yaml.String(v)
+	case []string:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = yaml.String(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	default:
+		return s.String()
+	}
+}
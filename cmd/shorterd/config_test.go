@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfig writes a config file named name to a temporary directory.
+func writeConfig(t *testing.T, name, content string) string {
+	dir, err := ioutil.TempDir("", "shorterd-config")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	return path
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	path := writeConfig(t, "shorterd.yaml", `
+listen:
+  http: ":1"
+  grpc: ":2"
+redirect: 301
+`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	cfg, _, err := loadConfig(
+		[]string{"-config", path, "-redirect", "308", "-sort-query"},
+		[]string{
+			"SHORTER_LISTEN_GRPC=:3", "SHORTER_REDIRECT=307", "HOME=/root",
+			// what Kubernetes injects for a service named shorter is ignored
+			"SHORTER_SERVICE_HOST=10.0.0.1", "SHORTER_PORT_8080_TCP=tcp://10.0.0.1:8080",
+		},
+	)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	want := defaultConfig()
+	want.Listen.HTTP = ":1"
+	want.Listen.GRPC = ":3"
+	want.Redirect = 308
+	want.URLs.SortQuery = true
+	if !reflect.DeepEqual(cfg, want) {
+		t.Logf("expected %+v, got %+v", want, cfg)
+		t.Fail()
+	}
+}
+
+func TestConfigFile(t *testing.T) {
+	want := defaultConfig()
+	want.BaseURL = "https://sho.rt"
+	want.Store.DataDir = "/var/lib/shorter"
+	want.Store.ReapEvery = 0
+	want.URLs.Schemes = []string{"https", "ftp"}
+	want.Health.Every = 1500 * time.Millisecond
+
+	files := map[string]string{
+		"shorterd.yaml": `
+# comments are skipped
+base_url: 'https://sho.rt' # so are trailing ones
+store:
+  data_dir: "/var/lib/shorter"
+  reap_every: 0s
+urls:
+  schemes:
+    - https
+    - ftp
+health:
+  every: 1.5s
+`,
+		"shorterd.json": `{
+	"base_url": "https://sho.rt",
+	"store": {"data_dir": "/var/lib/shorter", "reap_every": "0s"},
+	"urls": {"schemes": ["https", "ftp"]},
+	"health": {"every": "1.5s"}
+}`,
+	}
+	for name, content := range files {
+		path := writeConfig(t, name, content)
+		defer os.RemoveAll(filepath.Dir(path))
+
+		cfg, _, err := loadConfig(nil, []string{"SHORTER_CONFIG=" + path})
+		if err != nil {
+			t.Logf("%s: %v", name, err)
+			t.Fail()
+			continue
+		}
+		if !reflect.DeepEqual(cfg, want) {
+			t.Logf("%s: expected %+v, got %+v", name, want, cfg)
+			t.Fail()
+		}
+	}
+}
+
+func TestYAMLQuotedEscapes(t *testing.T) {
+	for line, want := range map[string]string{
+		`data_dir: "C:\\data"`:      `C:\data`,
+		`a: "x\\" # c`:              `x\`,
+		`a: "a # b\"c" # comment`:   `a # b"c`,
+		`a: 'it''s # not' # a note`: `it's # not`,
+	} {
+		values, err := parseYAML([]byte(line + "\n"))
+		if err != nil {
+			t.Logf("%s: %v", line, err)
+			t.Fail()
+			continue
+		}
+		for _, v := range values {
+			if v != want {
+				t.Logf("%s: expected %q, got %q", line, want, v)
+				t.Fail()
+			}
+		}
+		if len(values) != 1 {
+			t.Logf("%s: expected a single value, got %v", line, values)
+			t.Fail()
+		}
+	}
+}
+
+func TestConfigExample(t *testing.T) {
+	cfg, _, err := loadConfig([]string{"-config", "config.example.yaml"}, nil)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if !reflect.DeepEqual(cfg, defaultConfig()) {
+		t.Logf("expected the example to hold the defaults, got %+v", cfg)
+		t.Fail()
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		args    []string
+		environ []string
+		errs    []string
+	}{
+		{
+			name: "unknown setting",
+			file: "listen:\n  htp: :80\n",
+			errs: []string{`unknown setting "listen.htp"`},
+		},
+		{
+			name: "bad value",
+			args: []string{"-max-batch", "lots"},
+			errs: []string{`-max-batch: "lots" isn't a number`},
+		},
+		{
+			name: "bad indentation",
+			file: "listen:\n  http: :80\n   grpc: :81\n",
+			errs: []string{"line 3: bad indentation"},
+		},
+		{
+			name: "invalid",
+			args: []string{"-redirect", "200", "-generator", "short", "-client-ca", "ca.pem"},
+			environ: []string{
+				"SHORTER_BASE_URL=sho.rt",
+				"SHORTER_BATCH_MAX=0",
+			},
+			errs: []string{
+				"tls.client_ca needs tls.cert and tls.key",
+				`base_url "sho.rt" must be an absolute http or https URL`,
+				"redirect 200 isn't one of",
+				`generator.name "short" isn't one of`,
+				"batch.max must be positive",
+			},
+		},
+	}
+	for _, test := range tests {
+		args := test.args
+		if test.file != "" {
+			path := writeConfig(t, "shorterd.yaml", test.file)
+			defer os.RemoveAll(filepath.Dir(path))
+			args = append([]string{"-config", path}, args...)
+		}
+		_, _, err := loadConfig(args, test.environ)
+		if err == nil {
+			t.Logf("%s: expected an error", test.name)
+			t.Fail()
+			continue
+		}
+		for _, want := range test.errs {
+			if !strings.Contains(err.Error(), want) {
+				t.Logf("%s: expected %q in %q", test.name, want, err)
+				t.Fail()
+			}
+		}
+	}
+}
+
+func TestPrintConfig(t *testing.T) {
+	cfg, printConfig, err := loadConfig([]string{
+		"-print-config",
+		"-base-url", "https://sho.rt/s",
+		"-schemes", "https, ftp",
+		"-data-dir", "/tmp/#shorter",
+		"-drain-timeout", "1m30s",
+	}, nil)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if !printConfig {
+		t.Log("expected -print-config to be reported")
+		t.Fail()
+	}
+	var buf bytes.Buffer
+	if err := cfg.print(&buf); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	path := writeConfig(t, "printed.yaml", buf.String())
+	defer os.RemoveAll(filepath.Dir(path))
+
+	printed, _, err := loadConfig([]string{"-config", path}, nil)
+	if err != nil {
+		t.Logf("%v reading back:\n%s", err, buf.String())
+		t.FailNow()
+	}
+	if !reflect.DeepEqual(printed, cfg) {
+		t.Logf("expected %+v to read back, got %+v", cfg, printed)
+		t.Fail()
+	}
+}
+
+func TestPrintRedactsSecrets(t *testing.T) {
+	var s struct {
+		Auth struct {
+			User     string `config:"user"`
+			Password string `config:"password" secret:""`
+			Token    string `config:"token" secret:""`
+		} `config:"auth"`
+	}
+	s.Auth.User = "jenny"
+	s.Auth.Password = "hunter2"
+
+	var buf bytes.Buffer
+	if err := settingsOf(&s).print(&buf); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	want := "auth:\n  user: jenny\n  password: \"<redacted>\"\n  token: \"\"\n"
+	if buf.String() != want {
+		t.Logf("expected %q, got %q", want, buf.String())
+		t.Fail()
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	v1 "github.com/jennyservices/shorter/transport/v1"
+	"golang.org/x/net/http2"
+	"google.golang.org/grpc"
+)
+
+// server is a listener shorterd serves on.
+type server interface {
+	// Serve serves until Shutdown is called, after which it returns nil.
+	Serve() error
+
+	// Shutdown stops accepting connections and waits for the requests in
+	// flight to finish. Once ctx is done they're cut off, and ctx's error
+	// is returned.
+	Shutdown(ctx context.Context) error
+}
+
+// run serves on every server until one of them fails or ctx is done, then
+// shuts them all down, giving requests in flight drain to finish. It returns
+// the error the failing server returned, or the first error shutting down.
+func run(ctx context.Context, drain time.Duration, servers ...server) error {
+	errc := make(chan error, len(servers))
+	for _, s := range servers {
+		go func(s server) {
+			errc <- s.Serve()
+		}(s)
+	}
+
+	var err error
+	select {
+	case err = <-errc:
+		log.Printf("shutting down: %v", err)
+	case <-ctx.Done():
+		log.Println("shutting down")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), drain)
+	defer cancel()
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, s := range servers {
+		wg.Add(1)
+		go func(s server) {
+			defer wg.Done()
+			if serr := s.Shutdown(ctx); serr != nil {
+				mu.Lock()
+				if err == nil {
+					err = serr
+				}
+				mu.Unlock()
+			}
+		}(s)
+	}
+	wg.Wait()
+	return err
+}
+
+// notifyContext returns a context that's done once the process receives one
+// of sigs. After stop is called sigs are handled the default way again, so
+// a second signal kills a process stuck shutting down.
+func notifyContext(parent context.Context, sigs ...os.Signal) (ctx context.Context, stop func()) {
+	ctx, cancel := context.WithCancel(parent)
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, sigs...)
+	go func() {
+		select {
+		case sig := <-c:
+			log.Printf("received %v", sig)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(c)
+		cancel()
+	}
+}
+
+// grpcServer serves gRPC on its own listener.
+type grpcServer struct {
+	s *grpc.Server
+	l net.Listener
+}
+
+// listenGRPC listens on addr to serve shorterSvc and whatever opts add over
+// gRPC.
+func listenGRPC(shorterSvc v1.Shorter, addr string, opts ...grpcOption) (*grpcServer, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("GRPC server listening at %s\n", addr)
+	return &grpcServer{s: newGRPCServer(shorterSvc, opts...), l: listener}, nil
+}
+
+func (g *grpcServer) Serve() error {
+	if err := g.s.Serve(g.l); err != grpc.ErrServerStopped {
+		return err
+	}
+	return nil
+}
+
+func (g *grpcServer) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		g.s.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		g.s.Stop()
+		return ctx.Err()
+	}
+}
+
+// httpServer serves HTTP on its own listener.
+type httpServer struct {
+	s *http.Server
+	l net.Listener
+}
+
+// listenHTTP listens on addr to serve shorterSvc over HTTP, over TLS unless
+// tlsConfig is nil.
+func listenHTTP(shorterSvc v1.Shorter, addr string, redirect int, tlsConfig *tls.Config) (*httpServer, error) {
+	server := &http.Server{Handler: newHTTPHandler(shorterSvc, redirect)}
+	if tlsConfig != nil {
+		if err := http2.ConfigureServer(server, nil); err != nil {
+			return nil, err
+		}
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+	log.Printf("HTTP server listening at %s\n", addr)
+	return &httpServer{s: server, l: listener}, nil
+}
+
+func (h *httpServer) Serve() error {
+	if err := h.s.Serve(h.l); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+func (h *httpServer) Shutdown(ctx context.Context) error {
+	if err := h.s.Shutdown(ctx); err != nil {
+		h.s.Close()
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	pb "github.com/jennyservices/shorter/transport/pb"
+	v1 "github.com/jennyservices/shorter/transport/v1"
+	"github.com/phayes/freeport"
+	"google.golang.org/grpc"
+)
+
+// blockingShorter shortens URLs once release is closed, telling started
+// about every request it holds up.
+func blockingShorter(started chan<- struct{}, release <-chan struct{}) *mockShorter {
+	return &mockShorter{shorten: func(ctx context.Context, long v1.URL) (*v1.URL, error) {
+		started <- struct{}{}
+		select {
+		case <-release:
+			return &v1.URL{Addr: "short-" + long.Addr}, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}}
+}
+
+// listenBoth listens for gRPC and HTTP, on one port if single is set.
+func listenBoth(t *testing.T, svc v1.Shorter, single bool) (grpcAddr, httpAddr string, servers []server) {
+	ports, err := freeport.GetFreePorts(2)
+	if err != nil {
+		log.Fatal(err)
+	}
+	grpcAddr = fmt.Sprintf("localhost:%d", ports[0])
+	httpAddr = fmt.Sprintf("localhost:%d", ports[1])
+	if single {
+		s, err := listenSinglePort(svc, httpAddr, http.StatusFound, nil)
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+		return httpAddr, httpAddr, []server{s}
+	}
+	g, err := listenGRPC(svc, grpcAddr)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	h, err := listenHTTP(svc, httpAddr, http.StatusFound, nil)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	return grpcAddr, httpAddr, []server{g, h}
+}
+
+// shortenBoth shortens a URL over gRPC and another over HTTP, sending what
+// came back or the error to results.
+func shortenBoth(t *testing.T, grpcAddr, httpAddr string, results chan<- string) {
+	conn, err := grpc.Dial(grpcAddr, grpc.WithInsecure(), grpc.WithBlock(), grpc.WithTimeout(5*time.Second))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	go func() {
+		defer conn.Close()
+		u, err := pb.NewShorterClient(conn).Shorten(context.Background(), &pb.URL{Addr: "grpc"})
+		if err != nil {
+			results <- err.Error()
+			return
+		}
+		results <- u.Addr
+	}()
+	go func() {
+		resp, err := http.Post("http://"+httpAddr+"/shorten", "application/json", strings.NewReader(`{"addr": "http"}`))
+		if err != nil {
+			results <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		var u v1.URL
+		if err := json.NewDecoder(resp.Body).Decode(&u); err != nil {
+			results <- resp.Status
+			return
+		}
+		results <- u.Addr
+	}()
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	for _, single := range []bool{false, true} {
+		started, release := make(chan struct{}), make(chan struct{})
+		grpcAddr, httpAddr, servers := listenBoth(t, blockingShorter(started, release), single)
+
+		ctx, stop := notifyContext(context.Background(), syscall.SIGTERM)
+		done := make(chan error)
+		go func() {
+			done <- run(ctx, 5*time.Second, servers...)
+		}()
+
+		results := make(chan string, 2)
+		shortenBoth(t, grpcAddr, httpAddr, results)
+		<-started
+		<-started
+
+		syscall.Kill(os.Getpid(), syscall.SIGTERM)
+
+		// new connections are refused while the requests in flight finish
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			conn, err := net.Dial("tcp", httpAddr)
+			if err != nil {
+				break
+			}
+			conn.Close()
+			if time.Now().After(deadline) {
+				t.Logf("single port %v: still accepting connections after SIGTERM", single)
+				t.FailNow()
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		close(release)
+
+		got := map[string]bool{<-results: true, <-results: true}
+		if !got["short-grpc"] || !got["short-http"] {
+			t.Logf("single port %v: expected both requests to finish, got %v", single, got)
+			t.Fail()
+		}
+		if err := <-done; err != nil {
+			t.Logf("single port %v: expected a clean shutdown, got %v", single, err)
+			t.Fail()
+		}
+		stop()
+	}
+}
+
+func TestShutdownDrainTimeout(t *testing.T) {
+	for _, single := range []bool{false, true} {
+		started := make(chan struct{})
+		grpcAddr, httpAddr, servers := listenBoth(t, blockingShorter(started, nil), single)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() {
+			done <- run(ctx, 100*time.Millisecond, servers...)
+		}()
+
+		results := make(chan string, 2)
+		shortenBoth(t, grpcAddr, httpAddr, results)
+		<-started
+		<-started
+		cancel()
+
+		if err := <-done; err != context.DeadlineExceeded {
+			t.Logf("single port %v: expected the drain to time out, got %v", single, err)
+			t.Fail()
+		}
+		for i := 0; i < 2; i++ {
+			if r := <-results; strings.HasPrefix(r, "short-") {
+				t.Logf("single port %v: expected requests to be cut off, got %v", single, r)
+				t.Fail()
+			}
+		}
+	}
+}
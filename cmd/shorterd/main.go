@@ -1,53 +1,275 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"flag"
+	"fmt"
+	"io"
 	"log"
-	"net"
-	"net/http"
+	"os"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/jennyservices/shorter/shorter"
+	"github.com/jennyservices/shorter/transport/health"
 	pb "github.com/jennyservices/shorter/transport/pb"
+	"github.com/jennyservices/shorter/transport/reflection"
 	v1 "github.com/jennyservices/shorter/transport/v1"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 func main() {
-	var (
-		addr     = flag.String("addr", ":8080", "default -addr :8080")
-		gRPCAddr = flag.String("grpc", ":8081", "gRPC listen address")
+	cfg, printConfig, err := loadConfig(os.Args[1:], os.Environ())
+	if err == flag.ErrHelp {
+		os.Exit(0)
+	} else if err != nil {
+		log.Fatal(err)
+	}
+	if printConfig {
+		if err := cfg.print(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	store, err := newStore(cfg.Store.DataDir, cfg.Store.CompactEvery)
+	if err != nil {
+		log.Fatal(err)
+	}
+	generator, err := newGenerator(cfg.Generator.Name, cfg.Generator.CodeLength, store)
+	if err != nil {
+		log.Fatal(err)
+	}
+	normalizer := &shorter.Normalizer{
+		Schemes:   cfg.URLs.Schemes,
+		SortQuery: cfg.URLs.SortQuery,
+	}
+	shorterSvc := shorter.New(store,
+		shorter.WithGenerator(generator),
+		shorter.WithNormalizer(normalizer),
+		shorter.WithMaxBatch(cfg.Batch.Max),
+		shorter.WithBatchParallelism(cfg.Batch.Parallelism),
+		shorter.WithBaseURL(cfg.BaseURL),
 	)
-	flag.Parse()
-	shorterSvc := shorter.New()
 
-	errChan := make(chan error)
+	ctx, stop := notifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// background workers run until the servers are done with the store
+	workCtx, stopWork := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	goWork := func(f func(context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			f(workCtx)
+		}()
+	}
+
+	if cfg.Store.ReapEvery > 0 {
+		goWork(func(ctx context.Context) { startReaper(ctx, shorterSvc, cfg.Store.ReapEvery) })
+	}
+
+	var (
+		grpcOpts  []grpcOption
+		httpTLS   *tls.Config
+		singleTLS *tls.Config
+	)
+	if cfg.TLS.Cert != "" {
+		certs, err := newCertReloader(cfg.TLS.Cert, cfg.TLS.Key, cfg.TLS.ClientCA)
+		if err != nil {
+			log.Fatal(err)
+		}
+		goWork(func(ctx context.Context) { certs.watch(ctx, cfg.TLS.ReloadEvery) })
+		grpcOpts = append(grpcOpts, withTLS(certs.config("h2")))
+		httpTLS = certs.config("h2", "http/1.1")
+		singleTLS = httpTLS
+	}
+	if cfg.Health.Enabled {
+		h := health.NewServer()
+		goWork(func(ctx context.Context) { watchReadiness(ctx, store, h, cfg.Health.Every) })
+		grpcOpts = append(grpcOpts, withHealth(h))
+	}
+	if cfg.Reflection.Enabled {
+		grpcOpts = append(grpcOpts, withReflection())
+	}
 
-	//execute grpc server
-	go startGRPCServer(shorterSvc, *gRPCAddr, errChan)
-	go startHTTPServer(shorterSvc, *addr, errChan)
+	var servers []server
+	if cfg.Listen.SinglePort {
+		s, err := listenSinglePort(shorterSvc, cfg.Listen.HTTP, cfg.Redirect, singleTLS, grpcOpts...)
+		if err != nil {
+			log.Fatal(err)
+		}
+		servers = append(servers, s)
+	} else {
+		g, err := listenGRPC(shorterSvc, cfg.Listen.GRPC, grpcOpts...)
+		if err != nil {
+			log.Fatal(err)
+		}
+		h, err := listenHTTP(shorterSvc, cfg.Listen.HTTP, cfg.Redirect, httpTLS)
+		if err != nil {
+			log.Fatal(err)
+		}
+		servers = append(servers, g, h)
+	}
 
-	select {
-	case err := <-errChan:
+	err = run(ctx, cfg.Listen.DrainTimeout, servers...)
+	stop()
+	stopWork()
+	workers.Wait()
+	if c, ok := store.(io.Closer); ok {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
 		log.Fatal(err)
 	}
+	log.Println("shut down cleanly")
 }
 
-func startGRPCServer(shorterSvc v1.Shorter, addr string, errChan chan error) {
-	shorterGRPCServer := v1.NewShorterGRPCServer(shorterSvc)
-	listener, err := net.Listen("tcp", addr)
-	if err != nil {
-		errChan <- err
+// shorterService is the name the Shorter service is registered under, and
+// reported under by the health service.
+const shorterService = "pb.Shorter"
+
+// grpcConfig is what grpcOptions configure the gRPC server with.
+type grpcConfig struct {
+	serverOpts []grpc.ServerOption
+	register   []func(*grpc.Server)
+}
+
+// grpcOption configures the gRPC server.
+type grpcOption func(*grpcConfig)
+
+// withHealth serves h as grpc.health.v1.Health.
+func withHealth(h *health.Server) grpcOption {
+	return func(c *grpcConfig) {
+		c.register = append(c.register, func(s *grpc.Server) {
+			health.RegisterHealthServer(s, h)
+		})
+	}
+}
+
+// withReflection serves gRPC server reflection.
+func withReflection() grpcOption {
+	return func(c *grpcConfig) {
+		c.register = append(c.register, reflection.Register)
+	}
+}
+
+// withTLS serves gRPC over TLS. It has no effect in single port mode, where
+// the listener does TLS.
+func withTLS(cfg *tls.Config) grpcOption {
+	return func(c *grpcConfig) {
+		c.serverOpts = append(c.serverOpts, grpc.Creds(credentials.NewTLS(cfg)))
+	}
+}
+
+// newGRPCServer returns a gRPC server with the Shorter service and whatever
+// opts add registered.
+func newGRPCServer(shorterSvc v1.Shorter, opts ...grpcOption) *grpc.Server {
+	c := &grpcConfig{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	gRPCServer := grpc.NewServer(c.serverOpts...)
+	pb.RegisterShorterServer(gRPCServer, v1.NewShorterGRPCServer(shorterSvc))
+	for _, register := range c.register {
+		register(gRPCServer)
+	}
+	return gRPCServer
+}
+
+// reaper purges expired links, the shorter service implements it.
+type reaper interface {
+	Reap(ctx context.Context) (int, error)
+}
+
+func startReaper(ctx context.Context, r reaper, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		n, err := r.Reap(ctx)
+		if err != nil {
+			log.Printf("reaping expired links: %v", err)
+		}
+		if n > 0 {
+			log.Printf("reaped %d expired links", n)
+		}
+	}
+}
+
+// watchReadiness reports the server and the Shorter service as SERVING while
+// store answers pings, and NOT_SERVING while it doesn't. Stores that can't be
+// pinged are always ready.
+func watchReadiness(ctx context.Context, store shorter.Store, h *health.Server, every time.Duration) {
+	pinger, ok := store.(shorter.Pinger)
+	ready := true
+	check := func() {
+		if ok {
+			pingCtx, cancel := context.WithTimeout(ctx, time.Second)
+			err := pinger.Ping(pingCtx)
+			cancel()
+			if err != nil && ready {
+				log.Printf("store isn't ready: %v", err)
+			} else if err == nil && !ready {
+				log.Println("store is ready again")
+			}
+			ready = err == nil
+		}
+		st := health.HealthCheckResponse_SERVING
+		if !ready {
+			st = health.HealthCheckResponse_NOT_SERVING
+		}
+		h.SetServingStatus("", st)
+		h.SetServingStatus(shorterService, st)
+	}
+
+	check()
+	if !ok || every <= 0 {
 		return
 	}
-	gRPCServer := grpc.NewServer()
-	pb.RegisterShorterServer(gRPCServer, shorterGRPCServer)
-	log.Printf("GRPC server listening at %s\n", addr)
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			check()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
 
-	errChan <- gRPCServer.Serve(listener)
+func newStore(dataDir string, compactEvery time.Duration) (shorter.Store, error) {
+	if dataDir == "" {
+		return shorter.NewMemoryStore(), nil
+	}
+	return shorter.NewFileStore(dataDir, compactEvery)
 }
 
-func startHTTPServer(shorterSvc v1.Shorter, addr string, errChan chan error) {
-	shorterHTTPServer := v1.NewShorterHTTPServer(shorterSvc)
-	log.Printf("HTTP server listening at %s\n", addr)
-	errChan <- http.ListenAndServe(addr, shorterHTTPServer)
-}
\ No newline at end of file
+func newGenerator(name string, length int, store shorter.Store) (shorter.Generator, error) {
+	switch name {
+	case "hash":
+		return shorter.NewHashGenerator(), nil
+	case "sequential":
+		counter, ok := store.(shorter.Counter)
+		if !ok {
+			return nil, fmt.Errorf("-generator sequential needs a store that keeps a counter")
+		}
+		return shorter.NewSequentialGenerator(counter), nil
+	case "random":
+		if length < 1 {
+			return nil, fmt.Errorf("-code-length must be positive, got %d", length)
+		}
+		return shorter.NewRandomGenerator(length), nil
+	}
+	return nil, fmt.Errorf("unknown -generator %q, expected hash, sequential or random", name)
+}
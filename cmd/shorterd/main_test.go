@@ -3,39 +3,111 @@ package main
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/jennyservices/shorter/shorter"
+	"github.com/jennyservices/shorter/transport/health"
 	pb "github.com/jennyservices/shorter/transport/pb"
+	"github.com/jennyservices/shorter/transport/reflection"
 
 	v1 "github.com/jennyservices/shorter/transport/v1"
 	"github.com/phayes/freeport"
+	"golang.org/x/net/http2"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
-//  e2e Tests :)
+// e2e Tests :)
 type mockShorter struct {
 	shorten func(ctx context.Context, Long v1.URL) (Body *v1.URL, err error)
+	expand  func(ctx context.Context, Code string) (Body *v1.URL, err error)
+	batch   func(ctx context.Context, Longs []v1.URL) (Body []v1.BatchResult, err error)
+	info    func(ctx context.Context, Code string) (Body *v1.Link, err error)
+	list    func(ctx context.Context, After string, Limit int) (Body *v1.LinkPage, err error)
+	update  func(ctx context.Context, Code string, Changes v1.URL) (Body *v1.Link, err error)
+	delete  func(ctx context.Context, Code string) (Body *v1.Link, err error)
 }
 
 func (s *mockShorter) Shorten(ctx context.Context, Long v1.URL) (Body *v1.URL, err error) {
 	return s.shorten(ctx, Long)
 }
 
+func (s *mockShorter) Expand(ctx context.Context, Code string) (Body *v1.URL, err error) {
+	return s.expand(ctx, Code)
+}
+
+func (s *mockShorter) ShortenBatch(ctx context.Context, Longs []v1.URL) (Body []v1.BatchResult, err error) {
+	return s.batch(ctx, Longs)
+}
+
+func (s *mockShorter) Info(ctx context.Context, Code string) (Body *v1.Link, err error) {
+	return s.info(ctx, Code)
+}
+
+func (s *mockShorter) List(ctx context.Context, After string, Limit int) (Body *v1.LinkPage, err error) {
+	return s.list(ctx, After, Limit)
+}
+
+func (s *mockShorter) Update(ctx context.Context, Code string, Changes v1.URL) (Body *v1.Link, err error) {
+	return s.update(ctx, Code, Changes)
+}
+
+func (s *mockShorter) Delete(ctx context.Context, Code string) (Body *v1.Link, err error) {
+	return s.delete(ctx, Code)
+}
+
 const (
 	request  = "hello"
 	response = "goodbye"
 )
 
+// serveGRPC serves shorterSvc over gRPC on addr for the rest of the test.
+func serveGRPC(t *testing.T, shorterSvc v1.Shorter, addr string, opts ...grpcOption) {
+	s, err := listenGRPC(shorterSvc, addr, opts...)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	go s.Serve()
+}
+
+// serveHTTP serves shorterSvc over HTTP on addr for the rest of the test.
+func serveHTTP(t *testing.T, shorterSvc v1.Shorter, addr string, redirect int, tlsConfig *tls.Config) {
+	s, err := listenHTTP(shorterSvc, addr, redirect, tlsConfig)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	go s.Serve()
+}
+
+// serveSinglePort serves shorterSvc over HTTP and gRPC on addr for the rest
+// of the test.
+func serveSinglePort(t *testing.T, shorterSvc v1.Shorter, addr string, redirect int, tlsConfig *tls.Config, opts ...grpcOption) {
+	s, err := listenSinglePort(shorterSvc, addr, redirect, tlsConfig, opts...)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	go s.Serve()
+}
+
 // This test here is to test only that the gRPC server is working and nothing more.
 func TestGRPC(t *testing.T) {
-	errChan := make(chan error)
 	port, err := freeport.GetFreePort()
 	if err != nil {
 		log.Fatal(err)
@@ -51,18 +123,449 @@ func TestGRPC(t *testing.T) {
 
 	grpcAddr := fmt.Sprintf(":%d", port)
 	// port is ready to listen on
-	go startGRPCServer(&mockShorter{shorten: shortenFunc}, grpcAddr, errChan)
+	serveGRPC(t, &mockShorter{shorten: shortenFunc}, grpcAddr)
 
-	conn, err := grpc.Dial(grpcAddr, grpc.WithInsecure(), grpc.WithTimeout(1*time.Second))
+	conn, err := grpc.Dial(grpcAddr, grpc.WithInsecure(), grpc.WithBlock(), grpc.WithTimeout(5*time.Second))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
 	defer conn.Close()
 
 	client := pb.NewShorterClient(conn)
 	resp, err := client.Shorten(context.Background(), &pb.URL{Addr: request})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
 	if resp.Addr != response {
 		t.Fail()
 	}
 }
 
+func TestGRPCExpandNotFound(t *testing.T) {
+	port, err := freeport.GetFreePort()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	expandFunc := func(ctx context.Context, code string) (Body *v1.URL, err error) {
+		return nil, shorter.NotFound{Code: code}
+	}
+
+	grpcAddr := fmt.Sprintf(":%d", port)
+	serveGRPC(t, &mockShorter{expand: expandFunc}, grpcAddr)
+
+	conn, err := grpc.Dial(grpcAddr, grpc.WithInsecure(), grpc.WithBlock(), grpc.WithTimeout(5*time.Second))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	defer conn.Close()
+
+	client := pb.NewShorterClient(conn)
+	_, err = client.Expand(context.Background(), &pb.Code{Code: request})
+	if status.Code(err) != codes.NotFound {
+		t.Logf("expected codes.NotFound, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestGRPCShortenErrors(t *testing.T) {
+	port, err := freeport.GetFreePort()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	shortenFunc := func(ctx context.Context, long v1.URL) (Body *v1.URL, err error) {
+		if long.Addr == request {
+			return nil, errors.New("whooops")
+		}
+		return nil, shorter.InvalidURL{URL: long.Addr, Reason: "it has no scheme"}
+	}
+
+	grpcAddr := fmt.Sprintf(":%d", port)
+	serveGRPC(t, &mockShorter{shorten: shortenFunc}, grpcAddr)
+
+	conn, err := grpc.Dial(grpcAddr, grpc.WithInsecure(), grpc.WithBlock(), grpc.WithTimeout(5*time.Second))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	defer conn.Close()
+
+	client := pb.NewShorterClient(conn)
+	_, err = client.Shorten(context.Background(), &pb.URL{Addr: request})
+	if status.Code(err) != codes.Internal {
+		t.Logf("expected codes.Internal, got %v", err)
+		t.Fail()
+	}
+
+	_, err = client.Shorten(context.Background(), &pb.URL{Addr: "example.com"})
+	st, _ := status.FromError(err)
+	if st.Code() != codes.InvalidArgument {
+		t.Logf("expected codes.InvalidArgument, got %v", err)
+		t.FailNow()
+	}
+	details := st.Details()
+	if len(details) != 1 {
+		t.Logf("expected a field violation, got %v", details)
+		t.FailNow()
+	}
+	if br, ok := details[0].(*pb.BadRequest); !ok || br.FieldViolations[0].Field != "addr" {
+		t.Logf("expected a field violation on addr, got %v", details[0])
+		t.Fail()
+	}
+}
+
+func TestHTTPErrorBody(t *testing.T) {
+	shortenFunc := func(ctx context.Context, long v1.URL) (Body *v1.URL, err error) {
+		return nil, shorter.Invalid{Field: "alias", Reason: "is reserved"}
+	}
+	ts := httptest.NewServer(newHTTPHandler(&mockShorter{shorten: shortenFunc}, http.StatusFound))
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+"/shorten", "application/json", bytes.NewBufferString(`{"addr": "hello", "alias": "shorten"}`))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Logf("expected 400, got %d", resp.StatusCode)
+		t.Fail()
+	}
+	var body v1.Error
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if len(body.FieldViolations) != 1 || body.FieldViolations[0].Field != "alias" {
+		t.Logf("expected a field violation on alias, got %+v", body)
+		t.Fail()
+	}
+}
+
+func TestGRPCShortenBatch(t *testing.T) {
+	port, err := freeport.GetFreePort()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	batchFunc := func(ctx context.Context, longs []v1.URL) (Body []v1.BatchResult, err error) {
+		results := make([]v1.BatchResult, len(longs))
+		for i, long := range longs {
+			if long.Addr == request {
+				results[i].URL = &v1.URL{Addr: response}
+				continue
+			}
+			results[i].Error = &v1.Error{
+				Status:          http.StatusBadRequest,
+				Message:         "no scheme",
+				FieldViolations: []v1.FieldViolation{{Field: "addr", Description: "no scheme"}},
+			}
+		}
+		return results, nil
+	}
+
+	grpcAddr := fmt.Sprintf(":%d", port)
+	serveGRPC(t, &mockShorter{batch: batchFunc}, grpcAddr)
+
+	conn, err := grpc.Dial(grpcAddr, grpc.WithInsecure(), grpc.WithBlock(), grpc.WithTimeout(5*time.Second))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	defer conn.Close()
+
+	client := pb.NewShorterClient(conn)
+	resp, err := client.ShortenBatch(context.Background(), &pb.Batch{Urls: []*pb.URL{{Addr: request}, {Addr: "example.com"}}})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if len(resp.Results) != 2 {
+		t.Logf("expected 2 results, got %v", resp.Results)
+		t.FailNow()
+	}
+	if resp.Results[0].Url.GetAddr() != response || resp.Results[0].Error != nil {
+		t.Logf("expected the first URL to be shortened, got %v", resp.Results[0])
+		t.Fail()
+	}
+	if e := resp.Results[1].Error; e == nil || codes.Code(e.Code) != codes.InvalidArgument || len(e.FieldViolations) != 1 {
+		t.Logf("expected the second URL to be invalid, got %v", resp.Results[1])
+		t.Fail()
+	}
+}
+
+func TestGRPCShortenStream(t *testing.T) {
+	port, err := freeport.GetFreePort()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	shortenFunc := func(ctx context.Context, long v1.URL) (Body *v1.URL, err error) {
+		if strings.HasPrefix(long.Addr, "bad") {
+			return nil, shorter.InvalidURL{URL: long.Addr, Reason: "it has no scheme"}
+		}
+		return &v1.URL{Addr: "short-" + long.Addr}, nil
+	}
+
+	grpcAddr := fmt.Sprintf(":%d", port)
+	serveGRPC(t, &mockShorter{shorten: shortenFunc}, grpcAddr)
+
+	conn, err := grpc.Dial(grpcAddr, grpc.WithInsecure(), grpc.WithBlock(), grpc.WithTimeout(5*time.Second))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	defer conn.Close()
+
+	stream, err := pb.NewShorterClient(conn).ShortenStream(context.Background())
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	const n = 200
+	go func() {
+		for id := uint64(1); id <= n; id++ {
+			addr := fmt.Sprintf("good%d", id)
+			if id%10 == 0 {
+				addr = fmt.Sprintf("bad%d", id)
+			}
+			stream.Send(&pb.StreamRequest{Id: id, Url: &pb.URL{Addr: addr}})
+		}
+		stream.CloseSend()
+	}()
+
+	seen := make(map[uint64]bool)
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+		seen[res.Id] = true
+		if res.Id%10 == 0 {
+			if res.Error == nil || codes.Code(res.Error.Code) != codes.InvalidArgument || len(res.Error.FieldViolations) != 1 {
+				t.Logf("%d: expected an invalid argument error, got %v", res.Id, res)
+				t.Fail()
+			}
+			continue
+		}
+		if want := fmt.Sprintf("short-good%d", res.Id); res.Url.GetAddr() != want {
+			t.Logf("%d: expected %q, got %v", res.Id, want, res)
+			t.Fail()
+		}
+	}
+	if len(seen) != n {
+		t.Logf("expected %d results, got %d", n, len(seen))
+		t.Fail()
+	}
+}
+
+func TestGRPCHealth(t *testing.T) {
+	port, err := freeport.GetFreePort()
+	if err != nil {
+		log.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "shorterd")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	store, err := shorter.NewFileStore(dir, 0)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	h := health.NewServer()
+	go watchReadiness(context.Background(), store, h, 10*time.Millisecond)
+
+	grpcAddr := fmt.Sprintf(":%d", port)
+	serveGRPC(t, &mockShorter{}, grpcAddr, withHealth(h))
+
+	conn, err := grpc.Dial(grpcAddr, grpc.WithInsecure(), grpc.WithBlock(), grpc.WithTimeout(5*time.Second))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	defer conn.Close()
+	client := health.NewHealthClient(conn)
+
+	_, err = client.Check(context.Background(), &health.HealthCheckRequest{Service: "nope"})
+	if status.Code(err) != codes.NotFound {
+		t.Logf("expected NotFound for an unknown service, got %v", err)
+		t.Fail()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	watch, err := client.Watch(ctx, &health.HealthCheckRequest{Service: shorterService})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	// the first status may arrive before the store was first pinged
+	for {
+		res, err := watch.Recv()
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+		if res.Status == health.HealthCheckResponse_SERVING {
+			break
+		}
+	}
+
+	store.Close()
+	res, err := watch.Recv()
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if res.Status != health.HealthCheckResponse_NOT_SERVING {
+		t.Logf("expected NOT_SERVING once the store is closed, got %v", res.Status)
+		t.Fail()
+	}
+	res, err = client.Check(context.Background(), &health.HealthCheckRequest{})
+	if err != nil || res.Status != health.HealthCheckResponse_NOT_SERVING {
+		t.Logf("expected the server to be NOT_SERVING, got %v, %v", res, err)
+		t.Fail()
+	}
+}
+
+func TestGRPCReflection(t *testing.T) {
+	port, err := freeport.GetFreePort()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	grpcAddr := fmt.Sprintf(":%d", port)
+	serveGRPC(t, &mockShorter{}, grpcAddr, withHealth(health.NewServer()), withReflection())
+
+	conn, err := grpc.Dial(grpcAddr, grpc.WithInsecure(), grpc.WithBlock(), grpc.WithTimeout(5*time.Second))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	defer conn.Close()
+
+	stream, err := conn.NewStream(context.Background(),
+		&grpc.StreamDesc{ServerStreams: true, ClientStreams: true},
+		"/"+reflection.ServiceName+"/ServerReflectionInfo")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	ask := func(req *reflection.ServerReflectionRequest) *reflection.ServerReflectionResponse {
+		if err := stream.SendMsg(req); err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+		res := new(reflection.ServerReflectionResponse)
+		if err := stream.RecvMsg(res); err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+		return res
+	}
+
+	res := ask(&reflection.ServerReflectionRequest{ListServices: "*"})
+	services := make(map[string]bool)
+	for _, s := range res.ListServicesResponse.Service {
+		services[s.Name] = true
+	}
+	for _, want := range []string{shorterService, "grpc.health.v1.Health", reflection.ServiceName} {
+		if !services[want] {
+			t.Logf("expected %s to be listed, got %v", want, services)
+			t.Fail()
+		}
+	}
+
+	res = ask(&reflection.ServerReflectionRequest{FileContainingSymbol: shorterService + ".ShortenBatch"})
+	if res.FileDescriptorResponse == nil || len(res.FileDescriptorResponse.FileDescriptorProto) < 2 {
+		t.Logf("expected shorter.proto and its imports, got %v", res)
+		t.FailNow()
+	}
+
+	// shorter.proto and its imports were already sent on this stream
+	res = ask(&reflection.ServerReflectionRequest{FileContainingSymbol: "pb.Error"})
+	if res.FileDescriptorResponse == nil || len(res.FileDescriptorResponse.FileDescriptorProto) != 1 {
+		t.Logf("expected only shorter.proto, got %v", res)
+		t.Fail()
+	}
+
+	res = ask(&reflection.ServerReflectionRequest{FileContainingSymbol: "pb.Nope"})
+	if res.ErrorResponse == nil || codes.Code(res.ErrorResponse.ErrorCode) != codes.NotFound {
+		t.Logf("expected NotFound, got %v", res)
+		t.Fail()
+	}
+	stream.CloseSend()
+}
+
+func TestSinglePort(t *testing.T) {
+	port, err := freeport.GetFreePort()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	shortenFunc := func(ctx context.Context, long v1.URL) (Body *v1.URL, err error) {
+		return &v1.URL{Addr: "short-" + long.Addr}, nil
+	}
+	addr := fmt.Sprintf("localhost:%d", port)
+	serveSinglePort(t, &mockShorter{shorten: shortenFunc}, addr, http.StatusFound, nil, withHealth(health.NewServer()))
+
+	conn, err := grpc.Dial(addr, grpc.WithInsecure(), grpc.WithBlock(), grpc.WithTimeout(5*time.Second))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	defer conn.Close()
+	u, err := pb.NewShorterClient(conn).Shorten(context.Background(), &pb.URL{Addr: "grpc"})
+	if err != nil || u.Addr != "short-grpc" {
+		t.Logf("gRPC: expected short-grpc, got %v, %v", u, err)
+		t.Fail()
+	}
+	hc, err := health.NewHealthClient(conn).Check(context.Background(), &health.HealthCheckRequest{})
+	if err != nil || hc.Status != health.HealthCheckResponse_SERVING {
+		t.Logf("gRPC: expected SERVING, got %v, %v", hc, err)
+		t.Fail()
+	}
+
+	h2c := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}}
+	for _, client := range []*http.Client{http.DefaultClient, h2c} {
+		resp, err := client.Post("http://"+addr+"/shorten", "application/json", strings.NewReader(`{"addr": "http"}`))
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+		var u v1.URL
+		json.NewDecoder(resp.Body).Decode(&u)
+		resp.Body.Close()
+		if u.Addr != "short-http" {
+			t.Logf("%s: expected short-http, got %+v", resp.Proto, u)
+			t.Fail()
+		}
+		if client == h2c && resp.ProtoMajor != 2 {
+			t.Logf("expected HTTP/2, got %s", resp.Proto)
+			t.Fail()
+		}
+	}
+}
+
 func TestHTTPWorks(t *testing.T) {
 	shortenFunc := func(ctx context.Context, long v1.URL) (Body *v1.URL, err error) {
 		if long.Addr != request {
@@ -138,3 +641,178 @@ func TestHTTPWrongContentType(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+func TestHTTPContentNegotiation(t *testing.T) {
+	shortenFunc := func(ctx context.Context, long v1.URL) (Body *v1.URL, err error) {
+		if long.Addr != request {
+			return nil, errors.New("whooops")
+		}
+		return &v1.URL{Addr: response}, nil
+	}
+	expandFunc := func(ctx context.Context, code string) (Body *v1.URL, err error) {
+		return &v1.URL{Addr: "https://example.com/" + code}, nil
+	}
+	ts := httptest.NewServer(v1.NewShorterHTTPServer(&mockShorter{shorten: shortenFunc, expand: expandFunc}))
+	defer ts.Close()
+
+	tests := []struct {
+		method, path         string
+		contentType, accept  string
+		body                 string
+		status               int
+		respType, respSubstr string
+	}{
+		{http.MethodPost, "/shorten", "application/json; charset=utf-8", "", `{"addr": "hello"}`, http.StatusOK, "application/json", `"addr":"goodbye"`},
+		{http.MethodPost, "/shorten", "application/x-www-form-urlencoded", "", "addr=hello", http.StatusOK, "application/json", `"addr":"goodbye"`},
+		{http.MethodPost, "/shorten", "application/json", "application/xml", `{"addr": "hello"}`, http.StatusOK, "application/xml", "<Addr>goodbye</Addr>"},
+		{http.MethodPost, "/shorten", "text/plain", "", "hello", http.StatusUnsupportedMediaType, "", ""},
+		{http.MethodPost, "/shorten", "", "", `{"addr": "hello"}`, http.StatusUnsupportedMediaType, "", ""},
+		{http.MethodGet, "/expand/abc", "", "*/*", "", http.StatusOK, "application/json", `"addr":"https://example.com/abc"`},
+	}
+	for _, test := range tests {
+		req, err := http.NewRequest(test.method, ts.URL+test.path, bytes.NewBufferString(test.body))
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+		if test.contentType != "" {
+			req.Header.Set("Content-Type", test.contentType)
+		}
+		if test.accept != "" {
+			req.Header.Set("Accept", test.accept)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		name := test.method + " " + test.path + " " + test.contentType
+		if resp.StatusCode != test.status {
+			t.Logf("%s: expected status %d, got %d: %s", name, test.status, resp.StatusCode, body)
+			t.Fail()
+			continue
+		}
+		if test.respType == "" {
+			continue
+		}
+		if got := resp.Header.Get("Content-Type"); got != test.respType {
+			t.Logf("%s: expected Content-Type %q, got %q", name, test.respType, got)
+			t.Fail()
+		}
+		if !strings.Contains(string(body), test.respSubstr) {
+			t.Logf("%s: expected body to contain %q, got %s", name, test.respSubstr, body)
+			t.Fail()
+		}
+	}
+}
+
+func TestRedirect(t *testing.T) {
+	expandFunc := func(ctx context.Context, code string) (Body *v1.URL, err error) {
+		switch code {
+		case "default":
+			return &v1.URL{Addr: "https://example.com/default"}, nil
+		case "permanent":
+			return &v1.URL{Addr: "https://example.com/permanent", Redirect: http.StatusPermanentRedirect}, nil
+		case "expired":
+			return nil, shorter.Gone{Code: code}
+		}
+		return nil, shorter.NotFound{Code: code}
+	}
+	listFunc := func(ctx context.Context, after string, limit int) (Body *v1.LinkPage, err error) {
+		return &v1.LinkPage{Links: []v1.Link{}}, nil
+	}
+	ts := httptest.NewServer(newHTTPHandler(&mockShorter{expand: expandFunc, list: listFunc}, http.StatusFound))
+	defer ts.Close()
+
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	for _, tc := range []struct {
+		method   string
+		path     string
+		status   int
+		location string
+	}{
+		{http.MethodGet, "/default", http.StatusFound, "https://example.com/default"},
+		{http.MethodHead, "/default", http.StatusFound, "https://example.com/default"},
+		{http.MethodGet, "/permanent", http.StatusPermanentRedirect, "https://example.com/permanent"},
+		{http.MethodGet, "/missing", http.StatusNotFound, ""},
+		{http.MethodHead, "/missing", http.StatusNotFound, ""},
+		{http.MethodGet, "/expired", http.StatusGone, ""},
+		// not a code, the API's list of links
+		{http.MethodGet, "/links", http.StatusOK, ""},
+	} {
+		req, err := http.NewRequest(tc.method, ts.URL+tc.path, nil)
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != tc.status {
+			t.Logf("%s %s: expected %d, got %d", tc.method, tc.path, tc.status, resp.StatusCode)
+			t.Fail()
+		}
+		if loc := resp.Header.Get("Location"); loc != tc.location {
+			t.Logf("%s %s: expected Location %q, got %q", tc.method, tc.path, tc.location, loc)
+			t.Fail()
+		}
+	}
+}
+
+func TestRedirectHead(t *testing.T) {
+	store := shorter.NewMemoryStore()
+	svc := shorter.New(store)
+	ctx := context.Background()
+	store.Put(ctx, shorter.Link{Code: "once", URL: "https://example.com/", MaxClicks: 1})
+	ts := httptest.NewServer(newHTTPHandler(svc, http.StatusFound))
+	defer ts.Close()
+
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	for _, tc := range []struct {
+		method string
+		status int
+	}{
+		// previews don't use up the only click
+		{http.MethodHead, http.StatusFound},
+		{http.MethodHead, http.StatusFound},
+		{http.MethodGet, http.StatusFound},
+		{http.MethodHead, http.StatusGone},
+		{http.MethodGet, http.StatusGone},
+	} {
+		req, err := http.NewRequest(tc.method, ts.URL+"/once", nil)
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.status {
+			t.Logf("%s: expected %d, got %d", tc.method, tc.status, resp.StatusCode)
+			t.Fail()
+		}
+	}
+	if l, _ := store.Get(ctx, "once"); l.Clicks != 1 {
+		t.Logf("expected only the GET to be counted, got %d clicks", l.Clicks)
+		t.Fail()
+	}
+}
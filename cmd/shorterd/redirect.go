@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"html/template"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/jennyservices/jenny/options"
+	"github.com/jennyservices/shorter/shorter"
+	v1 "github.com/jennyservices/shorter/transport/v1"
+)
+
+var errorPage = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html>
+<head><title>{{.Title}}</title></head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.Message}}</p>
+</body>
+</html>
+`))
+
+// newHTTPHandler serves browsers following short links on GET /{code} and
+// hands everything else, GET /links included, to the JSON API.
+func newHTTPHandler(shorterSvc v1.Shorter, redirect int) http.Handler {
+	api := v1.NewShorterHTTPServer(shorterSvc, options.WithErrorEncoder(shorter.EncodeError))
+	router := mux.NewRouter()
+	router.Path("/links").Handler(api)
+	router.Methods(http.MethodGet, http.MethodHead).
+		Path("/{code}").
+		Handler(redirectHandler(shorterSvc, redirect))
+	router.PathPrefix("/").Handler(api)
+	return router
+}
+
+// resolver is implemented by services that can tell where a code leads
+// without counting a click on it.
+type resolver interface {
+	Resolve(ctx context.Context, code string) (*v1.URL, error)
+}
+
+// redirectHandler redirects to the URL behind the code with the status set on
+// the link, falling back to redirect. HEAD requests, which link previews and
+// curl -I send, don't count a click when shorterSvc is a resolver.
+func redirectHandler(shorterSvc v1.Shorter, redirect int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		code := mux.Vars(r)["code"]
+		expand := shorterSvc.Expand
+		if res, ok := shorterSvc.(resolver); ok && r.Method == http.MethodHead {
+			expand = res.Resolve
+		}
+		long, err := expand(r.Context(), code)
+		switch err.(type) {
+		case nil:
+		case shorter.NotFound:
+			writeErrorPage(w, http.StatusNotFound, "Link not found", "There is no link behind /"+code+", it might have been mistyped.")
+			return
+		case shorter.Gone:
+			writeErrorPage(w, http.StatusGone, "Link expired", "The link behind /"+code+" has expired.")
+			return
+		default:
+			log.Println(err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		status := redirect
+		if shorter.ValidRedirect(long.Redirect) {
+			status = long.Redirect
+		}
+		http.Redirect(w, r, long.Addr, status)
+	}
+}
+
+func writeErrorPage(w http.ResponseWriter, status int, title, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	errorPage.Execute(w, struct{ Title, Message string }{title, message})
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "github.com/jennyservices/shorter/transport/v1"
+	"golang.org/x/net/http2"
+	"google.golang.org/grpc"
+)
+
+// sniffTimeout bounds how long a new connection has to send enough bytes to
+// tell HTTP/1.1 from HTTP/2.
+const sniffTimeout = 10 * time.Second
+
+// singlePortServer serves HTTP/1.1 and HTTP/2 on a single listener, and gRPC
+// over the latter. Over TLS the protocol is negotiated with ALPN. Otherwise
+// HTTP/2 is cleartext with prior knowledge (h2c), and connections are told
+// apart by whether they open with the HTTP/2 preface.
+type singlePortServer struct {
+	grpc *grpc.Server
+	h1   *http.Server
+	h2   *http2.Server
+	l    net.Listener
+	tls  bool
+
+	// h2c connections are served outside of h1, so they're tracked here
+	// to wait for them on shutdown.
+	mu      sync.Mutex
+	closing bool
+	h2Conns map[net.Conn]struct{}
+	wg      sync.WaitGroup
+}
+
+// listenSinglePort listens on addr to serve shorterSvc over both HTTP and
+// gRPC, over TLS unless tlsConfig is nil.
+func listenSinglePort(shorterSvc v1.Shorter, addr string, redirect int, tlsConfig *tls.Config, opts ...grpcOption) (*singlePortServer, error) {
+	gRPCServer := newGRPCServer(shorterSvc, opts...)
+	s := &singlePortServer{
+		grpc:    gRPCServer,
+		h1:      &http.Server{Handler: grpcHandler(gRPCServer, newHTTPHandler(shorterSvc, redirect))},
+		h2:      &http2.Server{},
+		h2Conns: make(map[net.Conn]struct{}),
+	}
+	if err := http2.ConfigureServer(s.h1, s.h2); err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+		s.tls = true
+	}
+	s.l = listener
+	log.Printf("HTTP and GRPC server listening at %s\n", addr)
+	return s, nil
+}
+
+// grpcHandler sends gRPC requests to grpcServer and everything else to
+// other. gRPC is only ever spoken over HTTP/2.
+func grpcHandler(grpcServer *grpc.Server, other http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			grpcServer.ServeHTTP(w, r)
+			return
+		}
+		other.ServeHTTP(w, r)
+	})
+}
+
+func (s *singlePortServer) Serve() error {
+	if s.tls {
+		if err := s.h1.Serve(s.l); err != http.ErrServerClosed {
+			return err
+		}
+		return nil
+	}
+
+	http1 := newConnListener(s.l.Addr())
+	defer http1.Close()
+	go s.h1.Serve(http1)
+
+	for {
+		conn, err := s.l.Accept()
+		if err != nil {
+			if s.isClosing() {
+				return nil
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				time.Sleep(5 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+		go s.serveConn(conn, http1)
+	}
+}
+
+// serveConn serves conn as h2c if it opens with the HTTP/2 preface, and hands
+// it to http1 otherwise.
+func (s *singlePortServer) serveConn(conn net.Conn, http1 *connListener) {
+	conn, isH2, err := sniff(conn)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	if !isH2 {
+		if !http1.push(conn) {
+			conn.Close()
+		}
+		return
+	}
+
+	s.mu.Lock()
+	if s.closing {
+		s.mu.Unlock()
+		conn.Close()
+		return
+	}
+	s.h2Conns[conn] = struct{}{}
+	s.wg.Add(1)
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.h2Conns, conn)
+		s.mu.Unlock()
+		s.wg.Done()
+	}()
+
+	s.h2.ServeConn(conn, &http2.ServeConnOpts{BaseConfig: s.h1, Handler: s.h1.Handler})
+}
+
+func (s *singlePortServer) isClosing() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closing
+}
+
+// Shutdown shuts h1 down, which also tells HTTP/2 clients to go away, and
+// waits for the h2c connections to be done as well.
+func (s *singlePortServer) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closing = true
+	s.mu.Unlock()
+	if !s.tls {
+		s.l.Close()
+	}
+
+	err := s.h1.Shutdown(ctx)
+	if err == nil {
+		done := make(chan struct{})
+		go func() {
+			s.wg.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+	if err != nil {
+		s.h1.Close()
+		s.mu.Lock()
+		for conn := range s.h2Conns {
+			conn.Close()
+		}
+		s.mu.Unlock()
+	}
+	// GracefulStop doesn't work with gRPC served over ServeHTTP, but every
+	// stream is done by now, so Stop only cleans up
+	s.grpc.Stop()
+	return err
+}
+
+// sniff reports whether conn opens with the HTTP/2 client preface. The bytes
+// it reads to find out are read again from the returned connection.
+func sniff(conn net.Conn) (net.Conn, bool, error) {
+	conn.SetReadDeadline(time.Now().Add(sniffTimeout))
+	r := bufio.NewReader(conn)
+	isH2 := true
+	// peek a byte at a time so short HTTP/1 requests aren't held up
+	// waiting for a whole preface
+	for n := 1; n <= len(http2.ClientPreface); n++ {
+		b, err := r.Peek(n)
+		if err != nil {
+			return conn, false, err
+		}
+		if b[n-1] != http2.ClientPreface[n-1] {
+			isH2 = false
+			break
+		}
+	}
+	conn.SetReadDeadline(time.Time{})
+	return &peekedConn{Conn: conn, r: r}, isH2, nil
+}
+
+// peekedConn is a net.Conn whose reads start with what was buffered in r.
+type peekedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *peekedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+// connListener is a net.Listener that accepts the connections pushed to it.
+type connListener struct {
+	addr  net.Addr
+	conns chan net.Conn
+
+	once sync.Once
+	done chan struct{}
+}
+
+func newConnListener(addr net.Addr) *connListener {
+	return &connListener{addr: addr, conns: make(chan net.Conn), done: make(chan struct{})}
+}
+
+// push hands conn to Accept, or returns false if the listener is closed.
+func (l *connListener) push(conn net.Conn) bool {
+	select {
+	case l.conns <- conn:
+		return true
+	case <-l.done:
+		return false
+	}
+}
+
+func (l *connListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, errListenerClosed
+	}
+}
+
+func (l *connListener) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *connListener) Addr() net.Addr {
+	return l.addr
+}
+
+var errListenerClosed = errors.New("listener closed")
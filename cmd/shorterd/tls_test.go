@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/jennyservices/shorter/transport/pb"
+	v1 "github.com/jennyservices/shorter/transport/v1"
+	"github.com/phayes/freeport"
+	"golang.org/x/net/http2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// testCA signs certificates for tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "shorter test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue writes a certificate for localhost with the given serial number and
+// its key to dir, returning their paths.
+func (ca *testCA) issue(t *testing.T, dir, name string, serial int64) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	certFile, keyFile := filepath.Join(dir, name+".pem"), filepath.Join(dir, name+"-key.pem")
+	writeFile(t, certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	writeFile(t, keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	return certFile, keyFile
+}
+
+func writeFile(t *testing.T, name string, data []byte) {
+	if err := ioutil.WriteFile(name, data, 0600); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+}
+
+func TestGRPCMutualTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "shorterd")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+
+	ca := newTestCA(t)
+	caFile := filepath.Join(dir, "ca.pem")
+	writeFile(t, caFile, ca.pem)
+	serverCert, serverKey := ca.issue(t, dir, "server", 2)
+	clientCert, clientKey := ca.issue(t, dir, "client", 3)
+
+	certs, err := newCertReloader(serverCert, serverKey, caFile)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	port, err := freeport.GetFreePort()
+	if err != nil {
+		log.Fatal(err)
+	}
+	shortenFunc := func(ctx context.Context, long v1.URL) (Body *v1.URL, err error) {
+		return &v1.URL{Addr: response}, nil
+	}
+	addr := fmt.Sprintf("localhost:%d", port)
+	serveGRPC(t, &mockShorter{shorten: shortenFunc}, addr, withTLS(certs.config("h2")))
+
+	pair, err := tls.LoadX509KeyPair(clientCert, clientKey)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	clientTLS := &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{pair}}
+
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(credentials.NewTLS(clientTLS)), grpc.WithBlock(), grpc.WithTimeout(5*time.Second))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	defer conn.Close()
+	client := pb.NewShorterClient(conn)
+	if u, err := client.Shorten(context.Background(), &pb.URL{Addr: request}); err != nil || u.Addr != response {
+		t.Logf("expected %q, got %v, %v", response, u, err)
+		t.Fail()
+	}
+
+	// without a client certificate the handshake fails
+	c, err := tls.Dial("tcp", addr, &tls.Config{RootCAs: roots, NextProtos: []string{"h2"}})
+	if err == nil {
+		// with TLS 1.3 the server's verdict arrives after the handshake
+		c.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, err = c.Read(make([]byte, 1))
+		c.Close()
+	}
+	if err == nil || !strings.Contains(err.Error(), "certificate") {
+		t.Logf("expected the server to want a certificate, got %v", err)
+		t.Fail()
+	}
+
+	// a new certificate is served to new connections once reloaded, and
+	// the established one keeps working
+	ca.issue(t, dir, "server", 4)
+	if !certs.changed() {
+		// modification times can be too coarse to tell the files apart
+		future := time.Now().Add(time.Minute)
+		os.Chtimes(serverCert, future, future)
+	}
+	if !certs.changed() {
+		t.Log("expected the certificate to have changed")
+		t.Fail()
+	}
+	if err := certs.reload(); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	c, err = tls.Dial("tcp", addr, clientTLS)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if serial := c.ConnectionState().PeerCertificates[0].SerialNumber.Int64(); serial != 4 {
+		t.Logf("expected the reloaded certificate, got serial %d", serial)
+		t.Fail()
+	}
+	c.Close()
+	if _, err := client.Shorten(context.Background(), &pb.URL{Addr: request}); err != nil {
+		t.Logf("expected the established connection to survive a reload, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestHTTPTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "shorterd")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+
+	ca := newTestCA(t)
+	serverCert, serverKey := ca.issue(t, dir, "server", 2)
+	certs, err := newCertReloader(serverCert, serverKey, "")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	port, err := freeport.GetFreePort()
+	if err != nil {
+		log.Fatal(err)
+	}
+	expandFunc := func(ctx context.Context, code string) (Body *v1.URL, err error) {
+		return &v1.URL{Addr: "https://example.com"}, nil
+	}
+	addr := fmt.Sprintf("localhost:%d", port)
+	serveHTTP(t, &mockShorter{expand: expandFunc}, addr, http.StatusFound, certs.config("h2", "http/1.1"))
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	client := &http.Client{
+		Transport: &http2.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	var resp *http.Response
+	for i := 0; i < 50; i++ {
+		if resp, err = client.Get("https://" + addr + "/abc"); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound || resp.ProtoMajor != 2 {
+		t.Logf("expected a 302 over HTTP/2, got %s over %s", resp.Status, resp.Proto)
+		t.Fail()
+	}
+}
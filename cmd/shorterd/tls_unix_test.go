@@ -0,0 +1,108 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestCertWatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "shorterd")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+
+	// keep the SIGHUPs sent below from killing the test while watch isn't
+	// listening for them
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ca := newTestCA(t)
+	serverCert, serverKey := ca.issue(t, dir, "server", 2)
+	certs, err := newCertReloader(serverCert, serverKey, "")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", certs.config())
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	defer l.Close()
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			c.(*tls.Conn).Handshake()
+			c.Close()
+		}
+	}()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	// served calls poke until a new handshake gets the certificate with
+	// serial, or it gives up
+	served := func(serial int64, poke func()) bool {
+		for i := 0; i < 250; i++ {
+			poke()
+			c, err := tls.Dial("tcp", l.Addr().String(), &tls.Config{RootCAs: roots})
+			if err == nil {
+				got := c.ConnectionState().PeerCertificates[0].SerialNumber.Int64()
+				c.Close()
+				if got == serial {
+					return true
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		return false
+	}
+	watch := func(every time.Duration) (stop func()) {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			certs.watch(ctx, every)
+			close(done)
+		}()
+		return func() {
+			cancel()
+			<-done
+		}
+	}
+
+	// rewritten files are picked up by the periodic check
+	stop := watch(10 * time.Millisecond)
+	ca.issue(t, dir, "server", 3)
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(serverCert, future, future)
+	if !served(3, func() {}) {
+		t.Log("expected the rewritten certificate to be served")
+		t.Fail()
+	}
+	stop()
+
+	// without periodic checks they're reloaded on SIGHUP
+	stop = watch(0)
+	defer stop()
+	ca.issue(t, dir, "server", 4)
+	if !served(4, func() { syscall.Kill(os.Getpid(), syscall.SIGHUP) }) {
+		t.Log("expected the certificate to be reloaded on SIGHUP")
+		t.Fail()
+	}
+}
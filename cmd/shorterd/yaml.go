@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseYAML reads the subset of YAML config files need: nested mappings of
+// scalars, and lists of scalars in either flow ([a, b]) or block (- a) style,
+// with # comments. Keys are flattened by joining them with dots, and lists by
+// joining their items with commas, so the result reads like the environment.
+func parseYAML(data []byte) (map[string]string, error) {
+	type mapping struct {
+		indent int // of the key that opened it
+		child  int // of its keys, -1 until the first one
+		key    string
+	}
+	var (
+		values  = make(map[string]string)
+		stack   = []*mapping{{indent: -1, child: -1}}
+		pending *mapping // a key without a value yet
+
+		list      *mapping // the key whose block list is being read
+		listItems []string
+	)
+	set := func(key, value string) error {
+		if _, ok := values[key]; ok {
+			return fmt.Errorf("%s is set twice", key)
+		}
+		values[key] = value
+		return nil
+	}
+
+	for n, line := range strings.Split(string(data), "\n") {
+		fail := func(format string, args ...interface{}) (map[string]string, error) {
+			return nil, fmt.Errorf("line %d: %s", n+1, fmt.Sprintf(format, args...))
+		}
+
+		line = strings.TrimRight(stripComment(line), " \t\r")
+		content := strings.TrimLeft(line, " ")
+		indent := len(line) - len(content)
+		if content == "" || indent == 0 && (content == "---" || strings.HasPrefix(content, "%")) {
+			continue
+		}
+		if strings.HasPrefix(content, "\t") {
+			return fail("tabs can't be used for indentation")
+		}
+		item, isItem := listItem(content)
+
+		if list != nil {
+			if isItem && indent == list.child {
+				v, err := scalar(item)
+				if err != nil {
+					return fail("%v", err)
+				}
+				listItems = append(listItems, v)
+				continue
+			}
+			if err := set(list.key, strings.Join(listItems, ",")); err != nil {
+				return fail("%v", err)
+			}
+			list, listItems = nil, nil
+		}
+		if pending != nil {
+			switch {
+			case isItem && indent >= pending.indent:
+				v, err := scalar(item)
+				if err != nil {
+					return fail("%v", err)
+				}
+				pending.child = indent
+				list, listItems, pending = pending, []string{v}, nil
+				continue
+			case indent > pending.indent:
+				stack = append(stack, pending)
+			default:
+				if err := set(pending.key, ""); err != nil {
+					return fail("%v", err)
+				}
+			}
+			pending = nil
+		}
+
+		for indent <= stack[len(stack)-1].indent {
+			stack = stack[:len(stack)-1]
+		}
+		parent := stack[len(stack)-1]
+		if parent.child == -1 {
+			parent.child = indent
+		}
+		if indent != parent.child {
+			return fail("bad indentation")
+		}
+		if isItem {
+			return fail("unexpected list item")
+		}
+
+		key, value, err := keyValue(content)
+		if err != nil {
+			return fail("%v", err)
+		}
+		if parent.key != "" {
+			key = parent.key + "." + key
+		}
+		if value == "" {
+			pending = &mapping{indent: indent, child: -1, key: key}
+			continue
+		}
+		v, err := scalar(value)
+		if err != nil {
+			return fail("%v", err)
+		}
+		if err := set(key, v); err != nil {
+			return fail("%v", err)
+		}
+	}
+
+	var err error
+	switch {
+	case list != nil:
+		err = set(list.key, strings.Join(listItems, ","))
+	case pending != nil:
+		err = set(pending.key, "")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// stripComment cuts a # comment off line, unless it's inside quotes.
+func stripComment(line string) string {
+	var quote rune
+	escaped := false
+	for i, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0:
+			if r == '\\' && quote == '"' {
+				// the next character can't end the string
+				escaped = true
+			} else if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// listItem returns what follows the dash of a block list item.
+func listItem(content string) (string, bool) {
+	if content == "-" {
+		return "", true
+	}
+	if strings.HasPrefix(content, "- ") {
+		return strings.TrimSpace(content[2:]), true
+	}
+	return "", false
+}
+
+// keyValue splits a "key: value" line.
+func keyValue(content string) (string, string, error) {
+	i := strings.Index(content, ":")
+	for i >= 0 && i+1 < len(content) && content[i+1] != ' ' {
+		j := strings.Index(content[i+1:], ":")
+		if j < 0 {
+			i = -1
+			break
+		}
+		i += j + 1
+	}
+	if i <= 0 {
+		return "", "", fmt.Errorf("expected key: value, got %q", content)
+	}
+	key := content[:i]
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return "", "", fmt.Errorf("unsupported key %q", key)
+		}
+	}
+	return key, strings.TrimSpace(content[i+1:]), nil
+}
+
+// scalar reads a plain, quoted or null value, or a flow list of them.
+func scalar(s string) (string, error) {
+	switch {
+	case s == "", s == "~", s == "null":
+		return "", nil
+	case s[0] == '"':
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return "", fmt.Errorf("bad double quoted string %s", s)
+		}
+		return v, nil
+	case s[0] == '\'':
+		if len(s) < 2 || s[len(s)-1] != '\'' {
+			return "", fmt.Errorf("bad single quoted string %s", s)
+		}
+		return strings.Replace(s[1:len(s)-1], "''", "'", -1), nil
+	case s[0] == '[':
+		if s[len(s)-1] != ']' {
+			return "", fmt.Errorf("unterminated list %s", s)
+		}
+		var items []string
+		for _, item := range splitFlow(s[1 : len(s)-1]) {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			v, err := scalar(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, v)
+		}
+		return strings.Join(items, ","), nil
+	case strings.ContainsRune("{&*!|>@`", rune(s[0])):
+		return "", fmt.Errorf("unsupported value %s", s)
+	}
+	return s, nil
+}
+
+// splitFlow splits the items of a flow list on commas outside quotes.
+func splitFlow(s string) []string {
+	var (
+		items []string
+		quote rune
+		start int
+	)
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == ',':
+			items = append(items, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(items, s[start:])
+}
@@ -9,6 +9,7 @@ require (
 	github.com/jennyservices/jenny v0.0.5-alpha
 	github.com/phayes/freeport v0.0.0-20180830031419-95f893ade6f2
 	golang.org/x/net v0.0.0-20181005035420-146acd28ed58
+	golang.org/x/sys v0.0.0-20181205085412-a5c9d58dba9a
 	google.golang.org/grpc v1.17.0
 	willnorris.com/go/newbase60 v1.0.0
 )
@@ -0,0 +1,23 @@
+// Package yaml formats values for the YAML shorterd and shorterctl print.
+package yaml
+
+import (
+	"strconv"
+	"strings"
+)
+
+// String formats s as a YAML scalar, quoting it unless it reads back as the
+// same string.
+func String(s string) string {
+	if s == "" || strings.TrimSpace(s) != s || strings.ContainsAny(s, ":#,[]{}&*!|>'\"%@`\\") || strings.ContainsRune("-?", rune(s[0])) {
+		return strconv.Quote(s)
+	}
+	switch strings.ToLower(s) {
+	case "~", "null", "true", "false", "yes", "no", "on", "off":
+		return strconv.Quote(s)
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return strconv.Quote(s)
+	}
+	return s
+}
@@ -0,0 +1,23 @@
+package yaml
+
+import "testing"
+
+func TestString(t *testing.T) {
+	for s, want := range map[string]string{
+		"https://sho.rt":   `"https://sho.rt"`,
+		"/var/lib/shorter": "/var/lib/shorter",
+		"":                 `""`,
+		" padded":          `" padded"`,
+		"-dash":            `"-dash"`,
+		"yes":              `"yes"`,
+		"Null":             `"Null"`,
+		"1.5":              `"1.5"`,
+		`C:\data`:          `"C:\\data"`,
+		"plain text":       "plain text",
+	} {
+		if got := String(s); got != want {
+			t.Logf("%q: expected %s, got %s", s, want, got)
+			t.Fail()
+		}
+	}
+}
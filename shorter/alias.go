@@ -0,0 +1,41 @@
+package shorter
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	minAliasLength = 3
+	maxAliasLength = 64
+)
+
+// reserved are the aliases that would shadow a path served by shorterd.
+var reserved = map[string]bool{
+	"shorten": true,
+	"expand":  true,
+	"links":   true,
+	"_spec":   true,
+}
+
+// validateAlias checks alias is made of letters, digits, dashes and
+// underscores, is of a sensible length and isn't one of the reserved words.
+func validateAlias(alias string) error {
+	if len(alias) < minAliasLength || len(alias) > maxAliasLength {
+		return Invalid{
+			Field:  "alias",
+			Reason: fmt.Sprintf("must be between %d and %d characters long", minAliasLength, maxAliasLength),
+		}
+	}
+	for _, r := range alias {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+		default:
+			return Invalid{Field: "alias", Reason: fmt.Sprintf("%q isn't allowed, use letters, digits, - and _", r)}
+		}
+	}
+	if reserved[strings.ToLower(alias)] {
+		return Invalid{Field: "alias", Reason: fmt.Sprintf("%q is reserved", alias)}
+	}
+	return nil
+}
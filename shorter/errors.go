@@ -0,0 +1,299 @@
+package shorter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/golang/protobuf/ptypes"
+	pb "github.com/jennyservices/shorter/transport/pb"
+	v1 "github.com/jennyservices/shorter/transport/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// NotFound is returned when a code doesn't point to any link.
+type NotFound struct {
+	Code string
+}
+
+func (e NotFound) Error() string {
+	return fmt.Sprintf("%q not found", e.Code)
+}
+
+// StatusCode implements jenny's errors.HTTPError
+func (NotFound) StatusCode() int {
+	return http.StatusNotFound
+}
+
+// GRPCStatus lets the gRPC server report the error as codes.NotFound
+func (e NotFound) GRPCStatus() *status.Status {
+	return status.New(codes.NotFound, e.Error())
+}
+
+// Invalid is returned when a field of a request holds a value the service
+// can't accept.
+type Invalid struct {
+	Field  string
+	Reason string
+}
+
+func (e Invalid) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Reason)
+}
+
+// StatusCode implements jenny's errors.HTTPError
+func (Invalid) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+// GRPCStatus lets the gRPC server report the error as codes.InvalidArgument,
+// with the field in a pb.BadRequest detail
+func (e Invalid) GRPCStatus() *status.Status {
+	return badRequest(e.Error(), e.Field, e.Reason)
+}
+
+// AliasTaken is returned when a custom alias already points to another URL.
+type AliasTaken struct {
+	Alias string
+}
+
+func (e AliasTaken) Error() string {
+	return fmt.Sprintf("alias %q is already taken", e.Alias)
+}
+
+// StatusCode implements jenny's errors.HTTPError
+func (AliasTaken) StatusCode() int {
+	return http.StatusConflict
+}
+
+// GRPCStatus lets the gRPC server report the error as codes.AlreadyExists
+func (e AliasTaken) GRPCStatus() *status.Status {
+	return status.New(codes.AlreadyExists, e.Error())
+}
+
+// Conflict is returned when a request clashes with one made earlier, like
+// reusing an idempotency key for a different URL.
+type Conflict struct {
+	Reason string
+}
+
+func (e Conflict) Error() string {
+	return e.Reason
+}
+
+// StatusCode implements jenny's errors.HTTPError
+func (Conflict) StatusCode() int {
+	return http.StatusConflict
+}
+
+// GRPCStatus lets the gRPC server report the error as codes.Aborted
+func (e Conflict) GRPCStatus() *status.Status {
+	return status.New(codes.Aborted, e.Error())
+}
+
+// Gone is returned when a link has expired or used up its clicks.
+type Gone struct {
+	Code string
+}
+
+func (e Gone) Error() string {
+	return fmt.Sprintf("%q has expired", e.Code)
+}
+
+// StatusCode implements jenny's errors.HTTPError
+func (Gone) StatusCode() int {
+	return http.StatusGone
+}
+
+// GRPCStatus lets the gRPC server report the error as codes.FailedPrecondition
+func (e Gone) GRPCStatus() *status.Status {
+	return status.New(codes.FailedPrecondition, e.Error())
+}
+
+// InvalidURL is returned when a URL can't be shortened.
+type InvalidURL struct {
+	URL    string
+	Reason string
+}
+
+func (e InvalidURL) Error() string {
+	return fmt.Sprintf("%q isn't a valid URL: %s", e.URL, e.Reason)
+}
+
+// StatusCode implements jenny's errors.HTTPError
+func (InvalidURL) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+// GRPCStatus lets the gRPC server report the error as codes.InvalidArgument,
+// with the addr field in a pb.BadRequest detail
+func (e InvalidURL) GRPCStatus() *status.Status {
+	return badRequest(e.Error(), "addr", e.Reason)
+}
+
+// Forbidden is returned when the caller isn't allowed to do what it asked.
+type Forbidden struct {
+	Reason string
+}
+
+func (e Forbidden) Error() string {
+	return "forbidden: " + e.Reason
+}
+
+// StatusCode implements jenny's errors.HTTPError
+func (Forbidden) StatusCode() int {
+	return http.StatusForbidden
+}
+
+// GRPCStatus lets the gRPC server report the error as codes.PermissionDenied
+func (e Forbidden) GRPCStatus() *status.Status {
+	return status.New(codes.PermissionDenied, e.Error())
+}
+
+// RateLimited is returned when the caller made too many requests, and should
+// wait RetryAfter before trying again.
+type RateLimited struct {
+	RetryAfter time.Duration
+}
+
+func (e RateLimited) Error() string {
+	return fmt.Sprintf("rate limited, retry after %v", e.RetryAfter)
+}
+
+// StatusCode implements jenny's errors.HTTPError
+func (RateLimited) StatusCode() int {
+	return http.StatusTooManyRequests
+}
+
+// Headers implements go-kit's http.Headerer, adding a Retry-After header.
+func (e RateLimited) Headers() http.Header {
+	seconds := int((e.RetryAfter + time.Second - 1) / time.Second)
+	return http.Header{"Retry-After": {strconv.Itoa(seconds)}}
+}
+
+// GRPCStatus lets the gRPC server report the error as
+// codes.ResourceExhausted, with a pb.RetryInfo detail
+func (e RateLimited) GRPCStatus() *status.Status {
+	st := status.New(codes.ResourceExhausted, e.Error())
+	if withInfo, err := st.WithDetails(&pb.RetryInfo{RetryDelay: ptypes.DurationProto(e.RetryAfter)}); err == nil {
+		return withInfo
+	}
+	return st
+}
+
+// Internal wraps an error the caller can't do anything about, like a store
+// failing, so its details aren't leaked to the caller.
+type Internal struct {
+	Err error
+}
+
+func (e Internal) Error() string {
+	return "internal error"
+}
+
+// Cause returns the wrapped error.
+func (e Internal) Cause() error {
+	return e.Err
+}
+
+// StatusCode implements jenny's errors.HTTPError
+func (Internal) StatusCode() int {
+	return http.StatusInternalServerError
+}
+
+// GRPCStatus lets the gRPC server report the error as codes.Internal
+func (e Internal) GRPCStatus() *status.Status {
+	return status.New(codes.Internal, e.Error())
+}
+
+// badRequest builds an InvalidArgument status with a single field violation.
+func badRequest(msg, field, description string) *status.Status {
+	st := status.New(codes.InvalidArgument, msg)
+	withViolation, err := st.WithDetails(&pb.BadRequest{
+		FieldViolations: []*pb.FieldViolation{{Field: field, Description: description}},
+	})
+	if err != nil {
+		return st
+	}
+	return withViolation
+}
+
+// internal wraps err in Internal unless it's already one of the errors above,
+// so the service never hands callers anything else.
+func internal(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(interface{ GRPCStatus() *status.Status }); ok {
+		return err
+	}
+	log.Println(err)
+	return Internal{Err: err}
+}
+
+// EncodeError is a go-kit http.ErrorEncoder that writes err as the Error
+// definition in swagger.yaml. A request's context running out is reported as
+// a 504, or a 503 if it was cancelled, and other errors that don't carry a
+// status code as 500s without their message.
+func EncodeError(_ context.Context, err error, w http.ResponseWriter) {
+	body := errorBody(err)
+	if h, ok := err.(kithttp.Headerer); ok {
+		for k, values := range h.Headers() {
+			for _, v := range values {
+				w.Header().Add(k, v)
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(body.Status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// errorBody describes err for callers, including the details of its gRPC
+// status.
+func errorBody(err error) v1.Error {
+	body := v1.Error{
+		Status:  http.StatusInternalServerError,
+		Code:    int(codes.Internal),
+		Message: Internal{}.Error(),
+	}
+	switch sc, ok := err.(kithttp.StatusCoder); {
+	case ok:
+		body.Status = sc.StatusCode()
+		body.Message = err.Error()
+	case err == context.Canceled:
+		body.Status = http.StatusServiceUnavailable
+		body.Code = int(codes.Canceled)
+		body.Message = err.Error()
+	case err == context.DeadlineExceeded:
+		body.Status = http.StatusGatewayTimeout
+		body.Code = int(codes.DeadlineExceeded)
+		body.Message = err.Error()
+	default:
+		log.Println(err)
+	}
+	body.Error = http.StatusText(body.Status)
+
+	if gs, ok := err.(interface{ GRPCStatus() *status.Status }); ok {
+		body.Code = int(gs.GRPCStatus().Code())
+		for _, detail := range gs.GRPCStatus().Details() {
+			switch detail := detail.(type) {
+			case *pb.BadRequest:
+				for _, v := range detail.FieldViolations {
+					body.FieldViolations = append(body.FieldViolations, v1.FieldViolation{Field: v.Field, Description: v.Description})
+				}
+			case *pb.RetryInfo:
+				if d, err := ptypes.Duration(detail.RetryDelay); err == nil {
+					body.RetryAfter = int((d + time.Second - 1) / time.Second)
+				}
+			}
+		}
+	}
+	return body
+}
@@ -0,0 +1,149 @@
+package shorter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	pb "github.com/jennyservices/shorter/transport/pb"
+	v1 "github.com/jennyservices/shorter/transport/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestErrorStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		http int
+		grpc codes.Code
+	}{
+		{InvalidURL{URL: "nope", Reason: "it has no scheme"}, http.StatusBadRequest, codes.InvalidArgument},
+		{Invalid{Field: "alias", Reason: "is reserved"}, http.StatusBadRequest, codes.InvalidArgument},
+		{NotFound{Code: "abc"}, http.StatusNotFound, codes.NotFound},
+		{Gone{Code: "abc"}, http.StatusGone, codes.FailedPrecondition},
+		{AliasTaken{Alias: "abc"}, http.StatusConflict, codes.AlreadyExists},
+		{Conflict{Reason: "key reused"}, http.StatusConflict, codes.Aborted},
+		{Forbidden{Reason: "read only"}, http.StatusForbidden, codes.PermissionDenied},
+		{RateLimited{RetryAfter: time.Second}, http.StatusTooManyRequests, codes.ResourceExhausted},
+		{Internal{Err: errors.New("disk on fire")}, http.StatusInternalServerError, codes.Internal},
+	}
+	for _, test := range tests {
+		if code := test.err.(interface{ StatusCode() int }).StatusCode(); code != test.http {
+			t.Logf("%T: expected HTTP %d, got %d", test.err, test.http, code)
+			t.Fail()
+		}
+		if code := status.Code(test.err); code != test.grpc {
+			t.Logf("%T: expected %v, got %v", test.err, test.grpc, code)
+			t.Fail()
+		}
+	}
+}
+
+func TestErrorDetails(t *testing.T) {
+	st, _ := status.FromError(Invalid{Field: "alias", Reason: "is reserved"})
+	details := st.Details()
+	if len(details) != 1 {
+		t.Logf("expected a single detail, got %v", details)
+		t.FailNow()
+	}
+	br, ok := details[0].(*pb.BadRequest)
+	if !ok || len(br.FieldViolations) != 1 || br.FieldViolations[0].Field != "alias" {
+		t.Logf("expected a field violation on alias, got %v", details[0])
+		t.Fail()
+	}
+
+	st, _ = status.FromError(RateLimited{RetryAfter: 2 * time.Second})
+	if details := st.Details(); len(details) != 1 {
+		t.Logf("expected retry info, got %v", details)
+		t.Fail()
+	} else if _, ok := details[0].(*pb.RetryInfo); !ok {
+		t.Logf("expected retry info, got %T", details[0])
+		t.Fail()
+	}
+}
+
+func TestEncodeError(t *testing.T) {
+	tests := []struct {
+		err     error
+		body    v1.Error
+		headers http.Header
+	}{
+		{
+			err: InvalidURL{URL: "nope", Reason: "it has no scheme"},
+			body: v1.Error{
+				Status:          http.StatusBadRequest,
+				Code:            int(codes.InvalidArgument),
+				Error:           "Bad Request",
+				Message:         `"nope" isn't a valid URL: it has no scheme`,
+				FieldViolations: []v1.FieldViolation{{Field: "addr", Description: "it has no scheme"}},
+			},
+		},
+		{
+			err: RateLimited{RetryAfter: 1500 * time.Millisecond},
+			body: v1.Error{
+				Status:     http.StatusTooManyRequests,
+				Code:       int(codes.ResourceExhausted),
+				Error:      "Too Many Requests",
+				Message:    "rate limited, retry after 1.5s",
+				RetryAfter: 2,
+			},
+			headers: http.Header{"Retry-After": {"2"}},
+		},
+		{
+			err: context.DeadlineExceeded,
+			body: v1.Error{
+				Status:  http.StatusGatewayTimeout,
+				Code:    int(codes.DeadlineExceeded),
+				Error:   "Gateway Timeout",
+				Message: "context deadline exceeded",
+			},
+		},
+		{
+			err: errors.New("open /var/lib/shorter: permission denied"),
+			body: v1.Error{
+				Status:  http.StatusInternalServerError,
+				Code:    int(codes.Internal),
+				Error:   "Internal Server Error",
+				Message: "internal error",
+			},
+		},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		EncodeError(context.Background(), test.err, w)
+
+		if w.Code != test.body.Status {
+			t.Logf("%v: expected status %d, got %d", test.err, test.body.Status, w.Code)
+			t.Fail()
+		}
+		var body v1.Error
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Logf("%v: %v", test.err, err)
+			t.Fail()
+			continue
+		}
+		if body.Status != test.body.Status || body.Code != test.body.Code || body.Error != test.body.Error ||
+			body.Message != test.body.Message || body.RetryAfter != test.body.RetryAfter ||
+			len(body.FieldViolations) != len(test.body.FieldViolations) {
+			t.Logf("%v: expected %+v, got %+v", test.err, test.body, body)
+			t.Fail()
+			continue
+		}
+		for i, v := range body.FieldViolations {
+			if v != test.body.FieldViolations[i] {
+				t.Logf("%v: expected %+v, got %+v", test.err, test.body.FieldViolations[i], v)
+				t.Fail()
+			}
+		}
+		for k := range test.headers {
+			if got := w.Header().Get(k); got != test.headers.Get(k) {
+				t.Logf("%v: expected %s %q, got %q", test.err, k, test.headers.Get(k), got)
+				t.Fail()
+			}
+		}
+	}
+}
@@ -0,0 +1,732 @@
+package shorter
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	logName  = "links.log"
+	lockName = "LOCK"
+
+	// headerSize is the length and CRC32 that precede every record payload.
+	headerSize = 8
+	// maxRecordSize bounds the payload length read back from a header, so
+	// a garbled header can't make us allocate gigabytes.
+	maxRecordSize = 1 << 20
+)
+
+var (
+	// ErrLocked is returned by NewFileStore when another process has the
+	// data directory open.
+	ErrLocked = errors.New("data directory is locked by another process")
+	// ErrReadOnly is returned for writes to a store opened with
+	// OpenFileStoreReadOnly.
+	ErrReadOnly = errors.New("file store is read-only")
+)
+
+// record is a single entry of the append-only log.
+type record struct {
+	Op   string `json:"op"`
+	Link *Link  `json:"link,omitempty"`
+	Code string `json:"code,omitempty"`
+	Seq  uint64 `json:"seq,omitempty"`
+	// Owner and Key are the idempotency key added to Code, until ExpiresAt
+	// unless it's zero.
+	Owner     string    `json:"owner,omitempty"`
+	Key       string    `json:"key,omitempty"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+}
+
+const (
+	opPut    = "put"
+	opDelete = "del"
+	opSeq    = "seq"
+	opHit    = "hit"
+	opKey    = "key"
+)
+
+// NewFileStore opens, or creates, a Store kept in dir. Every write is appended
+// to a log and fsynced before it's acknowledged, so links survive the process
+// being killed at any point. The log is replayed into memory when the store is
+// opened, dropping a record that was only partially written, and rewritten
+// every compactEvery to get rid of overwritten and deleted links; zero turns
+// periodic compaction off.
+//
+// Only one process can have dir open at a time.
+func NewFileStore(dir string, compactEvery time.Duration) (*fileStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	lock, err := lockFile(filepath.Join(dir, lockName))
+	if err != nil {
+		return nil, err
+	}
+
+	f := &fileStore{
+		dir:   dir,
+		lock:  lock,
+		index: NewMemoryStore(),
+		done:  make(chan struct{}),
+	}
+	if err := f.replay(); err != nil {
+		lock.Close()
+		return nil, err
+	}
+	if compactEvery > 0 {
+		f.wg.Add(1)
+		go f.compactLoop(compactEvery)
+	}
+	return f, nil
+}
+
+// OpenFileStoreReadOnly opens the store in dir, which must exist, without
+// writing to its log, to look at it while shorterd is stopped. Where
+// NewFileStore cuts off a torn record at the end of the log and refuses a
+// corrupt one, this leaves the log as it is and replays what it can, skipping
+// to the next record that checks out; LogProblems reports what it skipped.
+// Writes fail with ErrReadOnly until Salvage is called.
+func OpenFileStoreReadOnly(dir string) (*fileStore, error) {
+	lock, err := lockFile(filepath.Join(dir, lockName))
+	if err != nil {
+		return nil, err
+	}
+
+	f := &fileStore{
+		dir:      dir,
+		lock:     lock,
+		readOnly: true,
+		index:    NewMemoryStore(),
+		done:     make(chan struct{}),
+	}
+	if err := f.replay(); err != nil {
+		lock.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+type fileStore struct {
+	dir      string
+	lock     *os.File
+	readOnly bool
+	// problems are the records replay couldn't apply.
+	problems []LogProblem
+
+	// mu serialises writes to the log, index is only updated while holding
+	// it so the order of the log and the index agree.
+	mu      sync.Mutex
+	log     *os.File
+	size    int64 // bytes of the log that hold complete records
+	index   *memoryStore
+	records int // records in the log, live or not
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// replay rebuilds the index from the log and opens it for appending. A torn
+// record at the end of the log is what a crash in the middle of a write
+// leaves behind; it was never acknowledged, so it's cut off. Anything else that
+// doesn't check out means the log is corrupt. Read-only stores only note
+// either in f.problems, and carry on past a corrupt record.
+func (f *fileStore) replay() error {
+	path := filepath.Join(f.dir, logName)
+	flag := os.O_RDWR | os.O_CREATE
+	if f.readOnly {
+		flag = os.O_RDONLY
+	}
+	file, err := os.OpenFile(path, flag, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	r := bufio.NewReader(file)
+	var offset int64
+	for {
+		rec, n, err := readRecord(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			torn, terr := tornTail(file, offset, info.Size())
+			if terr != nil {
+				file.Close()
+				return terr
+			}
+			if !torn && f.readOnly {
+				f.problems = append(f.problems, LogProblem{Offset: offset, Problem: ProblemCorrupt, Detail: err.Error()})
+				next := nextRecord(file, offset+1, info.Size())
+				if next < 0 {
+					break
+				}
+				f.problems = append(f.problems, LogProblem{Offset: next, Problem: ProblemAfterCorrupt, Detail: fmt.Sprintf("%d bytes skipped", next-offset)})
+				offset = next
+				r = bufio.NewReader(io.NewSectionReader(file, next, info.Size()-next))
+				continue
+			}
+			if !torn {
+				file.Close()
+				return fmt.Errorf("%s: corrupt record at offset %d: %v", path, offset, err)
+			}
+			if f.readOnly {
+				f.problems = append(f.problems, LogProblem{Offset: offset, Problem: ProblemTorn, Detail: err.Error()})
+				break
+			}
+			if err := file.Truncate(offset); err != nil {
+				file.Close()
+				return err
+			}
+			if err := file.Sync(); err != nil {
+				file.Close()
+				return err
+			}
+			break
+		}
+		if err := f.apply(rec); err != nil {
+			problem := ProblemNoLink
+			if err == ErrKeyExists {
+				problem = ProblemKeyTaken
+			}
+			f.problems = append(f.problems, LogProblem{Offset: offset, Op: rec.Op, Code: rec.Code, Problem: problem, Detail: err.Error()})
+		}
+		f.records++
+		offset += n
+	}
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return err
+	}
+	f.log = file
+	f.size = offset
+	return nil
+}
+
+// tornTail reports whether the bad record at offset is the remains of an
+// interrupted write: either it runs past the end of the log, or all that's
+// left of the log after its header are zeroes from space the filesystem
+// allocated but never got to write.
+func tornTail(file *os.File, offset, size int64) (bool, error) {
+	header := make([]byte, headerSize)
+	n, err := file.ReadAt(header, offset)
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	if n < headerSize {
+		return true, nil
+	}
+	if offset+headerSize+int64(binary.BigEndian.Uint32(header)) > size {
+		return true, nil
+	}
+
+	rest := io.NewSectionReader(file, offset+headerSize, size-offset-headerSize)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := rest.Read(buf)
+		for _, b := range buf[:n] {
+			if b != 0 {
+				return false, nil
+			}
+		}
+		if err == io.EOF {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+}
+
+// nextRecord returns the offset of the first record from offset on that
+// checks out, or -1 if there's none.
+func nextRecord(file *os.File, offset, size int64) int64 {
+	for ; offset+headerSize <= size; offset++ {
+		if _, _, err := readRecord(io.NewSectionReader(file, offset, size-offset)); err == nil {
+			return offset
+		}
+	}
+	return -1
+}
+
+func readRecord(r io.Reader) (*record, int64, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, 0, errors.New("short header")
+		}
+		return nil, 0, err
+	}
+	size := binary.BigEndian.Uint32(header)
+	if size == 0 || size > maxRecordSize {
+		return nil, 0, fmt.Errorf("bad record length %d", size)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, 0, errors.New("short record")
+	}
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:]) {
+		return nil, 0, errors.New("checksum mismatch")
+	}
+	rec := &record{}
+	if err := json.Unmarshal(payload, rec); err != nil {
+		return nil, 0, err
+	}
+	return rec, int64(headerSize + size), nil
+}
+
+func encodeRecord(rec *record) ([]byte, error) {
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, headerSize, headerSize+len(payload))
+	binary.BigEndian.PutUint32(buf, uint32(len(payload)))
+	binary.BigEndian.PutUint32(buf[4:], crc32.ChecksumIEEE(payload))
+	return append(buf, payload...), nil
+}
+
+// apply updates the index with rec, callers must hold f.mu or be replaying.
+// It returns ErrNotFound for a hit or key record whose link is gone, and
+// ErrKeyExists for a key record another link already holds the key of; only
+// a log that was tampered with has either.
+func (f *fileStore) apply(rec *record) error {
+	m := f.index
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch rec.Op {
+	case opPut:
+		m.put(*rec.Link)
+	case opDelete:
+		m.delete(rec.Code)
+	case opHit:
+		if !m.hit(rec.Code) {
+			return ErrNotFound
+		}
+	case opKey:
+		return m.addKey(rec.Owner, rec.Key, rec.Code, rec.ExpiresAt)
+	case opSeq:
+		if rec.Seq > m.next {
+			m.next = rec.Seq
+		}
+	}
+	return nil
+}
+
+// append writes rec to the log and fsyncs it, then applies it to the index.
+// Callers must hold f.mu.
+func (f *fileStore) append(rec *record) error {
+	if f.log == nil {
+		return errors.New("file store is closed")
+	}
+	if f.readOnly {
+		return ErrReadOnly
+	}
+	buf, err := encodeRecord(rec)
+	if err != nil {
+		return err
+	}
+	_, err = f.log.Write(buf)
+	if err == nil {
+		err = f.log.Sync()
+	}
+	if err != nil {
+		// drop whatever made it to the log so the next record doesn't
+		// land after half of this one
+		f.log.Truncate(f.size)
+		f.log.Seek(f.size, io.SeekStart)
+		return err
+	}
+	f.size += int64(len(buf))
+	f.apply(rec)
+	f.records++
+	return nil
+}
+
+func (f *fileStore) Put(_ context.Context, l Link) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.append(&record{Op: opPut, Link: &l})
+}
+
+func (f *fileStore) Update(ctx context.Context, code string, update func(*Link) error) (*Link, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	l, err := f.index.Get(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	if err := update(l); err != nil {
+		return nil, err
+	}
+	l.Code = code
+	if err := f.append(&record{Op: opPut, Link: l}); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (f *fileStore) Add(_ context.Context, l Link) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.index.mu.RLock()
+	err := f.index.conflict(l)
+	f.index.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	return f.append(&record{Op: opPut, Link: &l})
+}
+
+func (f *fileStore) AddKey(_ context.Context, owner, key, code string, expiresAt time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	m := f.index
+	m.mu.RLock()
+	existing, taken := m.liveKey(scoped(owner, key), time.Now())
+	_, ok := m.links[code]
+	m.mu.RUnlock()
+	switch {
+	case taken && existing == code:
+		return nil
+	case taken:
+		return ErrKeyExists
+	case !ok:
+		return ErrNotFound
+	}
+	return f.append(&record{Op: opKey, Code: code, Owner: owner, Key: key, ExpiresAt: expiresAt})
+}
+
+// ReapKeys only drops the keys from memory, as compaction leaves expired ones
+// out of the log.
+func (f *fileStore) ReapKeys(ctx context.Context, now time.Time) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.index.ReapKeys(ctx, now)
+}
+
+func (f *fileStore) Get(ctx context.Context, code string) (*Link, error) {
+	return f.index.Get(ctx, code)
+}
+
+func (f *fileStore) GetByURL(ctx context.Context, owner, url string) (*Link, error) {
+	return f.index.GetByURL(ctx, owner, url)
+}
+
+func (f *fileStore) GetByKey(ctx context.Context, owner, key string) (*Link, error) {
+	return f.index.GetByKey(ctx, owner, key)
+}
+
+func (f *fileStore) Delete(ctx context.Context, code string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if ok, _ := f.index.Exists(ctx, code); !ok {
+		return nil
+	}
+	return f.append(&record{Op: opDelete, Code: code})
+}
+
+func (f *fileStore) Exists(ctx context.Context, code string) (bool, error) {
+	return f.index.Exists(ctx, code)
+}
+
+func (f *fileStore) ExistsURL(ctx context.Context, owner, url string) (bool, error) {
+	return f.index.ExistsURL(ctx, owner, url)
+}
+
+func (f *fileStore) Hit(ctx context.Context, code string) (*Link, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if ok, _ := f.index.Exists(ctx, code); !ok {
+		return nil, ErrNotFound
+	}
+	if err := f.append(&record{Op: opHit, Code: code}); err != nil {
+		return nil, err
+	}
+	return f.index.Get(ctx, code)
+}
+
+func (f *fileStore) List(ctx context.Context, after string, limit int) ([]Link, error) {
+	return f.index.List(ctx, after, limit)
+}
+
+func (f *fileStore) ListByOwner(ctx context.Context, owner, after string, limit int) ([]Link, error) {
+	return f.index.ListByOwner(ctx, owner, after, limit)
+}
+
+func (f *fileStore) Next(context.Context) (uint64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.index.mu.RLock()
+	n := f.index.next + 1
+	f.index.mu.RUnlock()
+
+	if err := f.append(&record{Op: opSeq, Seq: n}); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// Compact rewrites the log with only the links that are still live. The new
+// log is written next to the old one and renamed over it, so a crash during
+// compaction leaves one or the other in place.
+func (f *fileStore) Compact() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.readOnly {
+		return ErrReadOnly
+	}
+	return f.compact()
+}
+
+// compact must be called with f.mu held.
+func (f *fileStore) compact() error {
+	if f.log == nil {
+		return errors.New("file store is closed")
+	}
+
+	path := filepath.Join(f.dir, logName)
+	tmp, err := os.OpenFile(path+".tmp", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	records, err := f.writeSnapshot(tmp)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	var size int64
+	if err == nil {
+		size, err = tmp.Seek(0, io.SeekCurrent)
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	// the old log is gone once renamed over, so writes have to go to the
+	// new one even if the rename can't be made durable
+	f.log.Close()
+	f.log = tmp
+	f.size = size
+	f.records = records
+	return syncDir(f.dir)
+}
+
+// writeSnapshot writes a record for every live link to w, in the order they
+// were minted so replaying it points shared index entries to the same links,
+// callers must hold f.mu.
+func (f *fileStore) writeSnapshot(w io.Writer) (int, error) {
+	m := f.index
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	bw := bufio.NewWriter(w)
+	records := 0
+	write := func(rec *record) error {
+		buf, err := encodeRecord(rec)
+		if err != nil {
+			return err
+		}
+		records++
+		_, err = bw.Write(buf)
+		return err
+	}
+
+	now := time.Now()
+	if m.next > 0 {
+		if err := write(&record{Op: opSeq, Seq: m.next}); err != nil {
+			return 0, err
+		}
+	}
+	for _, code := range m.mintedCodes() {
+		l := m.links[code]
+		if err := write(&record{Op: opPut, Link: &l}); err != nil {
+			return 0, err
+		}
+		keys := make([]string, 0, len(m.added[code]))
+		for k, exp := range m.added[code] {
+			if exp.IsZero() || now.Before(exp) {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			owner, key := unscoped(k)
+			if err := write(&record{Op: opKey, Code: code, Owner: owner, Key: key, ExpiresAt: m.added[code][k]}); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return records, bw.Flush()
+}
+
+// CheckIndex returns the entries of the long URL and idempotency key indexes
+// that disagree with the links in the store.
+func (f *fileStore) CheckIndex() []IndexProblem {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.index.mu.RLock()
+	defer f.index.mu.RUnlock()
+	return f.index.checkIndex()
+}
+
+// RepairIndex rebuilds the long URL and idempotency key indexes from the
+// links in the store, pointing every entry shared by several links to the one
+// minted last, and compacts the log so replaying it rebuilds them the same
+// way. It returns the problems it fixed.
+func (f *fileStore) RepairIndex() ([]IndexProblem, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.readOnly {
+		return nil, ErrReadOnly
+	}
+
+	f.index.mu.Lock()
+	problems := f.index.checkIndex()
+	if len(problems) > 0 {
+		f.index.reindex()
+	}
+	f.index.mu.Unlock()
+	if len(problems) == 0 {
+		return nil, nil
+	}
+	return problems, f.compact()
+}
+
+// LogProblems returns the records of the log that were skipped or couldn't be
+// applied when the store was opened with OpenFileStoreReadOnly, in the order
+// they're in the log.
+func (f *fileStore) LogProblems() []LogProblem {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]LogProblem(nil), f.problems...)
+}
+
+// Salvage rewrites the log of a store opened with OpenFileStoreReadOnly with
+// what could be replayed from it, dropping the corrupt, torn and unapplied
+// records LogProblems reports, and opens it for writing. It returns the
+// problems it fixed.
+func (f *fileStore) Salvage() ([]LogProblem, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.readOnly {
+		return nil, nil
+	}
+	if err := f.compact(); err != nil {
+		return nil, err
+	}
+	f.readOnly = false
+	problems := f.problems
+	f.problems = nil
+	return problems, nil
+}
+
+func (f *fileStore) compactLoop(every time.Duration) {
+	defer f.wg.Done()
+
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-f.done:
+			return
+		case <-ticker.C:
+			if !f.needsCompaction() {
+				continue
+			}
+			if err := f.Compact(); err != nil {
+				log.Printf("compacting %s: %v", f.dir, err)
+			}
+		}
+	}
+}
+
+// needsCompaction reports whether at least half of the log is dead records.
+func (f *fileStore) needsCompaction() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.index.mu.RLock()
+	live := len(f.index.links) + 1
+	f.index.mu.RUnlock()
+	return f.records > 2*live
+}
+
+// Ping reports an error if the log is closed or can no longer be read.
+func (f *fileStore) Ping(context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.log == nil {
+		return errors.New("file store is closed")
+	}
+	_, err := f.log.Stat()
+	return err
+}
+
+// Close stops compaction and closes the log, releasing the data directory.
+func (f *fileStore) Close() error {
+	f.mu.Lock()
+	if f.log == nil {
+		f.mu.Unlock()
+		return nil
+	}
+	close(f.done)
+	f.mu.Unlock()
+	f.wg.Wait()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	err := f.log.Close()
+	f.log = nil
+	if lerr := f.lock.Close(); err == nil {
+		err = lerr
+	}
+	return err
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,506 @@
+package shorter
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "shorter")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	return dir
+}
+
+func openFileStore(t *testing.T, dir string) *fileStore {
+	store, err := NewFileStore(dir, 0)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	return store
+}
+
+func expectLinks(t *testing.T, store Store, codes ...string) {
+	ctx := context.Background()
+	for _, code := range codes {
+		l, err := store.Get(ctx, code)
+		if err != nil {
+			t.Logf("%s: %v", code, err)
+			t.FailNow()
+		}
+		if l.URL != "https://example.com/"+code {
+			t.Logf("%s: unexpected url %q", code, l.URL)
+			t.Fail()
+		}
+	}
+}
+
+func TestFileStoreReopen(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+
+	store := openFileStore(t, dir)
+	for _, code := range []string{"a", "b", "c"} {
+		store.Put(ctx, Link{Code: code, URL: "https://example.com/" + code})
+	}
+	store.Delete(ctx, "b")
+	if err := store.Add(ctx, Link{Code: "a", URL: "https://example.org"}); err != ErrExists {
+		t.Logf("expected ErrExists, got %v", err)
+		t.Fail()
+	}
+	store.Add(ctx, Link{Code: "d", URL: "https://example.com/d", Owner: "alice", Key: "k"})
+	store.AddKey(ctx, "alice", "k2", "a", time.Time{})
+	store.AddKey(ctx, "alice", "soon", "a", time.Now().Add(-time.Second))
+	n, _ := store.Next(ctx)
+	store.Hit(ctx, "c")
+	store.Update(ctx, "c", func(l *Link) error { l.MaxClicks = 5; return nil })
+	store.Close()
+
+	store = openFileStore(t, dir)
+	defer store.Close()
+	expectLinks(t, store, "a", "c", "d")
+	if ok, _ := store.Exists(ctx, "b"); ok {
+		t.Log("expected b to stay deleted")
+		t.Fail()
+	}
+	if l, _ := store.Get(ctx, "c"); l.Clicks != 1 || l.MaxClicks != 5 {
+		t.Logf("expected the click on c and its update to be kept, got %+v", l)
+		t.Fail()
+	}
+	if links, err := store.ListByOwner(ctx, "alice", "", 0); err != nil || len(links) != 1 || links[0].Code != "d" {
+		t.Logf("expected alice to have d only, got %v, %v", links, err)
+		t.Fail()
+	}
+	if l, err := store.GetByKey(ctx, "alice", "k"); err != nil || l.Code != "d" {
+		t.Logf("expected the idempotency key to be kept, got %v, %v", l, err)
+		t.Fail()
+	}
+	if l, err := store.GetByKey(ctx, "alice", "k2"); err != nil || l.Code != "a" {
+		t.Logf("expected the added idempotency key to be kept, got %v, %v", l, err)
+		t.Fail()
+	}
+	if _, err := store.GetByKey(ctx, "alice", "soon"); err != ErrNotFound {
+		t.Logf("expected the expired idempotency key to be gone, got %v", err)
+		t.Fail()
+	}
+	if n, err := store.ReapKeys(ctx, time.Now()); err != nil || n != 1 {
+		t.Logf("expected the expired idempotency key to be reaped, got %d, %v", n, err)
+		t.Fail()
+	}
+	if err := store.AddKey(ctx, "alice", "soon", "c", time.Time{}); err != nil {
+		t.Logf("expected an expired idempotency key to be free again, got %v", err)
+		t.Fail()
+	}
+	if err := store.AddKey(ctx, "alice", "k2", "c", time.Time{}); err != ErrKeyExists {
+		t.Logf("expected ErrKeyExists adding a key in use, got %v", err)
+		t.Fail()
+	}
+	if err := store.Add(ctx, Link{Code: "e", URL: "https://example.com/e", Owner: "alice", Key: "k"}); err != ErrKeyExists {
+		t.Logf("expected ErrKeyExists, got %v", err)
+		t.Fail()
+	}
+	if next, _ := store.Next(ctx); next != n+1 {
+		t.Logf("expected the counter to carry on at %d, got %d", n+1, next)
+		t.Fail()
+	}
+}
+
+// TestFileStoreTornWrite cuts the log at every byte of the last record, which
+// is what a crash halfway through a write leaves behind.
+func TestFileStoreTornWrite(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	path := filepath.Join(dir, logName)
+
+	store := openFileStore(t, dir)
+	store.Put(ctx, Link{Code: "a", URL: "https://example.com/a"})
+	store.Put(ctx, Link{Code: "b", URL: "https://example.com/b"})
+	store.AddKey(ctx, "", "k", "b", time.Time{})
+	before, _ := os.Stat(path)
+	store.Put(ctx, Link{Code: "c", URL: "https://example.com/c"})
+	store.Close()
+
+	full, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	for size := before.Size(); size < int64(len(full)); size++ {
+		if err := ioutil.WriteFile(path, full[:size], 0644); err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+
+		store := openFileStore(t, dir)
+		expectLinks(t, store, "a", "b")
+		if ok, _ := store.Exists(ctx, "c"); ok {
+			t.Logf("cut at %d: expected c to be dropped", size)
+			t.Fail()
+		}
+		// the store keeps working after recovering
+		store.Put(ctx, Link{Code: "d", URL: "https://example.com/d"})
+		store.Close()
+
+		store = openFileStore(t, dir)
+		expectLinks(t, store, "a", "b", "d")
+		store.Close()
+	}
+
+	// the header made it to disk but the payload is still zeroes
+	header := full[before.Size() : before.Size()+headerSize]
+	zeroed := append(append(append([]byte(nil), full[:before.Size()]...), header...), make([]byte, len(full)-int(before.Size())-headerSize)...)
+	if err := ioutil.WriteFile(path, zeroed, 0644); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	store = openFileStore(t, dir)
+	defer store.Close()
+	expectLinks(t, store, "a", "b")
+	if ok, _ := store.Exists(ctx, "c"); ok {
+		t.Log("zeroed payload: expected c to be dropped")
+		t.Fail()
+	}
+}
+
+func TestFileStoreZeroedTail(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+
+	store := openFileStore(t, dir)
+	store.Put(ctx, Link{Code: "a", URL: "https://example.com/a"})
+	store.Close()
+
+	f, _ := os.OpenFile(filepath.Join(dir, logName), os.O_WRONLY|os.O_APPEND, 0644)
+	f.Write(make([]byte, 4096))
+	f.Close()
+
+	store = openFileStore(t, dir)
+	defer store.Close()
+	expectLinks(t, store, "a")
+}
+
+func TestFileStoreCorrupt(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	path := filepath.Join(dir, logName)
+
+	store := openFileStore(t, dir)
+	store.Put(ctx, Link{Code: "a", URL: "https://example.com/a"})
+	store.Put(ctx, Link{Code: "b", URL: "https://example.com/b"})
+	store.Close()
+
+	// flip a byte in the middle of the first record
+	log, _ := ioutil.ReadFile(path)
+	log[headerSize+2] ^= 0xff
+	ioutil.WriteFile(path, log, 0644)
+
+	if _, err := NewFileStore(dir, 0); err == nil {
+		t.Log("expected a corrupt log to be refused")
+		t.Fail()
+	}
+}
+
+func TestFileStoreReadOnly(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	path := filepath.Join(dir, logName)
+
+	store := openFileStore(t, dir)
+	store.Put(ctx, Link{Code: "a", URL: "https://example.com/a"})
+	middle, _ := os.Stat(path)
+	store.Put(ctx, Link{Code: "b", URL: "https://example.com/b"})
+	store.Put(ctx, Link{Code: "c", URL: "https://example.com/c"})
+	store.Close()
+
+	// corrupt b, then add a key for a link that was never there and a torn
+	// record
+	log, _ := ioutil.ReadFile(path)
+	log[middle.Size()+headerSize+2] ^= 0xff
+	key, _ := encodeRecord(&record{Op: opKey, Code: "x", Key: "k"})
+	torn, _ := encodeRecord(&record{Op: opPut, Link: &Link{Code: "d", URL: "https://example.com/d"}})
+	log = append(append(log, key...), torn[:len(torn)-1]...)
+	ioutil.WriteFile(path, log, 0644)
+
+	store, err := OpenFileStoreReadOnly(dir)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	expectLinks(t, store, "a", "c")
+	var problems []string
+	for _, p := range store.LogProblems() {
+		problems = append(problems, p.Problem)
+	}
+	want := []string{ProblemCorrupt, ProblemAfterCorrupt, ProblemNoLink, ProblemTorn}
+	if fmt.Sprint(problems) != fmt.Sprint(want) {
+		t.Logf("expected problems %q, got %q", want, problems)
+		t.Fail()
+	}
+	if err := store.Put(ctx, Link{Code: "e", URL: "https://example.com/e"}); err != ErrReadOnly {
+		t.Logf("expected writes to be refused, got %v", err)
+		t.Fail()
+	}
+	if after, _ := ioutil.ReadFile(path); !bytes.Equal(after, log) {
+		t.Log("expected the log to be left as it was")
+		t.Fail()
+	}
+
+	if _, err := store.Salvage(); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if err := store.Put(ctx, Link{Code: "e", URL: "https://example.com/e"}); err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+	store.Close()
+
+	store = openFileStore(t, dir)
+	defer store.Close()
+	expectLinks(t, store, "a", "c", "e")
+}
+
+func TestFileStoreCompact(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	path := filepath.Join(dir, logName)
+
+	store := openFileStore(t, dir)
+	for i := 0; i < 100; i++ {
+		store.Put(ctx, Link{Code: "a", URL: fmt.Sprintf("https://example.com/%d", i)})
+		store.Put(ctx, Link{Code: fmt.Sprint(i), URL: fmt.Sprintf("https://example.com/%d", i)})
+		store.Delete(ctx, fmt.Sprint(i))
+	}
+	store.Put(ctx, Link{Code: "b", URL: "https://example.com/b"})
+	store.AddKey(ctx, "", "k", "b", time.Time{})
+	store.AddKey(ctx, "", "expired", "b", time.Now().Add(-time.Second))
+	before, _ := os.Stat(path)
+
+	if !store.needsCompaction() {
+		t.Log("expected a log of mostly dead records to need compaction")
+		t.Fail()
+	}
+	if err := store.Compact(); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	after, _ := os.Stat(path)
+	if after.Size() >= before.Size()/10 {
+		t.Logf("expected compaction to shrink the log, went from %d to %d bytes", before.Size(), after.Size())
+		t.Fail()
+	}
+
+	// writes after compaction go to the new log
+	store.Put(ctx, Link{Code: "c", URL: "https://example.com/c"})
+	store.Close()
+
+	store = openFileStore(t, dir)
+	defer store.Close()
+	expectLinks(t, store, "b", "c")
+	if l, err := store.GetByKey(ctx, "", "k"); err != nil || l.Code != "b" {
+		t.Logf("expected the added idempotency key to survive compaction, got %v, %v", l, err)
+		t.Fail()
+	}
+	if _, ok := store.index.added["b"][scoped("", "expired")]; ok {
+		t.Log("expected compaction to drop the expired idempotency key")
+		t.Fail()
+	}
+	l, _ := store.Get(ctx, "a")
+	if l.URL != "https://example.com/99" {
+		t.Logf("expected the last write to a to win, got %q", l.URL)
+		t.Fail()
+	}
+}
+
+func TestFileStoreMintOrder(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+
+	// z is minted before y and x, and updated after them, which doesn't
+	// make it any newer
+	store := openFileStore(t, dir)
+	store.Put(ctx, Link{Code: "z", URL: "https://example.com/"})
+	store.Put(ctx, Link{Code: "y", URL: "https://example.com/"})
+	store.Put(ctx, Link{Code: "x", URL: "https://example.com/"})
+	store.Update(ctx, "z", func(l *Link) error { l.MaxClicks = 5; return nil })
+
+	expect := func(when, want string) {
+		l, err := store.GetByURL(ctx, "", "https://example.com/")
+		if err != nil || l.Code != want {
+			t.Logf("%s: expected the most recently minted link %q, got %v, %v", when, want, l, err)
+			t.Fail()
+		}
+	}
+	expect("live", "x")
+	store.Close()
+	store = openFileStore(t, dir)
+	expect("replayed", "x")
+	store.Compact()
+	store.Close()
+	store = openFileStore(t, dir)
+	defer store.Close()
+	expect("compacted", "x")
+	store.Delete(ctx, "x")
+	expect("deleted", "y")
+}
+
+func TestFileStoreRepairIndex(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+
+	// deleting the link an entry points to points it to the older link that
+	// still has it
+	store := openFileStore(t, dir)
+	store.Put(ctx, Link{Code: "a", URL: "https://example.com/a"})
+	store.Put(ctx, Link{Code: "b", URL: "https://example.com/a"})
+	store.Delete(ctx, "b")
+	store.Put(ctx, Link{Code: "c", URL: "https://example.com/c", Owner: "alice", Key: "k"})
+	store.Put(ctx, Link{Code: "d", URL: "https://example.com/d", Owner: "alice", Key: "k"})
+	store.Delete(ctx, "d")
+	if problems := store.CheckIndex(); len(problems) > 0 {
+		t.Logf("expected deletes to leave the indexes consistent, got %+v", problems)
+		t.Fail()
+	}
+	store.Close()
+
+	store = openFileStore(t, dir)
+	if l, err := store.GetByURL(ctx, "", "https://example.com/a"); err != nil || l.Code != "a" {
+		t.Logf("expected the long URL to lead to a once b is deleted, got %v, %v", l, err)
+		t.Fail()
+	}
+	if l, err := store.GetByKey(ctx, "alice", "k"); err != nil || l.Code != "c" {
+		t.Logf("expected the key to lead to c once d is deleted, got %v, %v", l, err)
+		t.Fail()
+	}
+	// break the indexes the way older versions left them
+	delete(store.index.codes, scoped("", "https://example.com/a"))
+	delete(store.index.keys, scoped("alice", "k"))
+	store.index.codes[scoped("", "https://example.com/gone")] = "e"
+	problems := store.CheckIndex()
+	if len(problems) != 3 {
+		t.Logf("expected 3 problems, got %+v", problems)
+		t.FailNow()
+	}
+	for i, want := range []IndexProblem{
+		{Index: "url", Entry: "https://example.com/a", Links: []string{"a"}, Problem: ProblemMissing},
+		{Index: "url", Entry: "https://example.com/gone", Code: "e", Problem: ProblemDangling},
+		{Index: "key", Owner: "alice", Entry: "k", Links: []string{"c"}, Problem: ProblemMissing},
+	} {
+		if got := problems[i]; fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Logf("expected %+v, got %+v", want, got)
+			t.Fail()
+		}
+	}
+
+	if fixed, err := store.RepairIndex(); err != nil || len(fixed) != 3 {
+		t.Logf("expected the 3 problems to be fixed, got %+v, %v", fixed, err)
+		t.FailNow()
+	}
+	store.Close()
+
+	// the repaired indexes are rebuilt from the log
+	store = openFileStore(t, dir)
+	defer store.Close()
+	if problems := store.CheckIndex(); len(problems) > 0 {
+		t.Logf("expected no problems left, got %+v", problems)
+		t.Fail()
+	}
+	if l, err := store.GetByURL(ctx, "", "https://example.com/a"); err != nil || l.Code != "a" {
+		t.Logf("expected the long URL to lead to a again, got %v, %v", l, err)
+		t.Fail()
+	}
+	if l, err := store.GetByKey(ctx, "alice", "k"); err != nil || l.Code != "c" {
+		t.Logf("expected the key to lead to c again, got %v, %v", l, err)
+		t.Fail()
+	}
+}
+
+func TestFileStoreLocked(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	store := openFileStore(t, dir)
+	if _, err := NewFileStore(dir, 0); err != ErrLocked {
+		t.Logf("expected ErrLocked, got %v", err)
+		t.Fail()
+	}
+	store.Close()
+
+	store = openFileStore(t, dir)
+	store.Close()
+}
+
+// TestFileStoreKill kills a process writing to the store and checks that
+// every write it acknowledged made it to disk.
+func TestFileStoreKill(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	cmd := exec.Command(os.Args[0], "-test.run=TestFileStoreWriter")
+	cmd.Env = append(os.Environ(), "SHORTER_WRITER_DIR="+dir)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if err := cmd.Start(); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	var acked []string
+	scanner := bufio.NewScanner(out)
+	for scanner.Scan() && len(acked) < 200 {
+		acked = append(acked, scanner.Text())
+	}
+	cmd.Process.Kill()
+	cmd.Wait()
+
+	if len(acked) < 200 {
+		t.Log("writer exited before acknowledging 200 links")
+		t.FailNow()
+	}
+	store := openFileStore(t, dir)
+	defer store.Close()
+	expectLinks(t, store, acked...)
+}
+
+// TestFileStoreWriter is the process killed by TestFileStoreKill, it writes
+// links until it's stopped and prints the code of every acknowledged one.
+func TestFileStoreWriter(t *testing.T) {
+	dir := os.Getenv("SHORTER_WRITER_DIR")
+	if dir == "" {
+		t.Skip("only runs as part of TestFileStoreKill")
+	}
+	store, err := NewFileStore(dir, 0)
+	if err != nil {
+		os.Exit(1)
+	}
+	for i := 0; ; i++ {
+		code := fmt.Sprint(i)
+		if err := store.Put(context.Background(), Link{Code: code, URL: "https://example.com/" + code}); err != nil {
+			os.Exit(1)
+		}
+		fmt.Println(code)
+	}
+}
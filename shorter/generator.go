@@ -0,0 +1,104 @@
+package shorter
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+
+	"willnorris.com/go/newbase60"
+)
+
+// alphabet is the newbase60 character set, random codes are drawn from it so
+// they look like the ones the other generators mint.
+const alphabet = "0123456789ABCDEFGHJKLMNPQRSTUVWXYZ_abcdefghijkmnopqrstuvwxyz"
+
+// Generator mints the codes Shorten hands out. Codes don't need to be unique,
+// Shorten checks them against the store and asks for another one when a code
+// is taken.
+type Generator interface {
+	// Generate returns a code for addr. attempt is zero the first time and
+	// goes up by one every time the previous code turned out to be taken.
+	Generate(ctx context.Context, addr string, attempt int) (string, error)
+}
+
+// Counter hands out increasing numbers. Stores that can persist one implement
+// it so sequential codes carry on where they left off after a restart.
+type Counter interface {
+	Next(ctx context.Context) (uint64, error)
+}
+
+// NewHashGenerator returns a Generator that derives codes from the content of
+// the URL, so the same URL always gets the same code.
+//
+// The first attempt is the newbase60 encoded CRC32 of the URL, at most 6
+// characters long. Later attempts extend it with up to 3 characters taken from
+// a SHA-256 of the URL and the attempt number; CRC32 is linear, so salting it
+// wouldn't separate two URLs that already collide.
+func NewHashGenerator() *hashGenerator {
+	return &hashGenerator{}
+}
+
+type hashGenerator struct{}
+
+func (*hashGenerator) Generate(_ context.Context, addr string, attempt int) (string, error) {
+	code := newbase60.EncodeInt(int(crc32.ChecksumIEEE([]byte(addr))))
+	if attempt == 0 {
+		return code, nil
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d:%s", attempt, addr)))
+	return code + newbase60.EncodeInt(int(binary.BigEndian.Uint16(sum[:]))), nil
+}
+
+// NewSequentialGenerator returns a Generator that encodes the numbers handed
+// out by counter, giving the shortest codes possible: the first 59 links get a
+// single character, the next 3540 two and so on. Codes never repeat as long as
+// counter doesn't.
+func NewSequentialGenerator(counter Counter) *sequentialGenerator {
+	return &sequentialGenerator{counter: counter}
+}
+
+type sequentialGenerator struct {
+	counter Counter
+}
+
+func (g *sequentialGenerator) Generate(ctx context.Context, _ string, _ int) (string, error) {
+	n, err := g.counter.Next(ctx)
+	if err != nil {
+		return "", err
+	}
+	return newbase60.EncodeInt(int(n)), nil
+}
+
+// NewRandomGenerator returns a Generator of codes made of length characters
+// picked with crypto/rand, which makes them impossible to guess or enumerate.
+// There are 60^length codes, so with 7 characters two codes collide with a
+// probability of about one in 2.8 trillion.
+func NewRandomGenerator(length int) *randomGenerator {
+	return &randomGenerator{length: length}
+}
+
+type randomGenerator struct {
+	length int
+}
+
+func (g *randomGenerator) Generate(context.Context, string, int) (string, error) {
+	code := make([]byte, 0, g.length)
+	buf := make([]byte, g.length)
+	for len(code) < g.length {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			// 240 is the largest multiple of 60 that fits a byte, dropping
+			// anything above keeps every character equally likely.
+			if b >= 240 || len(code) == g.length {
+				continue
+			}
+			code = append(code, alphabet[b%60])
+		}
+	}
+	return string(code), nil
+}
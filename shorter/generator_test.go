@@ -0,0 +1,103 @@
+package shorter
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestHashGenerator(t *testing.T) {
+	g := NewHashGenerator()
+	ctx := context.Background()
+
+	// the same url always gets the same code
+	a, _ := g.Generate(ctx, plumless, 0)
+	b, _ := g.Generate(ctx, plumless, 0)
+	if a != b {
+		t.Logf("expected %q twice, got %q", a, b)
+		t.Fail()
+	}
+
+	// colliding urls share their first code but not the ones after it
+	c, _ := g.Generate(ctx, buckeroo, 0)
+	if a != c {
+		t.Logf("expected the crc32 collision to give %q, got %q", a, c)
+		t.Fail()
+	}
+	for attempt := 1; attempt < maxAttempts; attempt++ {
+		a, _ := g.Generate(ctx, plumless, attempt)
+		b, _ := g.Generate(ctx, buckeroo, attempt)
+		if a == b {
+			t.Logf("attempt %d: both urls were given %q", attempt, a)
+			t.Fail()
+		}
+		if len(a) > 9 {
+			t.Logf("attempt %d: expected at most 9 characters, got %q", attempt, a)
+			t.Fail()
+		}
+	}
+	if len(a) > 6 {
+		t.Logf("expected at most 6 characters on the first attempt, got %q", a)
+		t.Fail()
+	}
+}
+
+func TestSequentialGenerator(t *testing.T) {
+	g := NewSequentialGenerator(NewMemoryStore())
+	ctx := context.Background()
+
+	seen := make(map[string]bool)
+	for i := 1; i <= 3600; i++ {
+		code, err := g.Generate(ctx, "https://example.com", 0)
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+		if seen[code] {
+			t.Logf("%q was handed out twice", code)
+			t.FailNow()
+		}
+		seen[code] = true
+
+		// codes only grow a character once every shorter one is used up
+		want := 1
+		if i >= 60 {
+			want = 2
+		}
+		if i >= 3600 {
+			want = 3
+		}
+		if len(code) != want {
+			t.Logf("code %d: expected %d characters, got %q", i, want, code)
+			t.FailNow()
+		}
+	}
+}
+
+func TestRandomGenerator(t *testing.T) {
+	g := NewRandomGenerator(7)
+	ctx := context.Background()
+
+	seen := make(map[string]bool)
+	for i := 0; i < 10000; i++ {
+		code, err := g.Generate(ctx, "https://example.com", 0)
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+		if len(code) != 7 {
+			t.Logf("expected 7 characters, got %q", code)
+			t.FailNow()
+		}
+		if strings.Trim(code, alphabet) != "" {
+			t.Logf("%q has characters outside of newbase60", code)
+			t.FailNow()
+		}
+		// 10k codes out of 60^7 collide once in roughly 56k runs
+		if seen[code] {
+			t.Logf("%q was handed out twice", code)
+			t.FailNow()
+		}
+		seen[code] = true
+	}
+}
@@ -0,0 +1,56 @@
+package shorter
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/jennyservices/jenny/auth"
+	jennyhttp "github.com/jennyservices/jenny/http"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// IdempotencyHeader is the HTTP header clients send an idempotency key
+	// in. Retrying a request with the same key returns the link the first
+	// request minted instead of minting another.
+	IdempotencyHeader = "Idempotency-Key"
+
+	// IdempotencyMetadata is the gRPC metadata key for the same thing.
+	IdempotencyMetadata = "idempotency-key"
+
+	maxKeyLength = 255
+
+	// keyTTL is how long a key is remembered when its request was answered
+	// with a link minted before, so clients sending a fresh key with every
+	// request don't grow the store forever. A link minted with a key keeps
+	// it as long as the link lives.
+	keyTTL = 24 * time.Hour
+)
+
+// idempotencyKey returns the idempotency key the request in ctx was sent
+// with, or "" if there isn't one.
+func idempotencyKey(ctx context.Context) string {
+	if h, ok := ctx.Value(jennyhttp.ContextKeyRequestHeaders).(http.Header); ok {
+		if key := h.Get(IdempotencyHeader); key != "" {
+			return key
+		}
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if keys := md.Get(IdempotencyMetadata); len(keys) > 0 {
+			return keys[0]
+		}
+	}
+	return ""
+}
+
+// owner identifies the user the request in ctx was made by, or returns ""
+// for anonymous requests.
+func owner(ctx context.Context) string {
+	u, err := auth.ContextUser(ctx)
+	if err != nil {
+		return ""
+	}
+	return hex.EncodeToString(u.UniqueID())
+}
@@ -0,0 +1,26 @@
+//go:build !windows
+// +build !windows
+
+package shorter
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile takes an exclusive lock on path, which is held until the returned
+// file is closed.
+func lockFile(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if err == syscall.EWOULDBLOCK {
+			return nil, ErrLocked
+		}
+		return nil, err
+	}
+	return f, nil
+}
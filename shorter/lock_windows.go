@@ -0,0 +1,9 @@
+package shorter
+
+import "os"
+
+// lockFile opens path. Windows doesn't lock the data directory, a second
+// process opening it will corrupt the log.
+func lockFile(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+}
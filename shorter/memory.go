@@ -0,0 +1,521 @@
+package shorter
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NewMemoryStore returns a Store that keeps every link in memory. Links are
+// lost when the process exits, so it's meant for tests and local development.
+func NewMemoryStore() *memoryStore {
+	return &memoryStore{
+		links:  make(map[string]Link),
+		codes:  make(map[string]string),
+		keys:   make(map[string]string),
+		added:  make(map[string]map[string]time.Time),
+		minted: make(map[string]uint64),
+		owned:  make(map[string]map[string]bool),
+		urls:   make(map[string]map[string]bool),
+		keyed:  make(map[string]map[string]bool),
+	}
+}
+
+type memoryStore struct {
+	mu    sync.RWMutex
+	links map[string]Link   // code → link
+	codes map[string]string // owner and long url → code
+	keys  map[string]string // owner and idempotency key → code
+	// added are the keys of links recorded with AddKey rather than as
+	// their Key, as owner and idempotency key by code, along with when
+	// they expire.
+	added map[string]map[string]time.Time
+	// minted orders the links by when they were put in the store, so
+	// entries shared by several links point to the one minted last.
+	minted map[string]uint64 // code → order
+	mints  uint64
+	owned  map[string]map[string]bool // owner → codes
+	// urls and keyed are the codes of every link with a long url or
+	// idempotency key, so codes and keys can be pointed to another one
+	// when the link they point to goes.
+	urls  map[string]map[string]bool // owner and long url → codes
+	keyed map[string]map[string]bool // owner and idempotency key → codes
+	next  uint64
+}
+
+// scoped joins owner and a url or idempotency key into an index key, so
+// owners never see each other's links.
+func scoped(owner, s string) string {
+	return owner + "\x00" + s
+}
+
+// unscoped splits an index key made by scoped back up.
+func unscoped(k string) (owner, s string) {
+	i := strings.IndexByte(k, 0)
+	return k[:i], k[i+1:]
+}
+
+func (m *memoryStore) Put(_ context.Context, l Link) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.put(l)
+	return nil
+}
+
+func (m *memoryStore) Add(_ context.Context, l Link) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.conflict(l); err != nil {
+		return err
+	}
+	m.put(l)
+	return nil
+}
+
+// conflict returns the error Add fails with for l, if any. It must be called
+// with m.mu held.
+func (m *memoryStore) conflict(l Link) error {
+	if _, ok := m.links[l.Code]; ok {
+		return ErrExists
+	}
+	if l.Key != "" {
+		if _, ok := m.liveKey(scoped(l.Owner, l.Key), time.Now()); ok {
+			return ErrKeyExists
+		}
+	}
+	return nil
+}
+
+// put must be called with m.mu held.
+func (m *memoryStore) put(l Link) {
+	if old, ok := m.links[l.Code]; ok {
+		m.unindex(old)
+	} else {
+		m.mints++
+		m.minted[l.Code] = m.mints
+	}
+	m.links[l.Code] = l
+	m.index(l)
+}
+
+func (m *memoryStore) Update(_ context.Context, code string, update func(*Link) error) (*Link, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	l, ok := m.links[code]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if err := update(&l); err != nil {
+		return nil, err
+	}
+	l.Code = code
+	m.put(l)
+	return &l, nil
+}
+
+func (m *memoryStore) AddKey(_ context.Context, owner, key, code string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.addKey(owner, key, code, expiresAt)
+}
+
+// addKey must be called with m.mu held.
+func (m *memoryStore) addKey(owner, key, code string, expiresAt time.Time) error {
+	k := scoped(owner, key)
+	if existing, ok := m.liveKey(k, time.Now()); ok {
+		if existing == code {
+			return nil
+		}
+		return ErrKeyExists
+	}
+	if _, ok := m.links[code]; !ok {
+		return ErrNotFound
+	}
+	m.dropKey(k)
+	m.keys[k] = code
+	if m.added[code] == nil {
+		m.added[code] = make(map[string]time.Time)
+	}
+	m.added[code][k] = expiresAt
+	addCode(m.keyed, k, code)
+	return nil
+}
+
+// liveKey returns the code the entry for k points to, unless it was added
+// with AddKey and expired by now. It must be called with m.mu held.
+func (m *memoryStore) liveKey(k string, now time.Time) (string, bool) {
+	code, ok := m.keys[k]
+	if !ok {
+		return "", false
+	}
+	if exp, added := m.added[code][k]; added && !exp.IsZero() && !now.Before(exp) {
+		return "", false
+	}
+	return code, true
+}
+
+// dropKey forgets that k was added to the link its entry points to, if it
+// was. It must be called with m.mu held.
+func (m *memoryStore) dropKey(k string) {
+	code, ok := m.keys[k]
+	if _, added := m.added[code][k]; !ok || !added {
+		return
+	}
+	delete(m.added[code], k)
+	if len(m.added[code]) == 0 {
+		delete(m.added, code)
+	}
+	m.unshare(m.keys, m.keyed, k, code)
+}
+
+func (m *memoryStore) ReapKeys(_ context.Context, now time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.reapKeys(now), nil
+}
+
+// reapKeys drops the keys added with AddKey that expired by now and returns
+// how many. It must be called with m.mu held.
+func (m *memoryStore) reapKeys(now time.Time) int {
+	var expired []string
+	for _, keys := range m.added {
+		for k, exp := range keys {
+			if !exp.IsZero() && !now.Before(exp) {
+				expired = append(expired, k)
+			}
+		}
+	}
+	for _, k := range expired {
+		m.dropKey(k)
+	}
+	return len(expired)
+}
+
+// index adds l to the reverse entries for it, pointing them to l unless they
+// point to a link minted after it. It must be called with m.mu held.
+func (m *memoryStore) index(l Link) {
+	addCode(m.owned, l.Owner, l.Code)
+	k := scoped(l.Owner, l.URL)
+	m.point(m.codes, k, l.Code)
+	addCode(m.urls, k, l.Code)
+	if l.Key != "" {
+		k := scoped(l.Owner, l.Key)
+		if _, ok := m.liveKey(k, time.Now()); !ok {
+			m.dropKey(k)
+		}
+		m.point(m.keys, k, l.Code)
+		addCode(m.keyed, k, l.Code)
+	}
+}
+
+// point points the entry for k in index to code, unless it points to a link
+// minted after it. It must be called with m.mu held.
+func (m *memoryStore) point(index map[string]string, k, code string) {
+	if other, ok := index[k]; !ok || m.minted[other] < m.minted[code] {
+		index[k] = code
+	}
+}
+
+// unindex drops the reverse entries for l. Those pointing to l are pointed to
+// the most recently minted of the links left sharing them. It must be called
+// with m.mu held.
+func (m *memoryStore) unindex(l Link) {
+	removeCode(m.owned, l.Owner, l.Code)
+	m.unshare(m.codes, m.urls, scoped(l.Owner, l.URL), l.Code)
+	if l.Key != "" {
+		m.unshare(m.keys, m.keyed, scoped(l.Owner, l.Key), l.Code)
+	}
+}
+
+// unshare drops code from the codes sharing k, and points the entry for k in
+// index to the most recently minted of the others if it pointed to code. It
+// must be called with m.mu held.
+func (m *memoryStore) unshare(index map[string]string, shared map[string]map[string]bool, k, code string) {
+	removeCode(shared, k, code)
+	if index[k] != code {
+		return
+	}
+	delete(index, k)
+	for other := range shared[k] {
+		m.point(index, k, other)
+	}
+}
+
+// addCode adds code to the set of codes under k.
+func addCode(sets map[string]map[string]bool, k, code string) {
+	if sets[k] == nil {
+		sets[k] = make(map[string]bool)
+	}
+	sets[k][code] = true
+}
+
+// removeCode removes code from the set of codes under k.
+func removeCode(sets map[string]map[string]bool, k, code string) {
+	delete(sets[k], code)
+	if len(sets[k]) == 0 {
+		delete(sets, k)
+	}
+}
+
+// mintedCodes returns the code of every link in the order they were minted.
+// It must be called with m.mu held.
+func (m *memoryStore) mintedCodes() []string {
+	codes := make([]string, 0, len(m.links))
+	for code := range m.links {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool { return m.minted[codes[i]] < m.minted[codes[j]] })
+	return codes
+}
+
+// sortedCodes returns the code of every link in order. It must be called with
+// m.mu held.
+func (m *memoryStore) sortedCodes() []string {
+	codes := make([]string, 0, len(m.links))
+	for code := range m.links {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// checkIndex returns the reverse entries that disagree with the links. It
+// must be called with m.mu held.
+func (m *memoryStore) checkIndex() []IndexProblem {
+	wantCodes := make(map[string][]string)
+	wantKeys := make(map[string][]string)
+	for _, code := range m.sortedCodes() {
+		l := m.links[code]
+		k := scoped(l.Owner, l.URL)
+		wantCodes[k] = append(wantCodes[k], code)
+		if l.Key != "" {
+			k := scoped(l.Owner, l.Key)
+			wantKeys[k] = append(wantKeys[k], code)
+		}
+		for k := range m.added[code] {
+			wantKeys[k] = append(wantKeys[k], code)
+		}
+	}
+	problems := append(checkEntries("url", m.codes, wantCodes), checkEntries("key", m.keys, wantKeys)...)
+	sort.Slice(problems, func(i, j int) bool {
+		a, b := problems[i], problems[j]
+		if a.Index != b.Index {
+			return a.Index > b.Index
+		}
+		if a.Owner != b.Owner {
+			return a.Owner < b.Owner
+		}
+		return a.Entry < b.Entry
+	})
+	return problems
+}
+
+// checkEntries compares the entries of index to the codes of the links each
+// should point to one of.
+func checkEntries(name string, index map[string]string, want map[string][]string) []IndexProblem {
+	var problems []IndexProblem
+	problem := func(k, code string, links []string, what string) {
+		owner, entry := unscoped(k)
+		problems = append(problems, IndexProblem{Index: name, Owner: owner, Entry: entry, Code: code, Links: links, Problem: what})
+	}
+	for k, links := range want {
+		code, ok := index[k]
+		switch {
+		case !ok:
+			problem(k, "", links, ProblemMissing)
+		case !contains(links, code):
+			problem(k, code, links, ProblemWrong)
+		}
+	}
+	for k, code := range index {
+		if _, ok := want[k]; !ok {
+			problem(k, code, nil, ProblemDangling)
+		}
+	}
+	return problems
+}
+
+func contains(ss []string, s string) bool {
+	for _, x := range ss {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// reindex rebuilds the reverse entries from the links, pointing those shared
+// by several links to the one minted last, as put does. It must be called
+// with m.mu held.
+func (m *memoryStore) reindex() {
+	m.codes = make(map[string]string)
+	m.keys = make(map[string]string)
+	m.owned = make(map[string]map[string]bool)
+	m.urls = make(map[string]map[string]bool)
+	m.keyed = make(map[string]map[string]bool)
+	for _, code := range m.mintedCodes() {
+		m.index(m.links[code])
+		for k := range m.added[code] {
+			m.point(m.keys, k, code)
+			addCode(m.keyed, k, code)
+		}
+	}
+}
+
+func (m *memoryStore) Get(_ context.Context, code string) (*Link, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	l, ok := m.links[code]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &l, nil
+}
+
+func (m *memoryStore) GetByURL(_ context.Context, owner, url string) (*Link, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.lookup(m.codes, scoped(owner, url))
+}
+
+func (m *memoryStore) GetByKey(_ context.Context, owner, key string) (*Link, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	code, ok := m.liveKey(scoped(owner, key), time.Now())
+	if !ok {
+		return nil, ErrNotFound
+	}
+	l := m.links[code]
+	return &l, nil
+}
+
+// lookup returns the link index points k to. It must be called with m.mu
+// held.
+func (m *memoryStore) lookup(index map[string]string, k string) (*Link, error) {
+	code, ok := index[k]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	l := m.links[code]
+	return &l, nil
+}
+
+func (m *memoryStore) Delete(_ context.Context, code string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.delete(code)
+	return nil
+}
+
+// delete must be called with m.mu held.
+func (m *memoryStore) delete(code string) {
+	l, ok := m.links[code]
+	if !ok {
+		return
+	}
+	delete(m.links, code)
+	m.unindex(l)
+	for k := range m.added[code] {
+		m.unshare(m.keys, m.keyed, k, code)
+	}
+	delete(m.added, code)
+	delete(m.minted, code)
+}
+
+func (m *memoryStore) Exists(_ context.Context, code string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.links[code]
+	return ok, nil
+}
+
+func (m *memoryStore) ExistsURL(_ context.Context, owner, url string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.codes[scoped(owner, url)]
+	return ok, nil
+}
+
+func (m *memoryStore) Next(context.Context) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.next++
+	return m.next, nil
+}
+
+func (m *memoryStore) Hit(_ context.Context, code string) (*Link, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.hit(code) {
+		return nil, ErrNotFound
+	}
+	l := m.links[code]
+	return &l, nil
+}
+
+// hit must be called with m.mu held.
+func (m *memoryStore) hit(code string) bool {
+	l, ok := m.links[code]
+	if !ok {
+		return false
+	}
+	l.Clicks++
+	m.links[code] = l
+	return true
+}
+
+func (m *memoryStore) List(_ context.Context, after string, limit int) ([]Link, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	codes := make([]string, 0, len(m.links))
+	for code := range m.links {
+		if code > after {
+			codes = append(codes, code)
+		}
+	}
+	return m.page(codes, limit), nil
+}
+
+func (m *memoryStore) ListByOwner(_ context.Context, owner, after string, limit int) ([]Link, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var codes []string
+	for code := range m.owned[owner] {
+		if code > after {
+			codes = append(codes, code)
+		}
+	}
+	return m.page(codes, limit), nil
+}
+
+// page returns the links of the first limit of codes in order. It must be
+// called with m.mu held.
+func (m *memoryStore) page(codes []string, limit int) []Link {
+	sort.Strings(codes)
+	if limit > 0 && len(codes) > limit {
+		codes = codes[:limit]
+	}
+
+	links := make([]Link, len(codes))
+	for i, code := range codes {
+		links[i] = m.links[code]
+	}
+	return links
+}
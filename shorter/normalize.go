@@ -0,0 +1,131 @@
+package shorter
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// defaultPorts are the ports that are implied by a scheme and dropped from
+// the host.
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// Normalizer validates the URLs handed to Shorten and rewrites them in a
+// canonical form, so that URLs that only differ in spelling get the same code.
+type Normalizer struct {
+	// Schemes are the schemes a URL can have, http and https when empty.
+	Schemes []string
+
+	// SortQuery orders query parameters by name. Most servers don't care about
+	// the order, but some do, which is why it's off by default.
+	SortQuery bool
+}
+
+// Normalize returns the canonical form of raw, or InvalidURL when raw isn't an
+// absolute URL with an allowed scheme and a host.
+//
+// The scheme and host are lowercased, international domain names are
+// converted to punycode, the port is dropped when it's the default for the
+// scheme and "." and ".." segments are removed from the path.
+func (n *Normalizer) Normalize(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", InvalidURL{URL: raw, Reason: "it's empty"}
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", InvalidURL{URL: raw, Reason: "it can't be parsed"}
+	}
+	if u.Scheme == "" {
+		return "", InvalidURL{URL: raw, Reason: "it has no scheme, try https://" + raw}
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if !n.allowed(u.Scheme) {
+		return "", InvalidURL{URL: raw, Reason: u.Scheme + " URLs can't be shortened"}
+	}
+	if u.Opaque != "" || u.Host == "" {
+		return "", InvalidURL{URL: raw, Reason: "it has no host"}
+	}
+
+	host, err := normalizeHost(u.Hostname())
+	if err != nil {
+		return "", InvalidURL{URL: raw, Reason: "its host isn't a valid domain name"}
+	}
+	if port := u.Port(); port != "" && port != defaultPorts[u.Scheme] {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	u.Host = host
+
+	path := removeDotSegments(u.EscapedPath())
+	if path == "" {
+		path = "/"
+	}
+	if u.Path, err = url.PathUnescape(path); err != nil {
+		return "", InvalidURL{URL: raw, Reason: "its path can't be parsed"}
+	}
+	u.RawPath = path
+
+	if n.SortQuery && u.RawQuery != "" {
+		query, err := url.ParseQuery(u.RawQuery)
+		if err != nil {
+			return "", InvalidURL{URL: raw, Reason: "its query can't be parsed"}
+		}
+		u.RawQuery = query.Encode()
+	}
+	return u.String(), nil
+}
+
+func (n *Normalizer) allowed(scheme string) bool {
+	schemes := n.Schemes
+	if len(schemes) == 0 {
+		schemes = []string{"http", "https"}
+	}
+	for _, s := range schemes {
+		if strings.EqualFold(s, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeHost lowercases host and converts it to its ASCII form. IP
+// addresses are left as they are.
+func normalizeHost(host string) (string, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String(), nil
+	}
+	host = strings.TrimSuffix(host, ".")
+	return idna.Lookup.ToASCII(strings.ToLower(host))
+}
+
+// removeDotSegments implements section 5.2.4 of RFC 3986.
+func removeDotSegments(path string) string {
+	var out []string
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		last := i == len(segments)-1
+		switch s {
+		case ".":
+			if last {
+				out = append(out, "")
+			}
+		case "..":
+			if len(out) > 1 {
+				out = out[:len(out)-1]
+			}
+			if last {
+				out = append(out, "")
+			}
+		default:
+			out = append(out, s)
+		}
+	}
+	return strings.Join(out, "/")
+}
@@ -2,19 +2,562 @@ package shorter
 
 import (
 	"context"
-	"hash/crc32"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
 
 	v1 "github.com/jennyservices/shorter/transport/v1"
-	"willnorris.com/go/newbase60"
 )
 
-func New() *shorter {
-	return &shorter{}
+const (
+	// maxAttempts is how many codes Shorten tries for a URL before giving up.
+	maxAttempts = 10
+
+	defaultMaxBatch         = 1000
+	defaultBatchParallelism = 8
+
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+// New returns a shorter service that keeps the links it mints in store. Codes
+// are derived from a hash of the URL unless WithGenerator says otherwise, and
+// only http and https URLs are accepted unless WithNormalizer does.
+func New(store Store, opts ...Option) *shorter {
+	s := &shorter{
+		store:      store,
+		generator:  NewHashGenerator(),
+		normalizer: &Normalizer{},
+
+		maxBatch:         defaultMaxBatch,
+		batchParallelism: defaultBatchParallelism,
+	}
+	for _, optf := range opts {
+		optf(s)
+	}
+	return s
+}
+
+// Option configures the shorter service returned by New.
+type Option func(*shorter)
+
+// WithGenerator sets the Generator codes are minted with.
+func WithGenerator(g Generator) Option {
+	return func(s *shorter) { s.generator = g }
+}
+
+// WithNormalizer sets the Normalizer URLs are validated and canonicalised
+// with before they're shortened.
+func WithNormalizer(n *Normalizer) Option {
+	return func(s *shorter) { s.normalizer = n }
+}
+
+// WithMaxBatch sets how many URLs ShortenBatch accepts at once.
+func WithMaxBatch(n int) Option {
+	return func(s *shorter) { s.maxBatch = n }
+}
+
+// WithBatchParallelism sets how many URLs of a batch are shortened
+// concurrently.
+func WithBatchParallelism(n int) Option {
+	return func(s *shorter) {
+		if n > 0 {
+			s.batchParallelism = n
+		}
+	}
+}
+
+// WithBaseURL makes Shorten return short links as base followed by the
+// code, instead of the bare code. Expand accepts either.
+func WithBaseURL(base string) Option {
+	return func(s *shorter) { s.baseURL = strings.TrimSuffix(base, "/") }
 }
 
-type shorter struct{}
+type shorter struct {
+	store      Store
+	generator  Generator
+	normalizer *Normalizer
+	baseURL    string
 
+	maxBatch         int
+	batchParallelism int
+}
+
+// ValidRedirect reports whether code is a HTTP status links can redirect with.
+func ValidRedirect(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently,
+		http.StatusFound,
+		http.StatusTemporaryRedirect,
+		http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// Shorten mints a code for u, or returns the one already minted for it. Store
+// failures come back as Internal so callers only ever see the errors in
+// errors.go.
 func (s *shorter) Shorten(ctx context.Context, u v1.URL) (*v1.URL, error) {
-	i := crc32.ChecksumIEEE([]byte(u.Addr))
-	return &v1.URL{Addr: newbase60.EncodeInt(int(i))}, nil
-}
\ No newline at end of file
+	short, err := s.shorten(ctx, u, idempotencyKey(ctx))
+	return short, internal(err)
+}
+
+// ShortenBatch shortens every URL in longs, at most WithBatchParallelism at a
+// time, and reports how each one went. A key the batch was sent with is
+// suffixed with each URL's index, so retrying the batch is idempotent too.
+// Once ctx is done no more URLs are started and the batch fails with ctx's
+// error, leaving the ones already shortened to a retry.
+func (s *shorter) ShortenBatch(ctx context.Context, longs []v1.URL) ([]v1.BatchResult, error) {
+	if len(longs) > s.maxBatch {
+		return nil, Invalid{Field: "longs", Reason: fmt.Sprintf("can't have more than %d URLs", s.maxBatch)}
+	}
+
+	key := idempotencyKey(ctx)
+	// the longest suffix is that of the last URL
+	if suffix := len(fmt.Sprintf("/%d", len(longs)-1)); key != "" && len(key)+suffix > maxKeyLength {
+		return nil, Invalid{Field: IdempotencyHeader, Reason: fmt.Sprintf("can't be longer than %d characters for a batch of %d URLs", maxKeyLength-suffix, len(longs))}
+	}
+	results := make([]v1.BatchResult, len(longs))
+	sem := make(chan struct{}, s.batchParallelism)
+	var wg sync.WaitGroup
+	for i := 0; i < len(longs) && ctx.Err() == nil; i++ {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer func() { <-sem; wg.Done() }()
+
+			itemKey := ""
+			if key != "" {
+				itemKey = fmt.Sprintf("%s/%d", key, i)
+			}
+			short, err := s.shorten(ctx, longs[i], itemKey)
+			if err != nil {
+				body := errorBody(internal(err))
+				results[i].Error = &body
+				return
+			}
+			results[i].URL = short
+		}(i)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (s *shorter) shorten(ctx context.Context, u v1.URL, key string) (*v1.URL, error) {
+	if u.Redirect != 0 && !ValidRedirect(u.Redirect) {
+		return nil, Invalid{Field: "redirect", Reason: "must be one of 301, 302, 307 or 308"}
+	}
+	if u.ExpiresAt != nil && !u.ExpiresAt.After(time.Now()) {
+		return nil, Invalid{Field: "expires_at", Reason: "must be in the future"}
+	}
+	if u.MaxClicks < 0 {
+		return nil, Invalid{Field: "max_clicks", Reason: "can't be negative"}
+	}
+	addr, err := s.normalizer.Normalize(u.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	l := Link{
+		URL:       addr,
+		Owner:     owner(ctx),
+		Key:       key,
+		Redirect:  u.Redirect,
+		MaxClicks: u.MaxClicks,
+	}
+	if u.ExpiresAt != nil {
+		l.ExpiresAt = *u.ExpiresAt
+	}
+	if len(l.Key) > maxKeyLength {
+		return nil, Invalid{Field: IdempotencyHeader, Reason: fmt.Sprintf("can't be longer than %d characters", maxKeyLength)}
+	}
+	if l.Key != "" {
+		if v, err := s.byKey(ctx, &l, u.Alias); err != ErrNotFound {
+			return v, err
+		}
+	}
+	if u.Alias != "" {
+		return s.shortenAlias(ctx, l, u.Alias)
+	}
+
+	// Hand out the link the owner already has for the URL rather than
+	// minting another one, as long as it still works.
+	existing, err := s.store.GetByURL(ctx, l.Owner, addr)
+	if err == nil && sameTarget(existing, &l) && !existing.Expired(time.Now()) {
+		return s.reuse(ctx, &l, existing, "")
+	} else if err != nil && err != ErrNotFound {
+		return nil, err
+	}
+
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		code, err := s.generator.Generate(ctx, addr, attempt)
+		if err != nil {
+			return nil, err
+		}
+		l.Code = code
+		err = s.store.Add(ctx, l)
+		if err == ErrKeyExists {
+			return s.byKey(ctx, &l, "") // a retry of this request beat us to it
+		}
+		if err == ErrExists {
+			existing, err := s.store.Get(ctx, l.Code)
+			if err != nil {
+				return nil, err
+			}
+			if !sameTarget(existing, &l) {
+				continue // taken by another link, try the next code
+			}
+			return s.reuse(ctx, &l, existing, "")
+		} else if err != nil {
+			return nil, err
+		}
+		return s.toShort(&l), nil
+	}
+	return nil, fmt.Errorf("no free code for %q after %d attempts", addr, maxAttempts)
+}
+
+// shortenAlias stores l under the alias it asked for. Asking for an alias
+// that already points to the same link isn't an error.
+func (s *shorter) shortenAlias(ctx context.Context, l Link, alias string) (*v1.URL, error) {
+	if err := validateAlias(alias); err != nil {
+		return nil, err
+	}
+
+	l.Code = alias
+	err := s.store.Add(ctx, l)
+	if err == ErrKeyExists {
+		return s.byKey(ctx, &l, alias)
+	}
+	if err == ErrExists {
+		existing, err := s.store.Get(ctx, l.Code)
+		if err != nil {
+			return nil, err
+		}
+		if !sameTarget(existing, &l) {
+			return nil, AliasTaken{Alias: alias}
+		}
+		return s.reuse(ctx, &l, existing, alias)
+	} else if err != nil {
+		return nil, err
+	}
+	u := s.toShort(&l)
+	u.Alias = alias
+	return u, nil
+}
+
+// reuse hands out existing in place of l, recording l's idempotency key
+// against it so the key can't be used for another request later.
+func (s *shorter) reuse(ctx context.Context, l *Link, existing *Link, alias string) (*v1.URL, error) {
+	if l.Key != "" && l.Key != existing.Key {
+		err := s.store.AddKey(ctx, l.Owner, l.Key, existing.Code, time.Now().Add(keyTTL))
+		if err == ErrKeyExists {
+			return s.byKey(ctx, l, alias) // a retry of this request beat us to it
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	u := s.toShort(existing)
+	u.Alias = alias
+	return u, nil
+}
+
+// byKey returns the link minted by an earlier request with l's idempotency
+// key, or ErrNotFound if there wasn't one. Reusing a key for a different
+// request is an error.
+func (s *shorter) byKey(ctx context.Context, l *Link, alias string) (*v1.URL, error) {
+	existing, err := s.store.GetByKey(ctx, l.Owner, l.Key)
+	if err != nil {
+		return nil, err
+	}
+	if !sameTarget(existing, l) || alias != "" && existing.Code != alias {
+		return nil, Conflict{Reason: IdempotencyHeader + " was already used for a different request"}
+	}
+	u := s.toShort(existing)
+	u.Alias = alias
+	return u, nil
+}
+
+// sameTarget reports whether a and b were shortened with the same settings,
+// in which case one can be handed out in place of the other.
+func sameTarget(a, b *Link) bool {
+	return a.URL == b.URL &&
+		a.Owner == b.Owner &&
+		a.Redirect == b.Redirect &&
+		a.ExpiresAt.Equal(b.ExpiresAt) &&
+		a.MaxClicks == b.MaxClicks
+}
+
+// Expand counts a click on the link behind code and returns the URL it points
+// to, or Gone when the link has expired.
+func (s *shorter) Expand(ctx context.Context, code string) (*v1.URL, error) {
+	if s.baseURL != "" {
+		code = strings.TrimPrefix(code, s.baseURL+"/")
+	}
+	// a link that's gone doesn't count clicks, so following it over and
+	// over doesn't keep writing to the store
+	l, err := s.store.Get(ctx, code)
+	if err == nil && l.Expired(time.Now()) {
+		return nil, Gone{Code: code}
+	}
+	if err == nil {
+		l, err = s.store.Hit(ctx, code)
+	}
+	if err == ErrNotFound {
+		return nil, NotFound{Code: code}
+	}
+	if err != nil {
+		return nil, internal(err)
+	}
+	// Clicks already includes this one, so the link is only gone once it
+	// goes over MaxClicks.
+	if l.pastExpiry(time.Now()) || l.MaxClicks > 0 && l.Clicks > l.MaxClicks {
+		return nil, Gone{Code: code}
+	}
+	return toURL(l.URL, l), nil
+}
+
+// Resolve returns what Expand would for code without counting a click, for
+// requests that only look at where a link leads, like HEAD ones.
+func (s *shorter) Resolve(ctx context.Context, code string) (*v1.URL, error) {
+	if s.baseURL != "" {
+		code = strings.TrimPrefix(code, s.baseURL+"/")
+	}
+	l, err := s.store.Get(ctx, code)
+	if err == ErrNotFound {
+		return nil, NotFound{Code: code}
+	}
+	if err != nil {
+		return nil, internal(err)
+	}
+	if l.Expired(time.Now()) {
+		return nil, Gone{Code: code}
+	}
+	return toURL(l.URL, l), nil
+}
+
+// Info describes the link behind code without counting a click. Only the
+// link's owner can see it.
+func (s *shorter) Info(ctx context.Context, code string) (*v1.Link, error) {
+	l, err := s.owned(ctx, code)
+	if err != nil {
+		return nil, internal(err)
+	}
+	return s.toLink(l), nil
+}
+
+// List returns a page of the caller's links ordered by code, starting after
+// the code after. A page holds 100 links unless limit says otherwise, and
+// Next is set to the code to continue from while there may be more.
+func (s *shorter) List(ctx context.Context, after string, limit int) (*v1.LinkPage, error) {
+	if limit < 0 || limit > maxListLimit {
+		return nil, Invalid{Field: "limit", Reason: fmt.Sprintf("must be between 0 and %d", maxListLimit)}
+	}
+	if limit == 0 {
+		limit = defaultListLimit
+	}
+
+	links, err := s.store.ListByOwner(ctx, owner(ctx), after, limit)
+	if err != nil {
+		return nil, internal(err)
+	}
+	page := &v1.LinkPage{Links: make([]v1.Link, len(links))}
+	for i := range links {
+		page.Links[i] = *s.toLink(&links[i])
+	}
+	if len(links) == limit {
+		page.Next = links[len(links)-1].Code
+	}
+	return page, nil
+}
+
+// Update applies the fields set in changes to the link behind code, and
+// resets those named in changes.Clear, leaving the others as they are. A
+// link's code can't be changed, so neither can its alias.
+func (s *shorter) Update(ctx context.Context, code string, changes v1.URL) (*v1.Link, error) {
+	l, err := s.owned(ctx, code)
+	if err != nil {
+		return nil, internal(err)
+	}
+	if changes.Alias != "" {
+		return nil, Invalid{Field: "alias", Reason: "can't be changed"}
+	}
+	clear := make(map[string]bool, len(changes.Clear))
+	for _, field := range changes.Clear {
+		set := false
+		switch field {
+		case "redirect":
+			set = changes.Redirect != 0
+		case "expires_at":
+			set = changes.ExpiresAt != nil
+		case "max_clicks":
+			set = changes.MaxClicks != 0
+		default:
+			return nil, Invalid{Field: "clear", Reason: fmt.Sprintf("%q can't be cleared, only redirect, expires_at and max_clicks can", field)}
+		}
+		if set {
+			return nil, Invalid{Field: "clear", Reason: fmt.Sprintf("%s can't be both set and cleared", field)}
+		}
+		clear[field] = true
+	}
+	if changes.Redirect != 0 && !ValidRedirect(changes.Redirect) {
+		return nil, Invalid{Field: "redirect", Reason: "must be one of 301, 302, 307 or 308"}
+	}
+	if changes.ExpiresAt != nil && !changes.ExpiresAt.After(time.Now()) {
+		return nil, Invalid{Field: "expires_at", Reason: "must be in the future"}
+	}
+	if changes.MaxClicks < 0 {
+		return nil, Invalid{Field: "max_clicks", Reason: "can't be negative"}
+	}
+	var addr string
+	if changes.Addr != "" {
+		if addr, err = s.normalizer.Normalize(changes.Addr); err != nil {
+			return nil, err
+		}
+	}
+
+	// the store applies the changes so clicks counted since l was read
+	// aren't lost
+	code = l.Code
+	l, err = s.store.Update(ctx, code, func(l *Link) error {
+		if changes.Redirect != 0 || clear["redirect"] {
+			l.Redirect = changes.Redirect
+		}
+		if changes.ExpiresAt != nil {
+			l.ExpiresAt = *changes.ExpiresAt
+		} else if clear["expires_at"] {
+			l.ExpiresAt = time.Time{}
+		}
+		if changes.MaxClicks > 0 || clear["max_clicks"] {
+			l.MaxClicks = changes.MaxClicks
+		}
+		if addr != "" {
+			l.URL = addr
+		}
+		return nil
+	})
+	if err == ErrNotFound {
+		return nil, NotFound{Code: code}
+	}
+	if err != nil {
+		return nil, internal(err)
+	}
+	return s.toLink(l), nil
+}
+
+// Delete deletes the link behind code and returns what it was.
+func (s *shorter) Delete(ctx context.Context, code string) (*v1.Link, error) {
+	l, err := s.owned(ctx, code)
+	if err != nil {
+		return nil, internal(err)
+	}
+	if err := s.store.Delete(ctx, l.Code); err != nil {
+		return nil, internal(err)
+	}
+	return s.toLink(l), nil
+}
+
+// owned returns the link behind code, which may be a short link, as long as
+// the caller is its owner.
+func (s *shorter) owned(ctx context.Context, code string) (*Link, error) {
+	if s.baseURL != "" {
+		code = strings.TrimPrefix(code, s.baseURL+"/")
+	}
+	l, err := s.store.Get(ctx, code)
+	if err == ErrNotFound {
+		return nil, NotFound{Code: code}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if l.Owner != owner(ctx) {
+		return nil, Forbidden{Reason: fmt.Sprintf("%q belongs to someone else", code)}
+	}
+	return l, nil
+}
+
+// Reap deletes every expired link from the store, and the idempotency keys
+// that expired, and returns how many links it deleted.
+func (s *shorter) Reap(ctx context.Context) (int, error) {
+	const pageSize = 1000
+
+	now := time.Now()
+	if _, err := s.store.ReapKeys(ctx, now); err != nil {
+		return 0, err
+	}
+	reaped := 0
+	after := ""
+	for {
+		links, err := s.store.List(ctx, after, pageSize)
+		if err != nil {
+			return reaped, err
+		}
+		for _, l := range links {
+			if !l.Expired(now) {
+				continue
+			}
+			if err := s.store.Delete(ctx, l.Code); err != nil {
+				return reaped, err
+			}
+			reaped++
+		}
+		if len(links) < pageSize {
+			return reaped, nil
+		}
+		after = links[len(links)-1].Code
+	}
+}
+
+// toShort describes l as a v1.URL addressed by its short link.
+func (s *shorter) toShort(l *Link) *v1.URL {
+	if s.baseURL != "" {
+		return toURL(s.baseURL+"/"+l.Code, l)
+	}
+	return toURL(l.Code, l)
+}
+
+// toLink describes l as a v1.Link.
+func (s *shorter) toLink(l *Link) *v1.Link {
+	short := l.Code
+	if s.baseURL != "" {
+		short = s.baseURL + "/" + l.Code
+	}
+	link := &v1.Link{
+		Code:      l.Code,
+		Short:     short,
+		Addr:      l.URL,
+		Redirect:  l.Redirect,
+		MaxClicks: l.MaxClicks,
+		Clicks:    l.Clicks,
+	}
+	if !l.ExpiresAt.IsZero() {
+		expiresAt := l.ExpiresAt
+		link.ExpiresAt = &expiresAt
+	}
+	return link
+}
+
+// toURL describes l as a v1.URL with the given address, which is the code
+// when shortening and the long URL when expanding.
+func toURL(addr string, l *Link) *v1.URL {
+	u := &v1.URL{
+		Addr:      addr,
+		Redirect:  l.Redirect,
+		MaxClicks: l.MaxClicks,
+	}
+	if !l.ExpiresAt.IsZero() {
+		expiresAt := l.ExpiresAt
+		u.ExpiresAt = &expiresAt
+	}
+	return u
+}
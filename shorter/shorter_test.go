@@ -0,0 +1,766 @@
+package shorter
+
+import (
+	"context"
+	"errors"
+	"hash/crc32"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jennyservices/jenny/auth"
+	v1 "github.com/jennyservices/shorter/transport/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestShortenStoresLink(t *testing.T) {
+	store := NewMemoryStore()
+	svc := New(store)
+	ctx := context.Background()
+
+	short, err := svc.Shorten(ctx, v1.URL{Addr: "https://example.com/some/long/path"})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	l, err := store.Get(ctx, short.Addr)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if l.URL != "https://example.com/some/long/path" {
+		t.Logf("expected the long url to be stored, got %q", l.URL)
+		t.Fail()
+	}
+
+	l, err = store.GetByURL(ctx, "", "https://example.com/some/long/path")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if l.Code != short.Addr {
+		t.Logf("expected code %q, got %q", short.Addr, l.Code)
+		t.Fail()
+	}
+}
+
+func TestExpand(t *testing.T) {
+	svc := New(NewMemoryStore())
+	ctx := context.Background()
+
+	short, err := svc.Shorten(ctx, v1.URL{Addr: "https://example.com/"})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	long, err := svc.Expand(ctx, short.Addr)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if long.Addr != "https://example.com/" {
+		t.Logf("expected https://example.com/, got %q", long.Addr)
+		t.Fail()
+	}
+
+	if _, err := svc.Expand(ctx, "nope"); err != (NotFound{Code: "nope"}) {
+		t.Logf("expected NotFound, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestBaseURL(t *testing.T) {
+	svc := New(NewMemoryStore(), WithBaseURL("https://sho.rt/"))
+	ctx := context.Background()
+
+	short, err := svc.Shorten(ctx, v1.URL{Addr: "https://example.com/", Alias: "exa"})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if short.Addr != "https://sho.rt/exa" {
+		t.Logf("expected https://sho.rt/exa, got %q", short.Addr)
+		t.Fail()
+	}
+	for _, code := range []string{short.Addr, "exa"} {
+		long, err := svc.Expand(ctx, code)
+		if err != nil || long.Addr != "https://example.com/" {
+			t.Logf("%s: expected https://example.com/, got %v, %v", code, long, err)
+			t.Fail()
+		}
+	}
+}
+
+func TestShortenCanonicalises(t *testing.T) {
+	svc := New(NewMemoryStore())
+	ctx := context.Background()
+
+	a, err := svc.Shorten(ctx, v1.URL{Addr: "HTTP://Example.com:80/"})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	b, err := svc.Shorten(ctx, v1.URL{Addr: "http://example.com/"})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if a.Addr != b.Addr {
+		t.Logf("expected the same code, got %q and %q", a.Addr, b.Addr)
+		t.Fail()
+	}
+
+	if _, err := svc.Shorten(ctx, v1.URL{Addr: "javascript:alert(1)"}); err == nil {
+		t.Log("expected javascript: urls to be refused")
+		t.Fail()
+	} else if _, ok := err.(InvalidURL); !ok {
+		t.Logf("expected InvalidURL, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestShortenAlias(t *testing.T) {
+	svc := New(NewMemoryStore())
+	ctx := context.Background()
+
+	short, err := svc.Shorten(ctx, v1.URL{Addr: "https://example.com/sale", Alias: "spring-sale"})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if short.Addr != "spring-sale" {
+		t.Logf("expected the alias as code, got %q", short.Addr)
+		t.Fail()
+	}
+
+	// asking again for the same link is fine
+	if _, err := svc.Shorten(ctx, v1.URL{Addr: "https://example.com/sale", Alias: "spring-sale"}); err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	_, err = svc.Shorten(ctx, v1.URL{Addr: "https://example.com/other", Alias: "spring-sale"})
+	if err != (AliasTaken{Alias: "spring-sale"}) {
+		t.Logf("expected AliasTaken, got %v", err)
+		t.Fail()
+	}
+
+	for _, alias := range []string{"ab", "has space", "slash/es", "shorten", "Expand", "_spec"} {
+		_, err := svc.Shorten(ctx, v1.URL{Addr: "https://example.com", Alias: alias})
+		if _, ok := err.(Invalid); !ok {
+			t.Logf("%q: expected Invalid, got %v", alias, err)
+			t.Fail()
+		}
+	}
+}
+
+func TestExpandExpiry(t *testing.T) {
+	store := NewMemoryStore()
+	svc := New(store)
+	ctx := context.Background()
+
+	short, err := svc.Shorten(ctx, v1.URL{Addr: "https://example.com", MaxClicks: 2})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := svc.Expand(ctx, short.Addr); err != nil {
+			t.Logf("click %d: %v", i+1, err)
+			t.Fail()
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := svc.Expand(ctx, short.Addr); err != (Gone{Code: short.Addr}) {
+			t.Logf("expected Gone after max_clicks, got %v", err)
+			t.Fail()
+		}
+	}
+	if l, _ := store.Get(ctx, short.Addr); l.Clicks != 2 {
+		t.Logf("expected clicks on a link that's gone not to count, got %d", l.Clicks)
+		t.Fail()
+	}
+
+	store.Put(ctx, Link{Code: "old", URL: "https://example.com", ExpiresAt: time.Now().Add(-time.Minute)})
+	if _, err := svc.Expand(ctx, "old"); err != (Gone{Code: "old"}) {
+		t.Logf("expected Gone past expires_at, got %v", err)
+		t.Fail()
+	}
+
+	past := time.Now().Add(-time.Hour)
+	if _, err := svc.Shorten(ctx, v1.URL{Addr: "https://example.com", ExpiresAt: &past}); err == nil {
+		t.Log("expected an expires_at in the past to be refused")
+		t.Fail()
+	}
+}
+
+func TestReap(t *testing.T) {
+	store := NewMemoryStore()
+	svc := New(store)
+	ctx := context.Background()
+
+	now := time.Now()
+	store.Put(ctx, Link{Code: "live", URL: "https://example.com/live", ExpiresAt: now.Add(time.Hour)})
+	store.Put(ctx, Link{Code: "forever", URL: "https://example.com/forever"})
+	store.Put(ctx, Link{Code: "expired", URL: "https://example.com/expired", ExpiresAt: now.Add(-time.Hour)})
+	store.Put(ctx, Link{Code: "clicked", URL: "https://example.com/clicked", MaxClicks: 1, Clicks: 1})
+
+	n, err := svc.Reap(ctx)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if n != 2 {
+		t.Logf("expected 2 links to be reaped, got %d", n)
+		t.Fail()
+	}
+	for code, want := range map[string]bool{"live": true, "forever": true, "expired": false, "clicked": false} {
+		if ok, _ := store.Exists(ctx, code); ok != want {
+			t.Logf("%s: expected exists to be %v", code, want)
+			t.Fail()
+		}
+	}
+}
+
+// plumless and buckeroo are a known CRC32 collision, which survives a shared
+// prefix since both strings have the same length.
+const (
+	plumless = "https://example.com/plumless"
+	buckeroo = "https://example.com/buckeroo"
+)
+
+func TestShortenCollision(t *testing.T) {
+	if crc32.ChecksumIEEE([]byte(plumless)) != crc32.ChecksumIEEE([]byte(buckeroo)) {
+		t.Log("test urls are expected to collide")
+		t.FailNow()
+	}
+
+	svc := New(NewMemoryStore())
+	ctx := context.Background()
+
+	first, err := svc.Shorten(ctx, v1.URL{Addr: plumless})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	second, err := svc.Shorten(ctx, v1.URL{Addr: buckeroo})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if first.Addr == second.Addr {
+		t.Logf("both urls were given %q", first.Addr)
+		t.FailNow()
+	}
+
+	for code, want := range map[string]string{first.Addr: plumless, second.Addr: buckeroo} {
+		long, err := svc.Expand(ctx, code)
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+		if long.Addr != want {
+			t.Logf("expected %q to expand to %q, got %q", code, want, long.Addr)
+			t.Fail()
+		}
+	}
+
+	// resolution is deterministic, shortening again gives back the same codes
+	again, err := svc.Shorten(ctx, v1.URL{Addr: buckeroo})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if again.Addr != second.Addr {
+		t.Logf("expected %q again, got %q", second.Addr, again.Addr)
+		t.Fail()
+	}
+}
+
+func hashCode(addr string, attempt int) string {
+	code, _ := NewHashGenerator().Generate(context.Background(), addr, attempt)
+	return code
+}
+
+func TestShortenSkipsTakenCodes(t *testing.T) {
+	store := NewMemoryStore()
+	svc := New(store)
+	ctx := context.Background()
+
+	for attempt := 0; attempt < 3; attempt++ {
+		store.Put(ctx, Link{Code: hashCode(plumless, attempt), URL: "https://example.org"})
+	}
+
+	short, err := svc.Shorten(ctx, v1.URL{Addr: plumless})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if short.Addr != hashCode(plumless, 3) {
+		t.Logf("expected %q, got %q", hashCode(plumless, 3), short.Addr)
+		t.Fail()
+	}
+
+	l, _ := store.Get(ctx, hashCode(plumless, 0))
+	if l.URL != "https://example.org" {
+		t.Log("existing link was overwritten")
+		t.Fail()
+	}
+
+	for attempt := 1; attempt < maxAttempts; attempt++ {
+		store.Put(ctx, Link{Code: hashCode(buckeroo, attempt), URL: "https://example.org"})
+	}
+	if _, err := svc.Shorten(ctx, v1.URL{Addr: buckeroo}); err == nil {
+		t.Log("expected an error once every attempt is taken")
+		t.Fail()
+	}
+}
+
+type user string
+
+func (u user) UniqueID() []byte { return []byte(u) }
+
+func TestShortenIdempotent(t *testing.T) {
+	store := NewMemoryStore()
+	svc := New(store, WithGenerator(NewRandomGenerator(7)))
+	ctx := context.Background()
+
+	first, err := svc.Shorten(ctx, v1.URL{Addr: "https://example.com/a"})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	again, err := svc.Shorten(ctx, v1.URL{Addr: "HTTPS://example.com:443/a"})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if again.Addr != first.Addr {
+		t.Logf("expected the same URL to get %q again, got %q", first.Addr, again.Addr)
+		t.Fail()
+	}
+
+	other, err := svc.Shorten(ctx, v1.URL{Addr: "https://example.com/a", MaxClicks: 1})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if other.Addr == first.Addr {
+		t.Log("expected different settings to get a new code")
+		t.Fail()
+	}
+
+	alice := context.WithValue(ctx, auth.UserContextKey, user("alice"))
+	owned, err := svc.Shorten(alice, v1.URL{Addr: "https://example.com/a"})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if owned.Addr == first.Addr {
+		t.Log("expected owners not to share codes with anonymous callers")
+		t.Fail()
+	}
+	if again, _ := svc.Shorten(alice, v1.URL{Addr: "https://example.com/a"}); again == nil || again.Addr != owned.Addr {
+		t.Logf("expected alice to get %q again, got %v", owned.Addr, again)
+		t.Fail()
+	}
+}
+
+func TestShortenIdempotencyKey(t *testing.T) {
+	store := NewMemoryStore()
+	svc := New(store, WithGenerator(NewRandomGenerator(7)))
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(IdempotencyMetadata, "retry-me"))
+
+	first, err := svc.Shorten(ctx, v1.URL{Addr: "https://example.com/a"})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	// a newer link for the same URL doesn't change what the key returns
+	store.Put(ctx, Link{Code: "newer", URL: "https://example.com/a"})
+
+	again, err := svc.Shorten(ctx, v1.URL{Addr: "https://example.com/a"})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if again.Addr != first.Addr {
+		t.Logf("expected the retry to get %q, got %q", first.Addr, again.Addr)
+		t.Fail()
+	}
+
+	_, err = svc.Shorten(ctx, v1.URL{Addr: "https://example.com/b"})
+	if _, ok := err.(Conflict); !ok {
+		t.Logf("expected reusing the key for another URL to conflict, got %v", err)
+		t.Fail()
+	}
+
+	// a key used on a URL that already has a link is kept against that link
+	reused := metadata.NewIncomingContext(context.Background(), metadata.Pairs(IdempotencyMetadata, "reused"))
+	plain, _ := svc.Shorten(context.Background(), v1.URL{Addr: "https://example.com/c"})
+	if u, err := svc.Shorten(reused, v1.URL{Addr: "https://example.com/c"}); err != nil || u.Addr != plain.Addr {
+		t.Logf("expected the existing link %q, got %v, %v", plain.Addr, u, err)
+		t.Fail()
+	}
+	_, err = svc.Shorten(reused, v1.URL{Addr: "https://example.com/d"})
+	if _, ok := err.(Conflict); !ok {
+		t.Logf("expected reusing the key of a reused link for another URL to conflict, got %v", err)
+		t.Fail()
+	}
+	// but only for a while
+	if exp := store.added[plain.Addr][scoped("", "reused")]; exp.Before(time.Now()) || exp.After(time.Now().Add(keyTTL)) {
+		t.Logf("expected the reused key to expire within %v, got %v", keyTTL, exp)
+		t.Fail()
+	}
+	if n, _ := store.ReapKeys(ctx, time.Now().Add(keyTTL+time.Minute)); n != 1 {
+		t.Logf("expected the reused key to be reaped once expired, got %d", n)
+		t.Fail()
+	}
+	if _, err := store.GetByKey(ctx, "", "reused"); err != ErrNotFound {
+		t.Logf("expected the reaped key to be gone, got %v", err)
+		t.Fail()
+	}
+
+	// concurrent retries must not race each other into two links
+	var wg sync.WaitGroup
+	codes := make([]string, 8)
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(IdempotencyMetadata, "race"))
+			u, err := svc.Shorten(ctx, v1.URL{Addr: "https://example.com/race", MaxClicks: 3})
+			if err != nil {
+				t.Log(err)
+				t.Fail()
+				return
+			}
+			codes[i] = u.Addr
+		}(i)
+	}
+	wg.Wait()
+	for _, code := range codes[1:] {
+		if code != codes[0] {
+			t.Logf("expected every retry to get the same code, got %v", codes)
+			t.Fail()
+			break
+		}
+	}
+}
+
+func TestShortenBatch(t *testing.T) {
+	store := NewMemoryStore()
+	svc := New(store, WithGenerator(NewRandomGenerator(7)), WithMaxBatch(3), WithBatchParallelism(2))
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(IdempotencyMetadata, "batch"))
+
+	longs := []v1.URL{
+		{Addr: "https://example.com/a"},
+		{Addr: "example.com"},
+		{Addr: "https://example.com/b", Alias: "bee"},
+	}
+	results, err := svc.ShortenBatch(ctx, longs)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if len(results) != len(longs) {
+		t.Logf("expected %d results, got %d", len(longs), len(results))
+		t.FailNow()
+	}
+	if results[0].URL == nil || results[0].Error != nil {
+		t.Logf("expected the first URL to be shortened, got %+v", results[0])
+		t.Fail()
+	}
+	if results[1].Error == nil || results[1].Error.Status != http.StatusBadRequest {
+		t.Logf("expected the second URL to be invalid, got %+v", results[1])
+		t.Fail()
+	}
+	if results[2].URL == nil || results[2].URL.Addr != "bee" {
+		t.Logf("expected the third URL to get its alias, got %+v", results[2])
+		t.Fail()
+	}
+
+	// retrying the batch with the same key hands out the same codes
+	again, err := svc.ShortenBatch(ctx, longs)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if again[0].URL == nil || again[0].URL.Addr != results[0].URL.Addr {
+		t.Logf("expected the retry to get %q, got %+v", results[0].URL.Addr, again[0])
+		t.Fail()
+	}
+
+	if _, err := svc.ShortenBatch(ctx, append(longs, v1.URL{Addr: "https://example.com/c"})); err == nil {
+		t.Log("expected a batch over the maximum to be refused")
+		t.Fail()
+	}
+
+	// the key of the last URL, suffixed with /2, would be too long
+	long := metadata.NewIncomingContext(context.Background(), metadata.Pairs(IdempotencyMetadata, strings.Repeat("k", maxKeyLength-1)))
+	if _, err := svc.ShortenBatch(long, longs); status.Code(err) != codes.InvalidArgument {
+		t.Logf("expected a key too long to suffix to be refused, got %v", err)
+		t.Fail()
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := svc.ShortenBatch(cancelled, []v1.URL{{Addr: "https://example.com/cancelled"}}); err != context.Canceled {
+		t.Logf("expected a cancelled batch to fail with Canceled, got %v", err)
+		t.Fail()
+	}
+	if l, err := store.GetByURL(context.Background(), "", "https://example.com/cancelled"); err != ErrNotFound {
+		t.Logf("expected nothing to be shortened once the batch was cancelled, got %+v, %v", l, err)
+		t.Fail()
+	}
+}
+
+func TestLinks(t *testing.T) {
+	svc := New(NewMemoryStore(), WithBaseURL("https://sho.rt"))
+	ctx := context.Background()
+	alice := context.WithValue(ctx, auth.UserContextKey, user("alice"))
+
+	for _, alias := range []string{"aaa", "bbb", "ccc"} {
+		if _, err := svc.Shorten(alice, v1.URL{Addr: "https://example.com/" + alias, Alias: alias}); err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+	}
+	if _, err := svc.Shorten(ctx, v1.URL{Addr: "https://example.com/anonymous", Alias: "abc"}); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	svc.Expand(ctx, "aaa")
+
+	l, err := svc.Info(alice, "https://sho.rt/aaa")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if l.Code != "aaa" || l.Short != "https://sho.rt/aaa" || l.Addr != "https://example.com/aaa" || l.Clicks != 1 {
+		t.Logf("expected aaa's link with a click, got %+v", l)
+		t.Fail()
+	}
+	if _, err := svc.Info(ctx, "aaa"); err == nil {
+		t.Log("expected someone else's link to be forbidden")
+		t.Fail()
+	}
+
+	var codes []string
+	after := ""
+	for {
+		page, err := svc.List(alice, after, 2)
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+		for _, l := range page.Links {
+			codes = append(codes, l.Code)
+		}
+		if page.Next == "" {
+			break
+		}
+		after = page.Next
+	}
+	if len(codes) != 3 || codes[0] != "aaa" || codes[2] != "ccc" {
+		t.Logf("expected alice's three links in order, got %q", codes)
+		t.Fail()
+	}
+	if _, err := svc.List(alice, "", maxListLimit+1); err == nil {
+		t.Log("expected a limit over the maximum to be refused")
+		t.Fail()
+	}
+
+	l, err = svc.Update(alice, "bbb", v1.URL{Addr: "https://example.org", MaxClicks: 5})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if l.Addr != "https://example.org/" || l.MaxClicks != 5 || l.Redirect != 0 {
+		t.Logf("expected only addr and max_clicks to change, got %+v", l)
+		t.Fail()
+	}
+	if _, err := svc.Update(alice, "bbb", v1.URL{Redirect: 200}); err == nil {
+		t.Log("expected an invalid redirect to be refused")
+		t.Fail()
+	}
+	if _, err := svc.Update(alice, "bbb", v1.URL{Alias: "bee"}); err == nil {
+		t.Log("expected changing the alias to be refused")
+		t.Fail()
+	}
+	if long, err := svc.Expand(ctx, "bbb"); err != nil || long.Addr != "https://example.org/" {
+		t.Logf("expected bbb to point to the new address, got %v, %v", long, err)
+		t.Fail()
+	}
+
+	if _, err := svc.Delete(ctx, "ccc"); err == nil {
+		t.Log("expected deleting someone else's link to be forbidden")
+		t.Fail()
+	}
+	if _, err := svc.Delete(alice, "ccc"); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if _, err := svc.Info(alice, "ccc"); err != (NotFound{Code: "ccc"}) {
+		t.Logf("expected ccc to be gone, got %v", err)
+		t.Fail()
+	}
+}
+
+// clickingStore counts a click on every link right after it's read, as if
+// someone followed it at just that moment.
+type clickingStore struct {
+	Store
+}
+
+func (s clickingStore) Get(ctx context.Context, code string) (*Link, error) {
+	l, err := s.Store.Get(ctx, code)
+	if err == nil {
+		s.Store.Hit(ctx, code)
+	}
+	return l, err
+}
+
+func TestUpdateKeepsClicks(t *testing.T) {
+	store := NewMemoryStore()
+	svc := New(clickingStore{store})
+	ctx := context.Background()
+
+	store.Put(ctx, Link{Code: "abc", URL: "https://example.com/", MaxClicks: 10})
+	l, err := svc.Update(ctx, "abc", v1.URL{Redirect: http.StatusTemporaryRedirect})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if l.Clicks != 1 || l.Redirect != http.StatusTemporaryRedirect {
+		t.Logf("expected the click counted during the update to be kept, got %+v", l)
+		t.Fail()
+	}
+	if l, _ := store.Get(ctx, "abc"); l.Clicks != 1 {
+		t.Logf("expected the store to keep the click, got %d", l.Clicks)
+		t.Fail()
+	}
+}
+
+func TestUpdateClear(t *testing.T) {
+	store := NewMemoryStore()
+	svc := New(store)
+	ctx := context.Background()
+
+	expiresAt := time.Now().Add(time.Hour)
+	store.Put(ctx, Link{Code: "abc", URL: "https://example.com/", Redirect: http.StatusPermanentRedirect, ExpiresAt: expiresAt, MaxClicks: 10})
+	l, err := svc.Update(ctx, "abc", v1.URL{Clear: []string{"max_clicks", "expires_at"}})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if l.MaxClicks != 0 || l.ExpiresAt != nil || l.Redirect != http.StatusPermanentRedirect {
+		t.Logf("expected only the click limit and expiry to be cleared, got %+v", l)
+		t.Fail()
+	}
+	if l, _ := store.Get(ctx, "abc"); l.MaxClicks != 0 || !l.ExpiresAt.IsZero() {
+		t.Logf("expected the store to have no click limit or expiry, got %+v", l)
+		t.Fail()
+	}
+
+	for _, changes := range []v1.URL{
+		{Clear: []string{"addr"}},
+		{MaxClicks: 5, Clear: []string{"max_clicks"}},
+		{ExpiresAt: &expiresAt, Clear: []string{"expires_at"}},
+	} {
+		if _, err := svc.Update(ctx, "abc", changes); err == nil || err.(Invalid).Field != "clear" {
+			t.Logf("%+v: expected Invalid clear, got %v", changes, err)
+			t.Fail()
+		}
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+
+	if _, err := store.Get(ctx, "nope"); err != ErrNotFound {
+		t.Logf("expected ErrNotFound, got %v", err)
+		t.Fail()
+	}
+
+	store.Put(ctx, Link{Code: "a", URL: "http://a.example"})
+	if ok, _ := store.Exists(ctx, "a"); !ok {
+		t.Log("expected code a to exist")
+		t.Fail()
+	}
+	if ok, _ := store.ExistsURL(ctx, "", "http://a.example"); !ok {
+		t.Log("expected url to exist")
+		t.Fail()
+	}
+
+	// replacing a code drops the reverse entry of the url it used to point to
+	store.Put(ctx, Link{Code: "a", URL: "http://b.example"})
+	if ok, _ := store.ExistsURL(ctx, "", "http://a.example"); ok {
+		t.Log("expected stale url to be dropped from the index")
+		t.Fail()
+	}
+
+	store.Put(ctx, Link{Code: "b", URL: "http://b.example", Owner: "alice"})
+	store.Put(ctx, Link{Code: "c", URL: "http://c.example", Owner: "alice"})
+	for after, want := range map[string]string{"": "b", "b": "c", "c": ""} {
+		links, err := store.ListByOwner(ctx, "alice", after, 1)
+		if err != nil || want == "" && len(links) != 0 || want != "" && (len(links) != 1 || links[0].Code != want) {
+			t.Logf("after %q: expected alice's %q, got %v, %v", after, want, links, err)
+			t.Fail()
+		}
+	}
+	// handing a code to someone else moves it out of its old owner's links
+	store.Put(ctx, Link{Code: "c", URL: "http://c.example", Owner: "bob"})
+	if links, _ := store.ListByOwner(ctx, "alice", "", 0); len(links) != 1 {
+		t.Logf("expected alice to be left with b, got %v", links)
+		t.Fail()
+	}
+
+	if _, err := store.Update(ctx, "nope", func(*Link) error { return nil }); err != ErrNotFound {
+		t.Logf("expected updating a missing code to fail with ErrNotFound, got %v", err)
+		t.Fail()
+	}
+	failed := errors.New("no thanks")
+	if _, err := store.Update(ctx, "b", func(l *Link) error { l.URL = "http://x.example"; return failed }); err != failed {
+		t.Logf("expected update's error, got %v", err)
+		t.Fail()
+	}
+	if l, _ := store.Get(ctx, "b"); l.URL != "http://b.example" {
+		t.Logf("expected a failed update to change nothing, got %+v", l)
+		t.Fail()
+	}
+
+	store.Delete(ctx, "a")
+	if ok, _ := store.Exists(ctx, "a"); ok {
+		t.Log("expected code a to be deleted")
+		t.Fail()
+	}
+	if _, err := store.GetByURL(ctx, "", "http://b.example"); err != ErrNotFound {
+		t.Logf("expected ErrNotFound, got %v", err)
+		t.Fail()
+	}
+
+	// deleting the newest link sharing a key points it to the one left
+	store.Put(ctx, Link{Code: "k1", URL: "http://k.example", Owner: "bob", Key: "k"})
+	store.Put(ctx, Link{Code: "k2", URL: "http://k.example", Owner: "bob", Key: "k"})
+	store.AddKey(ctx, "bob", "added", "k2", time.Time{})
+	store.Delete(ctx, "k2")
+	for _, key := range []string{"k", "added"} {
+		l, err := store.GetByKey(ctx, "bob", key)
+		if key == "k" && (err != nil || l.Code != "k1") || key == "added" && err != ErrNotFound {
+			t.Logf("%s: unexpected link %v, %v once k2 is deleted", key, l, err)
+			t.Fail()
+		}
+	}
+	if problems := store.checkIndex(); len(problems) > 0 {
+		t.Logf("expected the indexes to be consistent, got %+v", problems)
+		t.Fail()
+	}
+}
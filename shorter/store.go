@@ -0,0 +1,176 @@
+package shorter
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned by a Store when there is no link for the given
+	// code or long URL.
+	ErrNotFound = errors.New("link not found")
+
+	// ErrExists is returned by Store.Add when the code is already taken.
+	ErrExists = errors.New("code already exists")
+
+	// ErrKeyExists is returned by Store.Add when the owner already minted a
+	// link with the same idempotency key.
+	ErrKeyExists = errors.New("idempotency key already used")
+)
+
+// Link is a short code and the long URL it points to.
+type Link struct {
+	Code string `json:"code"`
+	URL  string `json:"url"`
+
+	// Owner identifies who minted the link, empty for anonymous callers.
+	Owner string `json:"owner,omitempty"`
+	// Key is the idempotency key the link was minted with, if any.
+	Key string `json:"key,omitempty"`
+
+	// Redirect is the HTTP status browsers are sent to URL with, zero means
+	// the server default.
+	Redirect int `json:"redirect,omitempty"`
+
+	// ExpiresAt is when the link stops working, zero means never.
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+	// MaxClicks is how many times the link can be followed, zero means
+	// there's no limit.
+	MaxClicks int `json:"max_clicks,omitempty"`
+	// Clicks is how many times the link has been followed.
+	Clicks int `json:"clicks,omitempty"`
+}
+
+// Expired reports whether l can no longer be followed at now, either because
+// it's past ExpiresAt or it has been followed MaxClicks times already.
+func (l *Link) Expired(now time.Time) bool {
+	return l.pastExpiry(now) || l.MaxClicks > 0 && l.Clicks >= l.MaxClicks
+}
+
+func (l *Link) pastExpiry(now time.Time) bool {
+	return !l.ExpiresAt.IsZero() && !now.Before(l.ExpiresAt)
+}
+
+// Store persists the links minted by the shorter service. Implementations must
+// be safe for concurrent use.
+type Store interface {
+	// Put saves l, replacing any link already stored under l.Code.
+	Put(ctx context.Context, l Link) error
+
+	// Update calls update with the link stored under code and saves it as
+	// update leaves it, unless update returns an error, which Update returns
+	// too. No other write to the link happens in between, so clicks counted
+	// meanwhile aren't lost. Changes to the link's code are ignored.
+	Update(ctx context.Context, code string, update func(*Link) error) (*Link, error)
+
+	// Add saves l unless a link is already stored under l.Code, in which case
+	// it returns ErrExists, or l.Owner already used l.Key, in which case it
+	// returns ErrKeyExists. The check and the write happen atomically.
+	Add(ctx context.Context, l Link) error
+
+	// Get returns the link stored under code.
+	Get(ctx context.Context, code string) (*Link, error)
+
+	// GetByURL returns the link owner most recently minted for the long url,
+	// of those still in the store.
+	GetByURL(ctx context.Context, owner, url string) (*Link, error)
+
+	// GetByKey returns the link owner minted with the idempotency key.
+	GetByKey(ctx context.Context, owner, key string) (*Link, error)
+
+	// AddKey records that owner's request with the idempotency key was
+	// answered with the link stored under code, so GetByKey finds it until
+	// expiresAt, unless owner already used key for another link, in which
+	// case it returns ErrKeyExists. A zero expiresAt keeps the key as long as
+	// the link.
+	AddKey(ctx context.Context, owner, key, code string, expiresAt time.Time) error
+
+	// ReapKeys drops the keys added with AddKey that expired by now, and
+	// returns how many it dropped.
+	ReapKeys(ctx context.Context, now time.Time) (int, error)
+
+	// Delete removes the link stored under code. Deleting a code that doesn't
+	// exist is not an error.
+	Delete(ctx context.Context, code string) error
+
+	// Exists reports whether there is a link stored under code.
+	Exists(ctx context.Context, code string) (bool, error)
+
+	// ExistsURL reports whether owner has minted a link for the long url.
+	ExistsURL(ctx context.Context, owner, url string) (bool, error)
+
+	// Hit counts a click on the link stored under code and returns the link,
+	// including that click in Clicks.
+	Hit(ctx context.Context, code string) (*Link, error)
+
+	// List returns up to limit links ordered by code, starting after the code
+	// after. A limit of zero returns every link.
+	List(ctx context.Context, after string, limit int) ([]Link, error)
+
+	// ListByOwner is List limited to the links owner minted.
+	ListByOwner(ctx context.Context, owner, after string, limit int) ([]Link, error)
+}
+
+// Pinger is implemented by stores that can tell whether they're able to serve
+// requests right now. Stores that don't implement it are assumed to be.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// IndexProblem is an entry of a store's long URL or idempotency key index
+// that disagrees with the links it has.
+type IndexProblem struct {
+	// Index is "url" for the long URL index and "key" for the idempotency
+	// key one.
+	Index string `json:"index"`
+	Owner string `json:"owner,omitempty"`
+	// Entry is the long URL or idempotency key.
+	Entry string `json:"entry"`
+	// Code is the code the index points Entry to, empty if it's missing.
+	Code string `json:"code,omitempty"`
+	// Links are the codes of the links Entry should point to one of.
+	Links []string `json:"links,omitempty"`
+	// Problem is one of ProblemMissing, ProblemWrong or ProblemDangling.
+	Problem string `json:"problem"`
+}
+
+// What can be wrong with an index entry.
+const (
+	// ProblemMissing is an entry missing for links that have it.
+	ProblemMissing = "missing"
+	// ProblemWrong is an entry pointing to a link that doesn't have it.
+	ProblemWrong = "wrong"
+	// ProblemDangling is an entry no link has.
+	ProblemDangling = "dangling"
+)
+
+// LogProblem is a record of a file store's log that couldn't be replayed.
+type LogProblem struct {
+	// Offset is where the record starts in the log.
+	Offset int64 `json:"offset"`
+	// Op and Code are those of the record, when it could be read.
+	Op   string `json:"op,omitempty"`
+	Code string `json:"code,omitempty"`
+	// Problem is one of ProblemCorrupt, ProblemAfterCorrupt, ProblemTorn,
+	// ProblemNoLink or ProblemKeyTaken.
+	Problem string `json:"problem"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+// What can be wrong with a record of the log.
+const (
+	// ProblemCorrupt is a record that doesn't check out, with more of the
+	// log after it.
+	ProblemCorrupt = "corrupt"
+	// ProblemAfterCorrupt is the first record that checks out after a
+	// corrupt one; truncating the log at the corrupt record would lose it
+	// and everything after it.
+	ProblemAfterCorrupt = "after corrupt"
+	// ProblemTorn is a record cut short at the end of the log by a crash.
+	ProblemTorn = "torn"
+	// ProblemNoLink is a hit or key record for a link that isn't there.
+	ProblemNoLink = "no link"
+	// ProblemKeyTaken is a key record for a key another link has.
+	ProblemKeyTaken = "key taken"
+)
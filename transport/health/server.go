@@ -0,0 +1,94 @@
+package health
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Server is a HealthServer that reports whatever status was last set for
+// each service. The empty service name stands for the server as a whole.
+type Server struct {
+	mu       sync.Mutex
+	statuses map[string]HealthCheckResponse_ServingStatus
+	watchers map[string]map[chan HealthCheckResponse_ServingStatus]struct{}
+}
+
+// NewServer returns a Server that reports the server as a whole as SERVING.
+func NewServer() *Server {
+	return &Server{
+		statuses: map[string]HealthCheckResponse_ServingStatus{"": HealthCheckResponse_SERVING},
+		watchers: make(map[string]map[chan HealthCheckResponse_ServingStatus]struct{}),
+	}
+}
+
+// SetServingStatus sets the status of service, and tells everyone watching
+// it if it changed.
+func (s *Server) SetServingStatus(service string, st HealthCheckResponse_ServingStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if old, ok := s.statuses[service]; ok && old == st {
+		return
+	}
+	s.statuses[service] = st
+	for w := range s.watchers[service] {
+		// watchers only care about the latest status, so replace a
+		// pending one rather than block on a slow client
+		select {
+		case <-w:
+		default:
+		}
+		w <- st
+	}
+}
+
+// Check implements HealthServer.
+func (s *Server) Check(ctx context.Context, req *HealthCheckRequest) (*HealthCheckResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok := s.statuses[req.Service]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "unknown service %q", req.Service)
+	}
+	return &HealthCheckResponse{Status: st}, nil
+}
+
+// Watch implements HealthServer, sending the status of the service straight
+// away and again whenever it changes. Unknown services are reported as
+// SERVICE_UNKNOWN, as they may be registered later.
+func (s *Server) Watch(req *HealthCheckRequest, stream Health_WatchServer) error {
+	w := make(chan HealthCheckResponse_ServingStatus, 1)
+
+	s.mu.Lock()
+	if st, ok := s.statuses[req.Service]; ok {
+		w <- st
+	} else {
+		w <- HealthCheckResponse_SERVICE_UNKNOWN
+	}
+	if s.watchers[req.Service] == nil {
+		s.watchers[req.Service] = make(map[chan HealthCheckResponse_ServingStatus]struct{})
+	}
+	s.watchers[req.Service][w] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.watchers[req.Service], w)
+		s.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case st := <-w:
+			if err := stream.Send(&HealthCheckResponse{Status: st}); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return status.Error(codes.Canceled, "stream has ended")
+		}
+	}
+}
@@ -6,6 +6,8 @@ package pb
 import proto "github.com/golang/protobuf/proto"
 import fmt "fmt"
 import math "math"
+import timestamp "github.com/golang/protobuf/ptypes/timestamp"
+import duration "github.com/golang/protobuf/ptypes/duration"
 
 import (
 	context "golang.org/x/net/context"
@@ -24,17 +26,22 @@ var _ = math.Inf
 const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
 type URL struct {
-	Addr                 string   `protobuf:"bytes,1,opt,name=addr,proto3" json:"addr,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+	Addr                 string               `protobuf:"bytes,1,opt,name=addr,proto3" json:"addr,omitempty"`
+	Redirect             int32                `protobuf:"varint,2,opt,name=redirect,proto3" json:"redirect,omitempty"`
+	Alias                string               `protobuf:"bytes,3,opt,name=alias,proto3" json:"alias,omitempty"`
+	ExpiresAt            *timestamp.Timestamp `protobuf:"bytes,4,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	MaxClicks            int64                `protobuf:"varint,5,opt,name=max_clicks,json=maxClicks,proto3" json:"max_clicks,omitempty"`
+	Clear                []string             `protobuf:"bytes,6,rep,name=clear,proto3" json:"clear,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
 }
 
 func (m *URL) Reset()         { *m = URL{} }
 func (m *URL) String() string { return proto.CompactTextString(m) }
 func (*URL) ProtoMessage()    {}
 func (*URL) Descriptor() ([]byte, []int) {
-	return fileDescriptor_shorter_55268af45bc6a522, []int{0}
+	return fileDescriptor_shorter_284723024f260b62, []int{0}
 }
 func (m *URL) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_URL.Unmarshal(m, b)
@@ -61,8 +68,725 @@ func (m *URL) GetAddr() string {
 	return ""
 }
 
+func (m *URL) GetRedirect() int32 {
+	if m != nil {
+		return m.Redirect
+	}
+	return 0
+}
+
+func (m *URL) GetAlias() string {
+	if m != nil {
+		return m.Alias
+	}
+	return ""
+}
+
+func (m *URL) GetExpiresAt() *timestamp.Timestamp {
+	if m != nil {
+		return m.ExpiresAt
+	}
+	return nil
+}
+
+func (m *URL) GetMaxClicks() int64 {
+	if m != nil {
+		return m.MaxClicks
+	}
+	return 0
+}
+
+func (m *URL) GetClear() []string {
+	if m != nil {
+		return m.Clear
+	}
+	return nil
+}
+
+type Code struct {
+	Code                 string   `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Code) Reset()         { *m = Code{} }
+func (m *Code) String() string { return proto.CompactTextString(m) }
+func (*Code) ProtoMessage()    {}
+func (*Code) Descriptor() ([]byte, []int) {
+	return fileDescriptor_shorter_284723024f260b62, []int{1}
+}
+func (m *Code) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Code.Unmarshal(m, b)
+}
+func (m *Code) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Code.Marshal(b, m, deterministic)
+}
+func (dst *Code) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Code.Merge(dst, src)
+}
+func (m *Code) XXX_Size() int {
+	return xxx_messageInfo_Code.Size(m)
+}
+func (m *Code) XXX_DiscardUnknown() {
+	xxx_messageInfo_Code.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Code proto.InternalMessageInfo
+
+func (m *Code) GetCode() string {
+	if m != nil {
+		return m.Code
+	}
+	return ""
+}
+
+type Batch struct {
+	Urls                 []*URL   `protobuf:"bytes,1,rep,name=urls,proto3" json:"urls,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Batch) Reset()         { *m = Batch{} }
+func (m *Batch) String() string { return proto.CompactTextString(m) }
+func (*Batch) ProtoMessage()    {}
+func (*Batch) Descriptor() ([]byte, []int) {
+	return fileDescriptor_shorter_284723024f260b62, []int{2}
+}
+func (m *Batch) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Batch.Unmarshal(m, b)
+}
+func (m *Batch) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Batch.Marshal(b, m, deterministic)
+}
+func (dst *Batch) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Batch.Merge(dst, src)
+}
+func (m *Batch) XXX_Size() int {
+	return xxx_messageInfo_Batch.Size(m)
+}
+func (m *Batch) XXX_DiscardUnknown() {
+	xxx_messageInfo_Batch.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Batch proto.InternalMessageInfo
+
+func (m *Batch) GetUrls() []*URL {
+	if m != nil {
+		return m.Urls
+	}
+	return nil
+}
+
+type BatchResults struct {
+	Results              []*BatchResult `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
+	XXX_unrecognized     []byte         `json:"-"`
+	XXX_sizecache        int32          `json:"-"`
+}
+
+func (m *BatchResults) Reset()         { *m = BatchResults{} }
+func (m *BatchResults) String() string { return proto.CompactTextString(m) }
+func (*BatchResults) ProtoMessage()    {}
+func (*BatchResults) Descriptor() ([]byte, []int) {
+	return fileDescriptor_shorter_284723024f260b62, []int{3}
+}
+func (m *BatchResults) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BatchResults.Unmarshal(m, b)
+}
+func (m *BatchResults) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BatchResults.Marshal(b, m, deterministic)
+}
+func (dst *BatchResults) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BatchResults.Merge(dst, src)
+}
+func (m *BatchResults) XXX_Size() int {
+	return xxx_messageInfo_BatchResults.Size(m)
+}
+func (m *BatchResults) XXX_DiscardUnknown() {
+	xxx_messageInfo_BatchResults.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BatchResults proto.InternalMessageInfo
+
+func (m *BatchResults) GetResults() []*BatchResult {
+	if m != nil {
+		return m.Results
+	}
+	return nil
+}
+
+type BatchResult struct {
+	Url                  *URL     `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	Error                *Error   `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *BatchResult) Reset()         { *m = BatchResult{} }
+func (m *BatchResult) String() string { return proto.CompactTextString(m) }
+func (*BatchResult) ProtoMessage()    {}
+func (*BatchResult) Descriptor() ([]byte, []int) {
+	return fileDescriptor_shorter_284723024f260b62, []int{4}
+}
+func (m *BatchResult) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BatchResult.Unmarshal(m, b)
+}
+func (m *BatchResult) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BatchResult.Marshal(b, m, deterministic)
+}
+func (dst *BatchResult) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BatchResult.Merge(dst, src)
+}
+func (m *BatchResult) XXX_Size() int {
+	return xxx_messageInfo_BatchResult.Size(m)
+}
+func (m *BatchResult) XXX_DiscardUnknown() {
+	xxx_messageInfo_BatchResult.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BatchResult proto.InternalMessageInfo
+
+func (m *BatchResult) GetUrl() *URL {
+	if m != nil {
+		return m.Url
+	}
+	return nil
+}
+
+func (m *BatchResult) GetError() *Error {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type StreamRequest struct {
+	Id                   uint64   `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Url                  *URL     `protobuf:"bytes,2,opt,name=url,proto3" json:"url,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *StreamRequest) Reset()         { *m = StreamRequest{} }
+func (m *StreamRequest) String() string { return proto.CompactTextString(m) }
+func (*StreamRequest) ProtoMessage()    {}
+func (*StreamRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_shorter_284723024f260b62, []int{5}
+}
+func (m *StreamRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_StreamRequest.Unmarshal(m, b)
+}
+func (m *StreamRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_StreamRequest.Marshal(b, m, deterministic)
+}
+func (dst *StreamRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StreamRequest.Merge(dst, src)
+}
+func (m *StreamRequest) XXX_Size() int {
+	return xxx_messageInfo_StreamRequest.Size(m)
+}
+func (m *StreamRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_StreamRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StreamRequest proto.InternalMessageInfo
+
+func (m *StreamRequest) GetId() uint64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *StreamRequest) GetUrl() *URL {
+	if m != nil {
+		return m.Url
+	}
+	return nil
+}
+
+type StreamResult struct {
+	Id                   uint64   `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Url                  *URL     `protobuf:"bytes,2,opt,name=url,proto3" json:"url,omitempty"`
+	Error                *Error   `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *StreamResult) Reset()         { *m = StreamResult{} }
+func (m *StreamResult) String() string { return proto.CompactTextString(m) }
+func (*StreamResult) ProtoMessage()    {}
+func (*StreamResult) Descriptor() ([]byte, []int) {
+	return fileDescriptor_shorter_284723024f260b62, []int{6}
+}
+func (m *StreamResult) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_StreamResult.Unmarshal(m, b)
+}
+func (m *StreamResult) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_StreamResult.Marshal(b, m, deterministic)
+}
+func (dst *StreamResult) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StreamResult.Merge(dst, src)
+}
+func (m *StreamResult) XXX_Size() int {
+	return xxx_messageInfo_StreamResult.Size(m)
+}
+func (m *StreamResult) XXX_DiscardUnknown() {
+	xxx_messageInfo_StreamResult.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StreamResult proto.InternalMessageInfo
+
+func (m *StreamResult) GetId() uint64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *StreamResult) GetUrl() *URL {
+	if m != nil {
+		return m.Url
+	}
+	return nil
+}
+
+func (m *StreamResult) GetError() *Error {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type Error struct {
+	Code                 int32              `protobuf:"varint,1,opt,name=code,proto3" json:"code,omitempty"`
+	Message              string             `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	FieldViolations      []*FieldViolation  `protobuf:"bytes,3,rep,name=field_violations,json=fieldViolations,proto3" json:"field_violations,omitempty"`
+	RetryAfter           *duration.Duration `protobuf:"bytes,4,opt,name=retry_after,json=retryAfter,proto3" json:"retry_after,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}           `json:"-"`
+	XXX_unrecognized     []byte             `json:"-"`
+	XXX_sizecache        int32              `json:"-"`
+}
+
+func (m *Error) Reset()         { *m = Error{} }
+func (m *Error) String() string { return proto.CompactTextString(m) }
+func (*Error) ProtoMessage()    {}
+func (*Error) Descriptor() ([]byte, []int) {
+	return fileDescriptor_shorter_284723024f260b62, []int{7}
+}
+func (m *Error) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Error.Unmarshal(m, b)
+}
+func (m *Error) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Error.Marshal(b, m, deterministic)
+}
+func (dst *Error) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Error.Merge(dst, src)
+}
+func (m *Error) XXX_Size() int {
+	return xxx_messageInfo_Error.Size(m)
+}
+func (m *Error) XXX_DiscardUnknown() {
+	xxx_messageInfo_Error.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Error proto.InternalMessageInfo
+
+func (m *Error) GetCode() int32 {
+	if m != nil {
+		return m.Code
+	}
+	return 0
+}
+
+func (m *Error) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
+func (m *Error) GetFieldViolations() []*FieldViolation {
+	if m != nil {
+		return m.FieldViolations
+	}
+	return nil
+}
+
+func (m *Error) GetRetryAfter() *duration.Duration {
+	if m != nil {
+		return m.RetryAfter
+	}
+	return nil
+}
+
+type BadRequest struct {
+	FieldViolations      []*FieldViolation `protobuf:"bytes,1,rep,name=field_violations,json=fieldViolations,proto3" json:"field_violations,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
+}
+
+func (m *BadRequest) Reset()         { *m = BadRequest{} }
+func (m *BadRequest) String() string { return proto.CompactTextString(m) }
+func (*BadRequest) ProtoMessage()    {}
+func (*BadRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_shorter_284723024f260b62, []int{8}
+}
+func (m *BadRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BadRequest.Unmarshal(m, b)
+}
+func (m *BadRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BadRequest.Marshal(b, m, deterministic)
+}
+func (dst *BadRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BadRequest.Merge(dst, src)
+}
+func (m *BadRequest) XXX_Size() int {
+	return xxx_messageInfo_BadRequest.Size(m)
+}
+func (m *BadRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_BadRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BadRequest proto.InternalMessageInfo
+
+func (m *BadRequest) GetFieldViolations() []*FieldViolation {
+	if m != nil {
+		return m.FieldViolations
+	}
+	return nil
+}
+
+type FieldViolation struct {
+	Field                string   `protobuf:"bytes,1,opt,name=field,proto3" json:"field,omitempty"`
+	Description          string   `protobuf:"bytes,2,opt,name=description,proto3" json:"description,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *FieldViolation) Reset()         { *m = FieldViolation{} }
+func (m *FieldViolation) String() string { return proto.CompactTextString(m) }
+func (*FieldViolation) ProtoMessage()    {}
+func (*FieldViolation) Descriptor() ([]byte, []int) {
+	return fileDescriptor_shorter_284723024f260b62, []int{9}
+}
+func (m *FieldViolation) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_FieldViolation.Unmarshal(m, b)
+}
+func (m *FieldViolation) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_FieldViolation.Marshal(b, m, deterministic)
+}
+func (dst *FieldViolation) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_FieldViolation.Merge(dst, src)
+}
+func (m *FieldViolation) XXX_Size() int {
+	return xxx_messageInfo_FieldViolation.Size(m)
+}
+func (m *FieldViolation) XXX_DiscardUnknown() {
+	xxx_messageInfo_FieldViolation.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_FieldViolation proto.InternalMessageInfo
+
+func (m *FieldViolation) GetField() string {
+	if m != nil {
+		return m.Field
+	}
+	return ""
+}
+
+func (m *FieldViolation) GetDescription() string {
+	if m != nil {
+		return m.Description
+	}
+	return ""
+}
+
+type RetryInfo struct {
+	RetryDelay           *duration.Duration `protobuf:"bytes,1,opt,name=retry_delay,json=retryDelay,proto3" json:"retry_delay,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}           `json:"-"`
+	XXX_unrecognized     []byte             `json:"-"`
+	XXX_sizecache        int32              `json:"-"`
+}
+
+func (m *RetryInfo) Reset()         { *m = RetryInfo{} }
+func (m *RetryInfo) String() string { return proto.CompactTextString(m) }
+func (*RetryInfo) ProtoMessage()    {}
+func (*RetryInfo) Descriptor() ([]byte, []int) {
+	return fileDescriptor_shorter_284723024f260b62, []int{10}
+}
+func (m *RetryInfo) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RetryInfo.Unmarshal(m, b)
+}
+func (m *RetryInfo) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RetryInfo.Marshal(b, m, deterministic)
+}
+func (dst *RetryInfo) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RetryInfo.Merge(dst, src)
+}
+func (m *RetryInfo) XXX_Size() int {
+	return xxx_messageInfo_RetryInfo.Size(m)
+}
+func (m *RetryInfo) XXX_DiscardUnknown() {
+	xxx_messageInfo_RetryInfo.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RetryInfo proto.InternalMessageInfo
+
+func (m *RetryInfo) GetRetryDelay() *duration.Duration {
+	if m != nil {
+		return m.RetryDelay
+	}
+	return nil
+}
+
+type Link struct {
+	Code                 string               `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	Short                string               `protobuf:"bytes,2,opt,name=short,proto3" json:"short,omitempty"`
+	Addr                 string               `protobuf:"bytes,3,opt,name=addr,proto3" json:"addr,omitempty"`
+	Redirect             int32                `protobuf:"varint,4,opt,name=redirect,proto3" json:"redirect,omitempty"`
+	ExpiresAt            *timestamp.Timestamp `protobuf:"bytes,5,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	MaxClicks            int64                `protobuf:"varint,6,opt,name=max_clicks,json=maxClicks,proto3" json:"max_clicks,omitempty"`
+	Clicks               int64                `protobuf:"varint,7,opt,name=clicks,proto3" json:"clicks,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *Link) Reset()         { *m = Link{} }
+func (m *Link) String() string { return proto.CompactTextString(m) }
+func (*Link) ProtoMessage()    {}
+func (*Link) Descriptor() ([]byte, []int) {
+	return fileDescriptor_shorter_284723024f260b62, []int{11}
+}
+func (m *Link) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Link.Unmarshal(m, b)
+}
+func (m *Link) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Link.Marshal(b, m, deterministic)
+}
+func (dst *Link) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Link.Merge(dst, src)
+}
+func (m *Link) XXX_Size() int {
+	return xxx_messageInfo_Link.Size(m)
+}
+func (m *Link) XXX_DiscardUnknown() {
+	xxx_messageInfo_Link.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Link proto.InternalMessageInfo
+
+func (m *Link) GetCode() string {
+	if m != nil {
+		return m.Code
+	}
+	return ""
+}
+
+func (m *Link) GetShort() string {
+	if m != nil {
+		return m.Short
+	}
+	return ""
+}
+
+func (m *Link) GetAddr() string {
+	if m != nil {
+		return m.Addr
+	}
+	return ""
+}
+
+func (m *Link) GetRedirect() int32 {
+	if m != nil {
+		return m.Redirect
+	}
+	return 0
+}
+
+func (m *Link) GetExpiresAt() *timestamp.Timestamp {
+	if m != nil {
+		return m.ExpiresAt
+	}
+	return nil
+}
+
+func (m *Link) GetMaxClicks() int64 {
+	if m != nil {
+		return m.MaxClicks
+	}
+	return 0
+}
+
+func (m *Link) GetClicks() int64 {
+	if m != nil {
+		return m.Clicks
+	}
+	return 0
+}
+
+type ListRequest struct {
+	After                string   `protobuf:"bytes,1,opt,name=after,proto3" json:"after,omitempty"`
+	Limit                int32    `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListRequest) Reset()         { *m = ListRequest{} }
+func (m *ListRequest) String() string { return proto.CompactTextString(m) }
+func (*ListRequest) ProtoMessage()    {}
+func (*ListRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_shorter_284723024f260b62, []int{12}
+}
+func (m *ListRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListRequest.Unmarshal(m, b)
+}
+func (m *ListRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListRequest.Marshal(b, m, deterministic)
+}
+func (dst *ListRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListRequest.Merge(dst, src)
+}
+func (m *ListRequest) XXX_Size() int {
+	return xxx_messageInfo_ListRequest.Size(m)
+}
+func (m *ListRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListRequest proto.InternalMessageInfo
+
+func (m *ListRequest) GetAfter() string {
+	if m != nil {
+		return m.After
+	}
+	return ""
+}
+
+func (m *ListRequest) GetLimit() int32 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
+}
+
+type LinkPage struct {
+	Links                []*Link  `protobuf:"bytes,1,rep,name=links,proto3" json:"links,omitempty"`
+	Next                 string   `protobuf:"bytes,2,opt,name=next,proto3" json:"next,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *LinkPage) Reset()         { *m = LinkPage{} }
+func (m *LinkPage) String() string { return proto.CompactTextString(m) }
+func (*LinkPage) ProtoMessage()    {}
+func (*LinkPage) Descriptor() ([]byte, []int) {
+	return fileDescriptor_shorter_284723024f260b62, []int{13}
+}
+func (m *LinkPage) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LinkPage.Unmarshal(m, b)
+}
+func (m *LinkPage) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LinkPage.Marshal(b, m, deterministic)
+}
+func (dst *LinkPage) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LinkPage.Merge(dst, src)
+}
+func (m *LinkPage) XXX_Size() int {
+	return xxx_messageInfo_LinkPage.Size(m)
+}
+func (m *LinkPage) XXX_DiscardUnknown() {
+	xxx_messageInfo_LinkPage.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LinkPage proto.InternalMessageInfo
+
+func (m *LinkPage) GetLinks() []*Link {
+	if m != nil {
+		return m.Links
+	}
+	return nil
+}
+
+func (m *LinkPage) GetNext() string {
+	if m != nil {
+		return m.Next
+	}
+	return ""
+}
+
+type LinkUpdate struct {
+	Code                 string   `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	Changes              *URL     `protobuf:"bytes,2,opt,name=changes,proto3" json:"changes,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *LinkUpdate) Reset()         { *m = LinkUpdate{} }
+func (m *LinkUpdate) String() string { return proto.CompactTextString(m) }
+func (*LinkUpdate) ProtoMessage()    {}
+func (*LinkUpdate) Descriptor() ([]byte, []int) {
+	return fileDescriptor_shorter_284723024f260b62, []int{14}
+}
+func (m *LinkUpdate) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LinkUpdate.Unmarshal(m, b)
+}
+func (m *LinkUpdate) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LinkUpdate.Marshal(b, m, deterministic)
+}
+func (dst *LinkUpdate) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LinkUpdate.Merge(dst, src)
+}
+func (m *LinkUpdate) XXX_Size() int {
+	return xxx_messageInfo_LinkUpdate.Size(m)
+}
+func (m *LinkUpdate) XXX_DiscardUnknown() {
+	xxx_messageInfo_LinkUpdate.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LinkUpdate proto.InternalMessageInfo
+
+func (m *LinkUpdate) GetCode() string {
+	if m != nil {
+		return m.Code
+	}
+	return ""
+}
+
+func (m *LinkUpdate) GetChanges() *URL {
+	if m != nil {
+		return m.Changes
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*URL)(nil), "pb.URL")
+	proto.RegisterType((*Code)(nil), "pb.Code")
+	proto.RegisterType((*Batch)(nil), "pb.Batch")
+	proto.RegisterType((*BatchResults)(nil), "pb.BatchResults")
+	proto.RegisterType((*BatchResult)(nil), "pb.BatchResult")
+	proto.RegisterType((*StreamRequest)(nil), "pb.StreamRequest")
+	proto.RegisterType((*StreamResult)(nil), "pb.StreamResult")
+	proto.RegisterType((*Error)(nil), "pb.Error")
+	proto.RegisterType((*BadRequest)(nil), "pb.BadRequest")
+	proto.RegisterType((*FieldViolation)(nil), "pb.FieldViolation")
+	proto.RegisterType((*RetryInfo)(nil), "pb.RetryInfo")
+	proto.RegisterType((*Link)(nil), "pb.Link")
+	proto.RegisterType((*ListRequest)(nil), "pb.ListRequest")
+	proto.RegisterType((*LinkPage)(nil), "pb.LinkPage")
+	proto.RegisterType((*LinkUpdate)(nil), "pb.LinkUpdate")
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -78,6 +802,13 @@ const _ = grpc.SupportPackageIsVersion4
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
 type ShorterClient interface {
 	Shorten(ctx context.Context, in *URL, opts ...grpc.CallOption) (*URL, error)
+	Expand(ctx context.Context, in *Code, opts ...grpc.CallOption) (*URL, error)
+	ShortenBatch(ctx context.Context, in *Batch, opts ...grpc.CallOption) (*BatchResults, error)
+	ShortenStream(ctx context.Context, opts ...grpc.CallOption) (Shorter_ShortenStreamClient, error)
+	Info(ctx context.Context, in *Code, opts ...grpc.CallOption) (*Link, error)
+	List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*LinkPage, error)
+	Update(ctx context.Context, in *LinkUpdate, opts ...grpc.CallOption) (*Link, error)
+	Delete(ctx context.Context, in *Code, opts ...grpc.CallOption) (*Link, error)
 }
 
 type shorterClient struct {
@@ -97,9 +828,101 @@ func (c *shorterClient) Shorten(ctx context.Context, in *URL, opts ...grpc.CallO
 	return out, nil
 }
 
+func (c *shorterClient) Expand(ctx context.Context, in *Code, opts ...grpc.CallOption) (*URL, error) {
+	out := new(URL)
+	err := c.cc.Invoke(ctx, "/pb.Shorter/Expand", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shorterClient) ShortenBatch(ctx context.Context, in *Batch, opts ...grpc.CallOption) (*BatchResults, error) {
+	out := new(BatchResults)
+	err := c.cc.Invoke(ctx, "/pb.Shorter/ShortenBatch", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shorterClient) ShortenStream(ctx context.Context, opts ...grpc.CallOption) (Shorter_ShortenStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Shorter_serviceDesc.Streams[0], "/pb.Shorter/ShortenStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &shorterShortenStreamClient{stream}
+	return x, nil
+}
+
+type Shorter_ShortenStreamClient interface {
+	Send(*StreamRequest) error
+	Recv() (*StreamResult, error)
+	grpc.ClientStream
+}
+
+type shorterShortenStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *shorterShortenStreamClient) Send(m *StreamRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *shorterShortenStreamClient) Recv() (*StreamResult, error) {
+	m := new(StreamResult)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *shorterClient) Info(ctx context.Context, in *Code, opts ...grpc.CallOption) (*Link, error) {
+	out := new(Link)
+	err := c.cc.Invoke(ctx, "/pb.Shorter/Info", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shorterClient) List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*LinkPage, error) {
+	out := new(LinkPage)
+	err := c.cc.Invoke(ctx, "/pb.Shorter/List", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shorterClient) Update(ctx context.Context, in *LinkUpdate, opts ...grpc.CallOption) (*Link, error) {
+	out := new(Link)
+	err := c.cc.Invoke(ctx, "/pb.Shorter/Update", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shorterClient) Delete(ctx context.Context, in *Code, opts ...grpc.CallOption) (*Link, error) {
+	out := new(Link)
+	err := c.cc.Invoke(ctx, "/pb.Shorter/Delete", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ShorterServer is the server API for Shorter service.
 type ShorterServer interface {
 	Shorten(context.Context, *URL) (*URL, error)
+	Expand(context.Context, *Code) (*URL, error)
+	ShortenBatch(context.Context, *Batch) (*BatchResults, error)
+	ShortenStream(Shorter_ShortenStreamServer) error
+	Info(context.Context, *Code) (*Link, error)
+	List(context.Context, *ListRequest) (*LinkPage, error)
+	Update(context.Context, *LinkUpdate) (*Link, error)
+	Delete(context.Context, *Code) (*Link, error)
 }
 
 func RegisterShorterServer(s *grpc.Server, srv ShorterServer) {
@@ -124,6 +947,140 @@ func _Shorter_Shorten_Handler(srv interface{}, ctx context.Context, dec func(int
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Shorter_Expand_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Code)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShorterServer).Expand(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Shorter/Expand",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShorterServer).Expand(ctx, req.(*Code))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Shorter_ShortenBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Batch)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShorterServer).ShortenBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Shorter/ShortenBatch",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShorterServer).ShortenBatch(ctx, req.(*Batch))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Shorter_ShortenStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ShorterServer).ShortenStream(&shorterShortenStreamServer{stream})
+}
+
+type Shorter_ShortenStreamServer interface {
+	Send(*StreamResult) error
+	Recv() (*StreamRequest, error)
+	grpc.ServerStream
+}
+
+type shorterShortenStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *shorterShortenStreamServer) Send(m *StreamResult) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *shorterShortenStreamServer) Recv() (*StreamRequest, error) {
+	m := new(StreamRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Shorter_Info_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Code)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShorterServer).Info(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Shorter/Info",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShorterServer).Info(ctx, req.(*Code))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Shorter_List_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShorterServer).List(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Shorter/List",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShorterServer).List(ctx, req.(*ListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Shorter_Update_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LinkUpdate)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShorterServer).Update(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Shorter/Update",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShorterServer).Update(ctx, req.(*LinkUpdate))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Shorter_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Code)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShorterServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Shorter/Delete",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShorterServer).Delete(ctx, req.(*Code))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _Shorter_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "pb.Shorter",
 	HandlerType: (*ShorterServer)(nil),
@@ -132,19 +1089,92 @@ var _Shorter_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Shorten",
 			Handler:    _Shorter_Shorten_Handler,
 		},
+		{
+			MethodName: "Expand",
+			Handler:    _Shorter_Expand_Handler,
+		},
+		{
+			MethodName: "ShortenBatch",
+			Handler:    _Shorter_ShortenBatch_Handler,
+		},
+		{
+			MethodName: "Info",
+			Handler:    _Shorter_Info_Handler,
+		},
+		{
+			MethodName: "List",
+			Handler:    _Shorter_List_Handler,
+		},
+		{
+			MethodName: "Update",
+			Handler:    _Shorter_Update_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _Shorter_Delete_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ShortenStream",
+			Handler:       _Shorter_ShortenStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "shorter.proto",
 }
 
-func init() { proto.RegisterFile("shorter.proto", fileDescriptor_shorter_55268af45bc6a522) }
+func init() { proto.RegisterFile("shorter.proto", fileDescriptor_shorter_284723024f260b62) }
 
-var fileDescriptor_shorter_55268af45bc6a522 = []byte{
-	// 95 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0xe2, 0x2d, 0xce, 0xc8, 0x2f,
-	0x2a, 0x49, 0x2d, 0xd2, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0x62, 0x2a, 0x48, 0x52, 0x92, 0xe4,
-	0x62, 0x0e, 0x0d, 0xf2, 0x11, 0x12, 0xe2, 0x62, 0x49, 0x4c, 0x49, 0x29, 0x92, 0x60, 0x54, 0x60,
-	0xd4, 0xe0, 0x0c, 0x02, 0xb3, 0x8d, 0xd4, 0xb8, 0xd8, 0x83, 0x21, 0xea, 0x85, 0xa4, 0x61, 0xcc,
-	0x3c, 0x21, 0x76, 0xbd, 0x82, 0x24, 0xbd, 0xd0, 0x20, 0x1f, 0x29, 0x18, 0x23, 0x89, 0x0d, 0x6c,
-	0x9a, 0x31, 0x20, 0x00, 0x00, 0xff, 0xff, 0xf8, 0xe2, 0x9c, 0x2e, 0x5e, 0x00, 0x00, 0x00,
+var fileDescriptor_shorter_284723024f260b62 = []byte{
+	// 760 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x54, 0x4d, 0x6f, 0xe3, 0x36,
+	0x10, 0x85, 0x2c, 0xc9, 0x8e, 0xc7, 0xce, 0x47, 0x89, 0xa2, 0x50, 0x94, 0x36, 0x71, 0x85, 0x16,
+	0x70, 0x51, 0xc0, 0x29, 0xdc, 0x4b, 0x13, 0xa0, 0x05, 0xf2, 0xd5, 0x36, 0xa8, 0x0f, 0x05, 0xd3,
+	0xf4, 0xb0, 0x17, 0x83, 0x96, 0x68, 0x47, 0x88, 0x2c, 0x69, 0x49, 0x7a, 0xe1, 0xfc, 0xaa, 0xdd,
+	0xfb, 0xfe, 0x8b, 0xfd, 0x45, 0x8b, 0x21, 0x45, 0x7f, 0xc5, 0x01, 0x82, 0xbd, 0x71, 0xde, 0x3c,
+	0x92, 0x8f, 0x8f, 0x33, 0x03, 0xbb, 0xf2, 0xa1, 0x10, 0x8a, 0x8b, 0x5e, 0x29, 0x0a, 0x55, 0x90,
+	0x5a, 0x39, 0x0a, 0x8f, 0x27, 0x45, 0x31, 0xc9, 0xf8, 0xa9, 0x46, 0x46, 0xb3, 0xf1, 0x69, 0x32,
+	0x13, 0x4c, 0xa5, 0x45, 0x6e, 0x38, 0xe1, 0xc9, 0x66, 0x5e, 0xa5, 0x53, 0x2e, 0x15, 0x9b, 0x96,
+	0x86, 0x10, 0x7d, 0x74, 0xc0, 0xbd, 0xa7, 0x03, 0x42, 0xc0, 0x63, 0x49, 0x22, 0x02, 0xa7, 0xe3,
+	0x74, 0x9b, 0x54, 0xaf, 0x49, 0x08, 0x3b, 0x82, 0x27, 0xa9, 0xe0, 0xb1, 0x0a, 0x6a, 0x1d, 0xa7,
+	0xeb, 0xd3, 0x45, 0x4c, 0xbe, 0x06, 0x9f, 0x65, 0x29, 0x93, 0x81, 0xab, 0x37, 0x98, 0x80, 0x9c,
+	0x01, 0xf0, 0x79, 0x99, 0x0a, 0x2e, 0x87, 0x4c, 0x05, 0x5e, 0xc7, 0xe9, 0xb6, 0xfa, 0x61, 0xcf,
+	0x68, 0xe8, 0x59, 0x0d, 0xbd, 0xff, 0xac, 0x06, 0xda, 0xac, 0xd8, 0x17, 0x8a, 0x7c, 0x07, 0x30,
+	0x65, 0xf3, 0x61, 0x9c, 0xa5, 0xf1, 0xa3, 0x0c, 0xfc, 0x8e, 0xd3, 0x75, 0x69, 0x73, 0xca, 0xe6,
+	0x57, 0x1a, 0xc0, 0xfb, 0xe2, 0x8c, 0x33, 0x11, 0xd4, 0x3b, 0x2e, 0xde, 0xa7, 0x83, 0x28, 0x04,
+	0xef, 0xaa, 0x48, 0x38, 0xaa, 0x8f, 0x8b, 0x84, 0x5b, 0xf5, 0xb8, 0x8e, 0x7e, 0x00, 0xff, 0x92,
+	0xa9, 0xf8, 0x81, 0x1c, 0x81, 0x37, 0x13, 0x99, 0x0c, 0x9c, 0x8e, 0xdb, 0x6d, 0xf5, 0x1b, 0xbd,
+	0x72, 0xd4, 0xbb, 0xa7, 0x03, 0xaa, 0xc1, 0xe8, 0x0c, 0xda, 0x9a, 0x45, 0xb9, 0x9c, 0x65, 0x4a,
+	0x92, 0x9f, 0xa0, 0x21, 0xcc, 0xb2, 0xe2, 0xef, 0x23, 0x7f, 0x85, 0x42, 0x6d, 0x3e, 0xba, 0x85,
+	0xd6, 0x0a, 0x4e, 0x0e, 0xc1, 0x9d, 0x89, 0x4c, 0x4b, 0x58, 0xb9, 0x05, 0x31, 0x72, 0x02, 0x3e,
+	0x17, 0xa2, 0x10, 0xda, 0xc5, 0x56, 0xbf, 0x89, 0xc9, 0x1b, 0x04, 0xa8, 0xc1, 0xa3, 0x73, 0xd8,
+	0xbd, 0x53, 0x82, 0xb3, 0x29, 0xe5, 0x6f, 0x67, 0x5c, 0x2a, 0xb2, 0x07, 0xb5, 0x34, 0xd1, 0x67,
+	0x79, 0xb4, 0x96, 0x26, 0xf6, 0xf0, 0xda, 0xf3, 0xc3, 0xa3, 0x37, 0xd0, 0xb6, 0x7b, 0xb5, 0x8e,
+	0xd7, 0x6f, 0x5d, 0xea, 0x72, 0x5f, 0xd0, 0xf5, 0xc1, 0x01, 0x5f, 0x03, 0x6b, 0x0e, 0xfb, 0xc6,
+	0x61, 0x12, 0x40, 0x63, 0xca, 0xa5, 0x64, 0x13, 0xae, 0x4f, 0x6f, 0x52, 0x1b, 0x92, 0xdf, 0xe1,
+	0x60, 0x9c, 0xf2, 0x2c, 0x19, 0xbe, 0x4b, 0x8b, 0x4c, 0xd7, 0x23, 0x16, 0x0a, 0xda, 0x49, 0xf0,
+	0x8e, 0x3f, 0x31, 0xf7, 0xbf, 0x4d, 0xd1, 0xfd, 0xf1, 0x5a, 0x2c, 0xc9, 0x39, 0xb4, 0x04, 0x57,
+	0xe2, 0x69, 0xc8, 0xc6, 0x8a, 0x8b, 0xaa, 0x8e, 0x0e, 0x9f, 0xd5, 0xd1, 0x75, 0x55, 0xeb, 0x14,
+	0x34, 0xfb, 0x02, 0xc9, 0xd1, 0x3f, 0x00, 0x97, 0x2c, 0xb1, 0x3e, 0x6e, 0x13, 0xe2, 0xbc, 0x5a,
+	0x48, 0xf4, 0x37, 0xec, 0xad, 0x53, 0xb0, 0x0e, 0x35, 0xa9, 0x2a, 0x35, 0x13, 0x90, 0x0e, 0xb4,
+	0x12, 0x2e, 0x63, 0x91, 0x96, 0x48, 0xaa, 0xdc, 0x58, 0x85, 0xa2, 0xbf, 0xa0, 0x49, 0x51, 0xe4,
+	0x6d, 0x3e, 0x2e, 0x96, 0xef, 0x4b, 0x78, 0xc6, 0x9e, 0x02, 0xe7, 0x75, 0xef, 0xbb, 0x46, 0x72,
+	0xf4, 0xc9, 0x01, 0x6f, 0x90, 0xe6, 0x8f, 0xdb, 0x6a, 0x1e, 0xd5, 0xe9, 0x19, 0x51, 0x29, 0x30,
+	0xc1, 0xa2, 0xb7, 0xdd, 0x17, 0x7a, 0xdb, 0xdb, 0xe8, 0xed, 0xf5, 0x2e, 0xf6, 0xbf, 0xbc, 0x8b,
+	0xeb, 0x9b, 0x5d, 0xfc, 0x0d, 0xd4, 0xab, 0x54, 0x43, 0xa7, 0xaa, 0x28, 0x3a, 0x83, 0xd6, 0x20,
+	0x95, 0xca, 0xfe, 0x1a, 0x0e, 0x17, 0xfd, 0xf3, 0x95, 0xc9, 0x3a, 0x40, 0x34, 0x4b, 0xa7, 0xa9,
+	0x9d, 0x45, 0x26, 0x88, 0xfe, 0x80, 0x1d, 0xb4, 0xe3, 0x5f, 0x2c, 0xbb, 0x63, 0x64, 0xe4, 0x8f,
+	0xf6, 0x8b, 0x77, 0xf0, 0x8b, 0x31, 0x49, 0x0d, 0x8c, 0x46, 0xe4, 0x7c, 0x6e, 0xdd, 0xd1, 0xeb,
+	0xe8, 0x0a, 0x00, 0x29, 0xf7, 0x65, 0xc2, 0xd4, 0xd6, 0x41, 0x42, 0xbe, 0x87, 0x46, 0xfc, 0xc0,
+	0xf2, 0x09, 0x97, 0x9b, 0x4d, 0x64, 0xf1, 0xfe, 0xfb, 0x1a, 0x34, 0xee, 0xcc, 0x70, 0x26, 0x47,
+	0x76, 0x99, 0x13, 0x4b, 0x0c, 0xed, 0x82, 0x1c, 0x41, 0xfd, 0x66, 0x5e, 0xb2, 0x3c, 0x21, 0x5a,
+	0x1c, 0x0e, 0xaf, 0x65, 0xf2, 0x67, 0x68, 0x57, 0x3b, 0xcd, 0xe0, 0x6a, 0x2e, 0x46, 0x4f, 0x78,
+	0xb0, 0x31, 0x85, 0x24, 0xf9, 0x0d, 0x76, 0x2b, 0xb2, 0xe9, 0x7e, 0xf2, 0x15, 0x52, 0xd6, 0xa6,
+	0x48, 0x78, 0xb0, 0x0a, 0xe1, 0xb6, 0xae, 0xf3, 0x8b, 0x43, 0x42, 0xf0, 0x74, 0x15, 0x2e, 0x15,
+	0x2c, 0x8c, 0x22, 0x3f, 0x62, 0x71, 0x49, 0x45, 0xf6, 0x0d, 0xb2, 0xf8, 0x92, 0xb0, 0x6d, 0x29,
+	0xda, 0xe8, 0x08, 0xea, 0x95, 0x61, 0x7b, 0x16, 0x37, 0xf1, 0xca, 0x51, 0xdf, 0x42, 0xfd, 0x9a,
+	0x67, 0x5c, 0xf1, 0x6d, 0x17, 0x8d, 0xea, 0xba, 0x8e, 0x7e, 0xfd, 0x1c, 0x00, 0x00, 0xff, 0xff,
+	0x7f, 0x28, 0x0a, 0xee, 0xd4, 0x06, 0x00, 0x00,
 }
@@ -0,0 +1,281 @@
+package reflection
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// The messages below are grpc.reflection.v1alpha's, written by hand because
+// their oneofs are beyond what we generate. They marshal themselves, which
+// grpc's proto codec prefers over reflection.
+
+// Field numbers of the ServerReflectionRequest oneof.
+const (
+	fileByFilename            = 3
+	fileContainingSymbol      = 4
+	fileContainingExtension   = 5
+	allExtensionNumbersOfType = 6
+	listServices              = 7
+)
+
+// ServerReflectionRequest is grpc.reflection.v1alpha.ServerReflectionRequest.
+// Only one of the fields after Host is meant to be set.
+type ServerReflectionRequest struct {
+	Host                      string
+	FileByFilename            string
+	FileContainingSymbol      string
+	AllExtensionNumbersOfType string
+	ListServices              string
+
+	// which is the field number of the oneof field that was sent, which
+	// can't be told from the values alone as they may be empty.
+	which int
+}
+
+func (m *ServerReflectionRequest) Reset()         { *m = ServerReflectionRequest{} }
+func (m *ServerReflectionRequest) String() string { return fmt.Sprintf("%+v", *m) }
+func (*ServerReflectionRequest) ProtoMessage()    {}
+
+// kind returns the field number of the oneof field set on m.
+func (m *ServerReflectionRequest) kind() int {
+	switch {
+	case m.which != 0:
+		return m.which
+	case m.FileByFilename != "":
+		return fileByFilename
+	case m.FileContainingSymbol != "":
+		return fileContainingSymbol
+	case m.AllExtensionNumbersOfType != "":
+		return allExtensionNumbersOfType
+	case m.ListServices != "":
+		return listServices
+	}
+	return 0
+}
+
+// Marshal implements proto.Marshaler.
+func (m *ServerReflectionRequest) Marshal() ([]byte, error) {
+	var b []byte
+	b = appendString(b, 1, m.Host)
+	switch m.kind() {
+	case fileByFilename:
+		b = appendBytes(b, fileByFilename, []byte(m.FileByFilename))
+	case fileContainingSymbol:
+		b = appendBytes(b, fileContainingSymbol, []byte(m.FileContainingSymbol))
+	case allExtensionNumbersOfType:
+		b = appendBytes(b, allExtensionNumbersOfType, []byte(m.AllExtensionNumbersOfType))
+	case listServices:
+		b = appendBytes(b, listServices, []byte(m.ListServices))
+	}
+	return b, nil
+}
+
+// Unmarshal implements proto.Unmarshaler.
+func (m *ServerReflectionRequest) Unmarshal(b []byte) error {
+	return decodeFields(b, func(num int, _ uint64, raw []byte) error {
+		switch num {
+		case 1:
+			m.Host = string(raw)
+		case fileByFilename:
+			m.FileByFilename = string(raw)
+		case fileContainingSymbol:
+			m.FileContainingSymbol = string(raw)
+		case allExtensionNumbersOfType:
+			m.AllExtensionNumbersOfType = string(raw)
+		case listServices:
+			m.ListServices = string(raw)
+		}
+		if num > 1 {
+			m.which = num
+		}
+		return nil
+	})
+}
+
+// ServerReflectionResponse is grpc.reflection.v1alpha.ServerReflectionResponse.
+type ServerReflectionResponse struct {
+	ValidHost              string
+	OriginalRequest        *ServerReflectionRequest
+	FileDescriptorResponse *FileDescriptorResponse
+	ListServicesResponse   *ListServiceResponse
+	ErrorResponse          *ErrorResponse
+}
+
+func (m *ServerReflectionResponse) Reset()         { *m = ServerReflectionResponse{} }
+func (m *ServerReflectionResponse) String() string { return fmt.Sprintf("%+v", *m) }
+func (*ServerReflectionResponse) ProtoMessage()    {}
+
+// Marshal implements proto.Marshaler.
+func (m *ServerReflectionResponse) Marshal() ([]byte, error) {
+	var b []byte
+	b = appendString(b, 1, m.ValidHost)
+	if m.OriginalRequest != nil {
+		req, _ := m.OriginalRequest.Marshal()
+		b = appendBytes(b, 2, req)
+	}
+	switch {
+	case m.FileDescriptorResponse != nil:
+		var fd []byte
+		for _, f := range m.FileDescriptorResponse.FileDescriptorProto {
+			fd = appendBytes(fd, 1, f)
+		}
+		b = appendBytes(b, 4, fd)
+	case m.ListServicesResponse != nil:
+		var ls []byte
+		for _, s := range m.ListServicesResponse.Service {
+			ls = appendBytes(ls, 1, appendString(nil, 1, s.Name))
+		}
+		b = appendBytes(b, 6, ls)
+	case m.ErrorResponse != nil:
+		var e []byte
+		e = appendVarint(e, 1, uint64(m.ErrorResponse.ErrorCode))
+		e = appendString(e, 2, m.ErrorResponse.ErrorMessage)
+		b = appendBytes(b, 7, e)
+	}
+	return b, nil
+}
+
+// Unmarshal implements proto.Unmarshaler.
+func (m *ServerReflectionResponse) Unmarshal(b []byte) error {
+	return decodeFields(b, func(num int, _ uint64, raw []byte) error {
+		switch num {
+		case 1:
+			m.ValidHost = string(raw)
+		case 2:
+			m.OriginalRequest = &ServerReflectionRequest{}
+			return m.OriginalRequest.Unmarshal(raw)
+		case 4:
+			m.FileDescriptorResponse = &FileDescriptorResponse{}
+			return decodeFields(raw, func(num int, _ uint64, raw []byte) error {
+				if num == 1 {
+					m.FileDescriptorResponse.FileDescriptorProto = append(m.FileDescriptorResponse.FileDescriptorProto, raw)
+				}
+				return nil
+			})
+		case 6:
+			m.ListServicesResponse = &ListServiceResponse{}
+			return decodeFields(raw, func(num int, _ uint64, raw []byte) error {
+				if num != 1 {
+					return nil
+				}
+				s := &ServiceResponse{}
+				m.ListServicesResponse.Service = append(m.ListServicesResponse.Service, s)
+				return decodeFields(raw, func(num int, _ uint64, raw []byte) error {
+					if num == 1 {
+						s.Name = string(raw)
+					}
+					return nil
+				})
+			})
+		case 7:
+			m.ErrorResponse = &ErrorResponse{}
+			return decodeFields(raw, func(num int, v uint64, raw []byte) error {
+				switch num {
+				case 1:
+					m.ErrorResponse.ErrorCode = int32(v)
+				case 2:
+					m.ErrorResponse.ErrorMessage = string(raw)
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+}
+
+// FileDescriptorResponse holds serialized FileDescriptorProtos.
+type FileDescriptorResponse struct {
+	FileDescriptorProto [][]byte
+}
+
+// ListServiceResponse lists the services a server exposes.
+type ListServiceResponse struct {
+	Service []*ServiceResponse
+}
+
+// ServiceResponse names a single service.
+type ServiceResponse struct {
+	Name string
+}
+
+// ErrorResponse is sent in place of a response the server couldn't build.
+type ErrorResponse struct {
+	ErrorCode    int32
+	ErrorMessage string
+}
+
+var errTruncated = errors.New("reflection: truncated message")
+
+// decodeFields calls f with every field of the message in b: varints and
+// fixed width values in v, length delimited ones in raw.
+func decodeFields(b []byte, f func(num int, v uint64, raw []byte) error) error {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errTruncated
+		}
+		b = b[n:]
+
+		var (
+			v   uint64
+			raw []byte
+		)
+		switch key & 7 {
+		case 0:
+			v, n = binary.Uvarint(b)
+			if n <= 0 {
+				return errTruncated
+			}
+			b = b[n:]
+		case 1:
+			if len(b) < 8 {
+				return errTruncated
+			}
+			v, b = binary.LittleEndian.Uint64(b), b[8:]
+		case 2:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < l {
+				return errTruncated
+			}
+			raw, b = b[n:n+int(l)], b[n+int(l):]
+		case 5:
+			if len(b) < 4 {
+				return errTruncated
+			}
+			v, b = uint64(binary.LittleEndian.Uint32(b)), b[4:]
+		default:
+			return fmt.Errorf("reflection: unsupported wire type %d", key&7)
+		}
+		if err := f(int(key>>3), v, raw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+func appendVarint(b []byte, num int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = appendUvarint(b, uint64(num)<<3)
+	return appendUvarint(b, v)
+}
+
+func appendBytes(b []byte, num int, raw []byte) []byte {
+	b = appendUvarint(b, uint64(num)<<3|2)
+	b = appendUvarint(b, uint64(len(raw)))
+	return append(b, raw...)
+}
+
+func appendString(b []byte, num int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	return appendBytes(b, num, []byte(s))
+}
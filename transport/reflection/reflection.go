@@ -0,0 +1,206 @@
+// Package reflection implements grpc.reflection.v1alpha.ServerReflection, so
+// tools like grpcurl can discover and call a server's services without a
+// copy of its protos.
+package reflection
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ServiceName is the fully qualified name of the reflection service.
+const ServiceName = "grpc.reflection.v1alpha.ServerReflection"
+
+// Register registers the reflection service on s. It describes whatever is
+// registered on s at the time a request comes in.
+func Register(s *grpc.Server) {
+	s.RegisterService(&serviceDesc, &server{s: s})
+}
+
+// ServerReflectionServer is the server API for the reflection service.
+type ServerReflectionServer interface {
+	ServerReflectionInfo(grpc.ServerStream) error
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*ServerReflectionServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName: "ServerReflectionInfo",
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				return srv.(ServerReflectionServer).ServerReflectionInfo(stream)
+			},
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "reflection/grpc_reflection_v1alpha/reflection.proto",
+}
+
+type server struct {
+	s *grpc.Server
+}
+
+// ServerReflectionInfo answers requests until the client closes the stream.
+// Files already sent on the stream aren't sent again as dependencies.
+func (r *server) ServerReflectionInfo(stream grpc.ServerStream) error {
+	sent := make(map[string]bool)
+	for {
+		req := new(ServerReflectionRequest)
+		if err := stream.RecvMsg(req); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		resp := &ServerReflectionResponse{ValidHost: req.Host, OriginalRequest: req}
+		var err error
+		switch req.kind() {
+		case fileByFilename:
+			resp.FileDescriptorResponse, err = r.fileByName(req.FileByFilename, sent)
+		case fileContainingSymbol:
+			resp.FileDescriptorResponse, err = r.fileContainingSymbol(req.FileContainingSymbol, sent)
+		case fileContainingExtension, allExtensionNumbersOfType:
+			err = status.Error(codes.NotFound, "extensions aren't supported")
+		case listServices:
+			resp.ListServicesResponse = r.listServices()
+		default:
+			err = status.Errorf(codes.InvalidArgument, "invalid request %v", req)
+		}
+		if err != nil {
+			st := status.Convert(err)
+			resp.ErrorResponse = &ErrorResponse{ErrorCode: int32(st.Code()), ErrorMessage: st.Message()}
+		}
+		if err := stream.SendMsg(resp); err != nil {
+			return err
+		}
+	}
+}
+
+func (r *server) listServices() *ListServiceResponse {
+	resp := &ListServiceResponse{}
+	for name := range r.s.GetServiceInfo() {
+		resp.Service = append(resp.Service, &ServiceResponse{Name: name})
+	}
+	return resp
+}
+
+func (r *server) fileByName(name string, sent map[string]bool) (*FileDescriptorResponse, error) {
+	gz := proto.FileDescriptor(name)
+	if gz == nil {
+		return nil, status.Errorf(codes.NotFound, "unknown file %q", name)
+	}
+	return withDependencies(gz, sent)
+}
+
+// fileContainingSymbol looks symbol up among the registered services, their
+// methods and then the registered messages.
+func (r *server) fileContainingSymbol(symbol string, sent map[string]bool) (*FileDescriptorResponse, error) {
+	services := r.s.GetServiceInfo()
+	if info, ok := services[symbol]; ok {
+		if file, ok := info.Metadata.(string); ok {
+			return r.fileByName(file, sent)
+		}
+	}
+	if i := strings.LastIndex(symbol, "."); i > 0 {
+		if info, ok := services[symbol[:i]]; ok {
+			for _, m := range info.Methods {
+				if file, ok := info.Metadata.(string); ok && m.Name == symbol[i+1:] {
+					return r.fileByName(file, sent)
+				}
+			}
+		}
+	}
+	if t := proto.MessageType(symbol); t != nil {
+		if d, ok := reflect.Zero(t).Interface().(interface{ Descriptor() ([]byte, []int) }); ok {
+			gz, _ := d.Descriptor()
+			return withDependencies(gz, sent)
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "unknown symbol %q", symbol)
+}
+
+// withDependencies returns the file described by gz, followed by every file
+// it imports that isn't in sent yet.
+func withDependencies(gz []byte, sent map[string]bool) (*FileDescriptorResponse, error) {
+	resp := &FileDescriptorResponse{}
+	var add func(gz []byte, always bool) error
+	add = func(gz []byte, always bool) error {
+		fd, err := decompress(gz)
+		if err != nil {
+			return err
+		}
+		name, deps, err := parseFile(fd)
+		if err != nil {
+			return err
+		}
+		if sent[name] && !always {
+			return nil
+		}
+		sent[name] = true
+		resp.FileDescriptorProto = append(resp.FileDescriptorProto, fd)
+
+		for _, dep := range deps {
+			if sent[dep] {
+				continue
+			}
+			depGz := proto.FileDescriptor(dep)
+			if depGz == nil {
+				return status.Errorf(codes.NotFound, "unknown dependency %q of %q", dep, name)
+			}
+			if err := add(depGz, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := add(gz, true); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func decompress(gz []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(gz))
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "bad file descriptor: %v", err)
+	}
+	defer r.Close()
+	fd, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "bad file descriptor: %v", err)
+	}
+	return fd, nil
+}
+
+// parseFile reads the name and dependencies of a serialized
+// FileDescriptorProto, which is all reflection needs to know about it.
+func parseFile(fd []byte) (name string, deps []string, err error) {
+	err = decodeFields(fd, func(num int, _ uint64, raw []byte) error {
+		switch num {
+		case 1:
+			name = string(raw)
+		case 3:
+			deps = append(deps, string(raw))
+		}
+		return nil
+	})
+	if err != nil {
+		return "", nil, status.Errorf(codes.Internal, "bad file descriptor: %v", err)
+	}
+	if name == "" {
+		return "", nil, status.Error(codes.Internal, "bad file descriptor: no name")
+	}
+	return name, deps, nil
+}
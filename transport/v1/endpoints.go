@@ -0,0 +1,83 @@
+package v1
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/endpoint"
+)
+
+// ShorterEndpoints is a Shorter made of an endpoint per operation, like the
+// ones NewShorterGRPCClient and NewShorterHTTPClient return. Its endpoints
+// can be wrapped in middleware to add deadlines, retries and the like.
+type ShorterEndpoints struct {
+	ShortenEndpoint      endpoint.Endpoint
+	ExpandEndpoint       endpoint.Endpoint
+	ShortenBatchEndpoint endpoint.Endpoint
+	InfoEndpoint         endpoint.Endpoint
+	ListEndpoint         endpoint.Endpoint
+	UpdateEndpoint       endpoint.Endpoint
+	DeleteEndpoint       endpoint.Endpoint
+}
+
+// Shorten calls ShortenEndpoint.
+func (e ShorterEndpoints) Shorten(ctx context.Context, Long URL) (Body *URL, err error) {
+	resp, err := e.ShortenEndpoint(ctx, _shortenRequest{Long: Long})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(_shortenResponse).Body, nil
+}
+
+// Expand calls ExpandEndpoint.
+func (e ShorterEndpoints) Expand(ctx context.Context, Code string) (Body *URL, err error) {
+	resp, err := e.ExpandEndpoint(ctx, _expandRequest{Code: Code})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(_expandResponse).Body, nil
+}
+
+// ShortenBatch calls ShortenBatchEndpoint.
+func (e ShorterEndpoints) ShortenBatch(ctx context.Context, Longs []URL) (Body []BatchResult, err error) {
+	resp, err := e.ShortenBatchEndpoint(ctx, _shortenBatchRequest{Longs: Longs})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(_shortenBatchResponse).Body, nil
+}
+
+// Info calls InfoEndpoint.
+func (e ShorterEndpoints) Info(ctx context.Context, Code string) (Body *Link, err error) {
+	resp, err := e.InfoEndpoint(ctx, _infoRequest{Code: Code})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(_infoResponse).Body, nil
+}
+
+// List calls ListEndpoint.
+func (e ShorterEndpoints) List(ctx context.Context, After string, Limit int) (Body *LinkPage, err error) {
+	resp, err := e.ListEndpoint(ctx, _listRequest{After: After, Limit: Limit})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(_listResponse).Body, nil
+}
+
+// Update calls UpdateEndpoint.
+func (e ShorterEndpoints) Update(ctx context.Context, Code string, Changes URL) (Body *Link, err error) {
+	resp, err := e.UpdateEndpoint(ctx, _updateRequest{Code: Code, Changes: Changes})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(_updateResponse).Body, nil
+}
+
+// Delete calls DeleteEndpoint.
+func (e ShorterEndpoints) Delete(ctx context.Context, Code string) (Body *Link, err error) {
+	resp, err := e.DeleteEndpoint(ctx, _deleteRequest{Code: Code})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(_deleteResponse).Body, nil
+}
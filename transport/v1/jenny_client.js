@@ -7,6 +7,45 @@ import url from 'url';
 
 type URL = {
     Addr?: string,
+    Redirect?: number,
+    Alias?: string,
+    ExpiresAt?: string,
+    MaxClicks?: number,
+    Clear?: Array<string>,
+}
+
+type Link = {
+    Code?: string,
+    Short?: string,
+    Addr?: string,
+    Redirect?: number,
+    ExpiresAt?: string,
+    MaxClicks?: number,
+    Clicks?: number,
+}
+
+type LinkPage = {
+    Links?: Array<Link>,
+    Next?: string,
+}
+
+type BatchResult = {
+    URL?: URL,
+    Error?: Error,
+}
+
+type Error = {
+    Status?: number,
+    Error?: string,
+    Message?: string,
+    FieldViolations?: Array<FieldViolation>,
+    RetryAfter?: number,
+    Code?: number,
+}
+
+type FieldViolation = {
+    Field?: string,
+    Description?: string,
 }
 
 
@@ -24,4 +63,52 @@ export default class ShorterClient {
   return data
 }
 
+  async ShortenBatch( Longs: Array<URL>,) : Promise<Array<BatchResult>>  {
+  let pathMaker = matchstick(this.baseURL+`/shorten/batch`, 'template');
+  let path = pathMaker.stick({  longs: Longs, })
+  let u = url.parse(path)
+  let data : Array<BatchResult>  =  await fetch(path);
+  return data
+}
+
+  async Expand( Code: string,) : Promise<URL>  {
+  let pathMaker = matchstick(this.baseURL+`/expand/{code}`, 'template');
+  let path = pathMaker.stick({  code: Code, })
+  let u = url.parse(path)
+  let data : URL  =  await fetch(path);
+  return data
+}
+
+  async List( After: string, Limit: number,) : Promise<LinkPage>  {
+  let pathMaker = matchstick(this.baseURL+`/links`, 'template');
+  let path = pathMaker.stick({  after: After,  limit: Limit, })
+  let u = url.parse(path)
+  let data : LinkPage  =  await fetch(path);
+  return data
+}
+
+  async Info( Code: string,) : Promise<Link>  {
+  let pathMaker = matchstick(this.baseURL+`/links/{code}`, 'template');
+  let path = pathMaker.stick({  code: Code, })
+  let u = url.parse(path)
+  let data : Link  =  await fetch(path);
+  return data
+}
+
+  async Update( Code: string, Changes: URL,) : Promise<Link>  {
+  let pathMaker = matchstick(this.baseURL+`/links/{code}`, 'template');
+  let path = pathMaker.stick({  code: Code,  changes: Changes, })
+  let u = url.parse(path)
+  let data : Link  =  await fetch(path);
+  return data
+}
+
+  async Delete( Code: string,) : Promise<Link>  {
+  let pathMaker = matchstick(this.baseURL+`/links/{code}`, 'template');
+  let path = pathMaker.stick({  code: Code, })
+  let u = url.parse(path)
+  let data : Link  =  await fetch(path);
+  return data
+}
+
 }
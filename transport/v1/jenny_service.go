@@ -6,6 +6,7 @@ package v1
 
 import (
 	"context"
+	"time"
 
 	"github.com/go-kit/kit/endpoint"
 	"github.com/jennyservices/jenny/options"
@@ -17,11 +18,73 @@ type Shorter interface {
 
 	// Shorten Gets a User from the database
 	Shorten(ctx context.Context, Long URL) (Body *URL, err error)
+
+	// Expand Expands a short code to the URL it points to
+	Expand(ctx context.Context, Code string) (Body *URL, err error)
+
+	// ShortenBatch Shortens a list of URLs, reporting success or failure for each
+	ShortenBatch(ctx context.Context, Longs []URL) (Body []BatchResult, err error)
+
+	// Info Describes the link behind a code without counting a click
+	Info(ctx context.Context, Code string) (Body *Link, err error)
+
+	// List Lists the caller's links ordered by code, a page at a time
+	List(ctx context.Context, After string, Limit int) (Body *LinkPage, err error)
+
+	// Update Changes the settings of a link
+	Update(ctx context.Context, Code string, Changes URL) (Body *Link, err error)
+
+	// Delete Deletes a link
+	Delete(ctx context.Context, Code string) (Body *Link, err error)
 }
 
 // URL is generated from a swagger definition
 type URL struct {
-	Addr string `json:"addr"` // Addr is generated from a swagger definition
+	Addr      string     `json:"addr"`                 // Addr is generated from a swagger definition
+	Redirect  int        `json:"redirect,omitempty"`   // Redirect is generated from a swagger definition
+	Alias     string     `json:"alias,omitempty"`      // Alias is generated from a swagger definition
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // ExpiresAt is generated from a swagger definition
+	MaxClicks int        `json:"max_clicks,omitempty"` // MaxClicks is generated from a swagger definition
+	Clear     []string   `json:"clear,omitempty"`      // Clear is generated from a swagger definition
+}
+
+// Link is generated from a swagger definition
+type Link struct {
+	Code      string     `json:"code"`                 // Code is generated from a swagger definition
+	Short     string     `json:"short"`                // Short is generated from a swagger definition
+	Addr      string     `json:"addr"`                 // Addr is generated from a swagger definition
+	Redirect  int        `json:"redirect,omitempty"`   // Redirect is generated from a swagger definition
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // ExpiresAt is generated from a swagger definition
+	MaxClicks int        `json:"max_clicks,omitempty"` // MaxClicks is generated from a swagger definition
+	Clicks    int        `json:"clicks"`               // Clicks is generated from a swagger definition
+}
+
+// LinkPage is generated from a swagger definition
+type LinkPage struct {
+	Links []Link `json:"links"`          // Links is generated from a swagger definition
+	Next  string `json:"next,omitempty"` // Next is generated from a swagger definition
+}
+
+// BatchResult is generated from a swagger definition
+type BatchResult struct {
+	URL   *URL   `json:"url,omitempty"`   // URL is generated from a swagger definition
+	Error *Error `json:"error,omitempty"` // Error is generated from a swagger definition
+}
+
+// Error is generated from a swagger definition
+type Error struct {
+	Status          int              `json:"status"`                     // Status is generated from a swagger definition
+	Error           string           `json:"error"`                      // Error is generated from a swagger definition
+	Message         string           `json:"message"`                    // Message is generated from a swagger definition
+	FieldViolations []FieldViolation `json:"field_violations,omitempty"` // FieldViolations is generated from a swagger definition
+	RetryAfter      int              `json:"retry_after,omitempty"`      // RetryAfter is generated from a swagger definition
+	Code            int              `json:"code,omitempty"`             // Code is generated from a swagger definition
+}
+
+// FieldViolation is generated from a swagger definition
+type FieldViolation struct {
+	Field       string `json:"field"`       // Field is generated from a swagger definition
+	Description string `json:"description"` // Description is generated from a swagger definition
 }
 
 // _shortenRequest is not to be used outside of this file.
@@ -38,6 +101,92 @@ type _shortenResponse struct {
 
 }
 
+// _expandRequest is not to be used outside of this file.
+// see https://gokit.io/examples/stringsvc.html#requests-and-responses for more detail
+type _expandRequest struct {
+	Code string `json:"code"` // Code is generated from a swagger definition
+
+}
+
+// _expandResponse is not to be used outside of this file.
+// see https://gokit.io/examples/stringsvc.html#requests-and-responses for more detail
+type _expandResponse struct {
+	Body *URL `json:"body,omitempty"` // Body is generated from a swagger definition
+
+}
+
+// _shortenBatchRequest is not to be used outside of this file.
+// see https://gokit.io/examples/stringsvc.html#requests-and-responses for more detail
+type _shortenBatchRequest struct {
+	Longs []URL `json:"longs"` // Longs is generated from a swagger definition
+
+}
+
+// _shortenBatchResponse is not to be used outside of this file.
+// see https://gokit.io/examples/stringsvc.html#requests-and-responses for more detail
+type _shortenBatchResponse struct {
+	Body []BatchResult `json:"body,omitempty"` // Body is generated from a swagger definition
+
+}
+
+// _infoRequest is not to be used outside of this file.
+// see https://gokit.io/examples/stringsvc.html#requests-and-responses for more detail
+type _infoRequest struct {
+	Code string `json:"code"` // Code is generated from a swagger definition
+
+}
+
+// _infoResponse is not to be used outside of this file.
+// see https://gokit.io/examples/stringsvc.html#requests-and-responses for more detail
+type _infoResponse struct {
+	Body *Link `json:"body,omitempty"` // Body is generated from a swagger definition
+
+}
+
+// _listRequest is not to be used outside of this file.
+// see https://gokit.io/examples/stringsvc.html#requests-and-responses for more detail
+type _listRequest struct {
+	After string `json:"after"` // After is generated from a swagger definition
+	Limit int    `json:"limit"` // Limit is generated from a swagger definition
+
+}
+
+// _listResponse is not to be used outside of this file.
+// see https://gokit.io/examples/stringsvc.html#requests-and-responses for more detail
+type _listResponse struct {
+	Body *LinkPage `json:"body,omitempty"` // Body is generated from a swagger definition
+
+}
+
+// _updateRequest is not to be used outside of this file.
+// see https://gokit.io/examples/stringsvc.html#requests-and-responses for more detail
+type _updateRequest struct {
+	Code    string `json:"code"`    // Code is generated from a swagger definition
+	Changes URL    `json:"changes"` // Changes is generated from a swagger definition
+
+}
+
+// _updateResponse is not to be used outside of this file.
+// see https://gokit.io/examples/stringsvc.html#requests-and-responses for more detail
+type _updateResponse struct {
+	Body *Link `json:"body,omitempty"` // Body is generated from a swagger definition
+
+}
+
+// _deleteRequest is not to be used outside of this file.
+// see https://gokit.io/examples/stringsvc.html#requests-and-responses for more detail
+type _deleteRequest struct {
+	Code string `json:"code"` // Code is generated from a swagger definition
+
+}
+
+// _deleteResponse is not to be used outside of this file.
+// see https://gokit.io/examples/stringsvc.html#requests-and-responses for more detail
+type _deleteResponse struct {
+	Body *Link `json:"body,omitempty"` // Body is generated from a swagger definition
+
+}
+
 // endpoints as used in https://gokit.io/examples/stringsvc.html#endpoints
 func makeShortenEndpoint(svc Shorter, opts *options.Options) endpoint.Endpoint {
 	shortenEndpoint := func(ctx context.Context, request interface{}) (interface{}, error) {
@@ -56,3 +205,111 @@ func makeShortenEndpoint(svc Shorter, opts *options.Options) endpoint.Endpoint {
 
 	return shortenMiddleware(shortenEndpoint)
 }
+
+func makeExpandEndpoint(svc Shorter, opts *options.Options) endpoint.Endpoint {
+	expandEndpoint := func(ctx context.Context, request interface{}) (interface{}, error) {
+
+		req := request.(_expandRequest)
+
+		resp := _expandResponse{}
+		var err error
+
+		resp.Body, err = svc.Expand(ctx, req.Code)
+
+		return resp, err
+	}
+
+	expandMiddleware := opts.OpMiddlewares("Expand")
+
+	return expandMiddleware(expandEndpoint)
+}
+
+func makeShortenBatchEndpoint(svc Shorter, opts *options.Options) endpoint.Endpoint {
+	shortenBatchEndpoint := func(ctx context.Context, request interface{}) (interface{}, error) {
+
+		req := request.(_shortenBatchRequest)
+
+		resp := _shortenBatchResponse{}
+		var err error
+
+		resp.Body, err = svc.ShortenBatch(ctx, req.Longs)
+
+		return resp, err
+	}
+
+	shortenBatchMiddleware := opts.OpMiddlewares("ShortenBatch")
+
+	return shortenBatchMiddleware(shortenBatchEndpoint)
+}
+
+func makeInfoEndpoint(svc Shorter, opts *options.Options) endpoint.Endpoint {
+	infoEndpoint := func(ctx context.Context, request interface{}) (interface{}, error) {
+
+		req := request.(_infoRequest)
+
+		resp := _infoResponse{}
+		var err error
+
+		resp.Body, err = svc.Info(ctx, req.Code)
+
+		return resp, err
+	}
+
+	infoMiddleware := opts.OpMiddlewares("Info")
+
+	return infoMiddleware(infoEndpoint)
+}
+
+func makeListEndpoint(svc Shorter, opts *options.Options) endpoint.Endpoint {
+	listEndpoint := func(ctx context.Context, request interface{}) (interface{}, error) {
+
+		req := request.(_listRequest)
+
+		resp := _listResponse{}
+		var err error
+
+		resp.Body, err = svc.List(ctx, req.After, req.Limit)
+
+		return resp, err
+	}
+
+	listMiddleware := opts.OpMiddlewares("List")
+
+	return listMiddleware(listEndpoint)
+}
+
+func makeUpdateEndpoint(svc Shorter, opts *options.Options) endpoint.Endpoint {
+	updateEndpoint := func(ctx context.Context, request interface{}) (interface{}, error) {
+
+		req := request.(_updateRequest)
+
+		resp := _updateResponse{}
+		var err error
+
+		resp.Body, err = svc.Update(ctx, req.Code, req.Changes)
+
+		return resp, err
+	}
+
+	updateMiddleware := opts.OpMiddlewares("Update")
+
+	return updateMiddleware(updateEndpoint)
+}
+
+func makeDeleteEndpoint(svc Shorter, opts *options.Options) endpoint.Endpoint {
+	deleteEndpoint := func(ctx context.Context, request interface{}) (interface{}, error) {
+
+		req := request.(_deleteRequest)
+
+		resp := _deleteResponse{}
+		var err error
+
+		resp.Body, err = svc.Delete(ctx, req.Code)
+
+		return resp, err
+	}
+
+	deleteMiddleware := opts.OpMiddlewares("Delete")
+
+	return deleteMiddleware(deleteEndpoint)
+}
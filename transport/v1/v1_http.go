@@ -0,0 +1,271 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	pb "github.com/jennyservices/shorter/transport/pb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// NewShorterHTTPClient returns endpoints calling the HTTP API served under
+// base. Errors are reported as the gRPC status closest to what the server
+// replied with, the way NewShorterGRPCClient reports them, so callers can
+// handle both transports the same way.
+func NewShorterHTTPClient(base *url.URL, opts ...kithttp.ClientOption) ShorterEndpoints {
+	return ShorterEndpoints{
+		ShortenEndpoint: httpClientEndpoint(kithttp.NewClient(
+			http.MethodPost, apiURL(base, "/shorten"),
+			encodeShortenHTTPRequest,
+			decodeShortenHTTPResponse,
+			opts...,
+		)),
+		ExpandEndpoint: httpClientEndpoint(kithttp.NewClient(
+			http.MethodGet, apiURL(base, "/expand/"),
+			encodeExpandHTTPRequest,
+			decodeExpandHTTPResponse,
+			opts...,
+		)),
+		ShortenBatchEndpoint: httpClientEndpoint(kithttp.NewClient(
+			http.MethodPost, apiURL(base, "/shorten/batch"),
+			encodeShortenBatchHTTPRequest,
+			decodeShortenBatchHTTPResponse,
+			opts...,
+		)),
+		InfoEndpoint: httpClientEndpoint(kithttp.NewClient(
+			http.MethodGet, apiURL(base, "/links/"),
+			encodeLinkHTTPRequest,
+			decodeInfoHTTPResponse,
+			opts...,
+		)),
+		ListEndpoint: httpClientEndpoint(kithttp.NewClient(
+			http.MethodGet, apiURL(base, "/links"),
+			encodeListHTTPRequest,
+			decodeListHTTPResponse,
+			opts...,
+		)),
+		UpdateEndpoint: httpClientEndpoint(kithttp.NewClient(
+			http.MethodPatch, apiURL(base, "/links/"),
+			encodeUpdateHTTPRequest,
+			decodeUpdateHTTPResponse,
+			opts...,
+		)),
+		DeleteEndpoint: httpClientEndpoint(kithttp.NewClient(
+			http.MethodDelete, apiURL(base, "/links/"),
+			encodeLinkHTTPRequest,
+			decodeDeleteHTTPResponse,
+			opts...,
+		)),
+	}
+}
+
+func apiURL(base *url.URL, path string) *url.URL {
+	u := *base
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	return &u
+}
+
+// setCode appends code to the path of u. A short URL is reduced to its last
+// path element, its code, since the server would only see a path it doesn't
+// route; anything else is escaped so it arrives as sent.
+func setCode(u *url.URL, code string) {
+	if short, err := url.Parse(code); err == nil && short.Scheme != "" && short.Host != "" {
+		if i := strings.LastIndex(short.Path, "/"); i >= 0 && i < len(short.Path)-1 {
+			code = short.Path[i+1:]
+		}
+	}
+	u.RawPath = u.EscapedPath() + url.PathEscape(code)
+	u.Path += code
+}
+
+// httpClientEndpoint reports requests that never got a response as gRPC
+// statuses too, Unavailable unless ctx ran out.
+func httpClientEndpoint(c *kithttp.Client) endpoint.Endpoint {
+	e := c.Endpoint()
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		resp, err := e(ctx, request)
+		if err == nil {
+			return resp, nil
+		}
+		if _, ok := err.(interface{ GRPCStatus() *status.Status }); ok {
+			return nil, err
+		}
+		switch ctx.Err() {
+		case context.DeadlineExceeded:
+			return nil, status.Error(codes.DeadlineExceeded, err.Error())
+		case context.Canceled:
+			return nil, status.Error(codes.Canceled, err.Error())
+		}
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+}
+
+func encodeShortenHTTPRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(_shortenRequest)
+	r.Header.Set("Accept", "application/json")
+	return kithttp.EncodeJSONRequest(ctx, r, req.Long)
+}
+
+func decodeShortenHTTPResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	resp := _shortenResponse{}
+	if err := decodeHTTPResponse(r, &resp.Body); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func encodeExpandHTTPRequest(_ context.Context, r *http.Request, request interface{}) error {
+	req := request.(_expandRequest)
+	r.Header.Set("Accept", "application/json")
+	setCode(r.URL, req.Code)
+	return nil
+}
+
+func decodeExpandHTTPResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	resp := _expandResponse{}
+	if err := decodeHTTPResponse(r, &resp.Body); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func encodeShortenBatchHTTPRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(_shortenBatchRequest)
+	r.Header.Set("Accept", "application/json")
+	return kithttp.EncodeJSONRequest(ctx, r, req.Longs)
+}
+
+func decodeShortenBatchHTTPResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	resp := _shortenBatchResponse{}
+	if err := decodeHTTPResponse(r, &resp.Body); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// encodeLinkHTTPRequest addresses the request to the link its code names.
+func encodeLinkHTTPRequest(_ context.Context, r *http.Request, request interface{}) error {
+	var code string
+	switch req := request.(type) {
+	case _infoRequest:
+		code = req.Code
+	case _deleteRequest:
+		code = req.Code
+	}
+	r.Header.Set("Accept", "application/json")
+	setCode(r.URL, code)
+	return nil
+}
+
+func decodeInfoHTTPResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	resp := _infoResponse{}
+	if err := decodeHTTPResponse(r, &resp.Body); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func encodeListHTTPRequest(_ context.Context, r *http.Request, request interface{}) error {
+	req := request.(_listRequest)
+	r.Header.Set("Accept", "application/json")
+	q := r.URL.Query()
+	if req.After != "" {
+		q.Set("after", req.After)
+	}
+	if req.Limit != 0 {
+		q.Set("limit", strconv.Itoa(req.Limit))
+	}
+	r.URL.RawQuery = q.Encode()
+	return nil
+}
+
+func decodeListHTTPResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	resp := _listResponse{}
+	if err := decodeHTTPResponse(r, &resp.Body); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func encodeUpdateHTTPRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(_updateRequest)
+	r.Header.Set("Accept", "application/json")
+	setCode(r.URL, req.Code)
+	return kithttp.EncodeJSONRequest(ctx, r, req.Changes)
+}
+
+func decodeUpdateHTTPResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	resp := _updateResponse{}
+	if err := decodeHTTPResponse(r, &resp.Body); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func decodeDeleteHTTPResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	resp := _deleteResponse{}
+	if err := decodeHTTPResponse(r, &resp.Body); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// decodeHTTPResponse decodes the JSON body of a successful response into v,
+// or returns the error the response reports.
+func decodeHTTPResponse(r *http.Response, v interface{}) error {
+	if r.StatusCode < 200 || r.StatusCode > 299 {
+		return httpClientError(r)
+	}
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return status.Errorf(codes.Internal, "decoding response: %v", err)
+	}
+	return nil
+}
+
+// httpClientError turns the Error definition a request failed with into a
+// gRPC status, with its field violations and retry delay as details.
+// Responses without one, like a proxy's, are reported by their status line.
+func httpClientError(r *http.Response) error {
+	body, _ := ioutil.ReadAll(io.LimitReader(r.Body, 64<<10))
+	var e Error
+	if err := json.Unmarshal(body, &e); err != nil || e.Message == "" {
+		e.Message = strings.TrimSpace(string(body))
+		if e.Message == "" || strings.HasPrefix(r.Header.Get("Content-Type"), "text/html") {
+			e.Message = r.Status
+		}
+	}
+	if e.RetryAfter == 0 {
+		e.RetryAfter, _ = strconv.Atoi(r.Header.Get("Retry-After"))
+	}
+
+	st := status.New(errorCode(&e, r.StatusCode), e.Message)
+	var details []proto.Message
+	if len(e.FieldViolations) > 0 {
+		br := &pb.BadRequest{}
+		for _, v := range e.FieldViolations {
+			br.FieldViolations = append(br.FieldViolations, &pb.FieldViolation{Field: v.Field, Description: v.Description})
+		}
+		details = append(details, br)
+	}
+	if e.RetryAfter > 0 {
+		details = append(details, &pb.RetryInfo{RetryDelay: ptypes.DurationProto(time.Duration(e.RetryAfter) * time.Second)})
+	}
+	if len(details) > 0 {
+		if withDetails, err := st.WithDetails(details...); err == nil {
+			st = withDetails
+		}
+	}
+	return st.Err()
+}
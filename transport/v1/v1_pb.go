@@ -2,15 +2,32 @@ package v1
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
 
 	pb "github.com/jennyservices/shorter/transport/pb"
 
 	grpctransport "github.com/go-kit/kit/transport/grpc"
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/golang/protobuf/ptypes"
 	"github.com/jennyservices/jenny/options"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 type shorterGRPCServer struct {
-	shorter grpctransport.Handler
+	shorter      grpctransport.Handler
+	expand       grpctransport.Handler
+	shortenBatch grpctransport.Handler
+	info         grpctransport.Handler
+	list         grpctransport.Handler
+	update       grpctransport.Handler
+	delete       grpctransport.Handler
 }
 
 func NewShorterGRPCServer(svc Shorter, opts ...options.Option) *shorterGRPCServer {
@@ -19,34 +36,693 @@ func NewShorterGRPCServer(svc Shorter, opts ...options.Option) *shorterGRPCServe
 		optf(svcOptions)
 	}
 	shortenEndpoint := makeShortenEndpoint(svc, svcOptions)
+	expandEndpoint := makeExpandEndpoint(svc, svcOptions)
+	shortenBatchEndpoint := makeShortenBatchEndpoint(svc, svcOptions)
+	infoEndpoint := makeInfoEndpoint(svc, svcOptions)
+	listEndpoint := makeListEndpoint(svc, svcOptions)
+	updateEndpoint := makeUpdateEndpoint(svc, svcOptions)
+	deleteEndpoint := makeDeleteEndpoint(svc, svcOptions)
 	return &shorterGRPCServer{
 		shorter: grpctransport.NewServer(
 			shortenEndpoint,
 			decodeShortenGRPCRequest,
 			encodeShortenGRPCResponse,
 		),
+		expand: grpctransport.NewServer(
+			expandEndpoint,
+			decodeExpandGRPCRequest,
+			encodeExpandGRPCResponse,
+		),
+		shortenBatch: grpctransport.NewServer(
+			shortenBatchEndpoint,
+			decodeShortenBatchGRPCRequest,
+			encodeShortenBatchGRPCResponse,
+		),
+		info: grpctransport.NewServer(
+			infoEndpoint,
+			decodeInfoGRPCRequest,
+			encodeLinkGRPCResponse,
+		),
+		list: grpctransport.NewServer(
+			listEndpoint,
+			decodeListGRPCRequest,
+			encodeListGRPCResponse,
+		),
+		update: grpctransport.NewServer(
+			updateEndpoint,
+			decodeUpdateGRPCRequest,
+			encodeLinkGRPCResponse,
+		),
+		delete: grpctransport.NewServer(
+			deleteEndpoint,
+			decodeDeleteGRPCRequest,
+			encodeLinkGRPCResponse,
+		),
+	}
+}
+
+// grpcError turns err into a gRPC status. Errors that know their status are
+// passed through, ones that only know their HTTP status code, like jenny's
+// auth errors, are mapped to the closest gRPC code, a context's errors to
+// Canceled and DeadlineExceeded, and anything else is an internal error.
+func grpcError(err error) error {
+	if _, ok := err.(interface{ GRPCStatus() *status.Status }); ok {
+		return err
+	}
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		return status.FromContextError(err).Err()
+	}
+	if sc, ok := err.(kithttp.StatusCoder); ok {
+		return status.Error(httpToGRPC(sc.StatusCode()), err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+func httpToGRPC(code int) codes.Code {
+	switch code {
+	case http.StatusBadRequest, http.StatusUnsupportedMediaType:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusGone:
+		return codes.FailedPrecondition
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+	if code >= 400 && code < 500 {
+		return codes.FailedPrecondition
+	}
+	return codes.Internal
+}
+
+// errorCode returns the gRPC code e was reported with, falling back on the
+// closest one to httpStatus for errors that don't say, like a proxy's.
+func errorCode(e *Error, httpStatus int) codes.Code {
+	if e.Code != 0 {
+		return codes.Code(e.Code)
+	}
+	return httpToGRPC(httpStatus)
+}
+
+// grpcToHTTP is the reverse of httpToGRPC, for reporting errors that came
+// over gRPC as the Error definition.
+func grpcToHTTP(code codes.Code) int {
+	switch code {
+	case codes.InvalidArgument, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.FailedPrecondition:
+		return http.StatusGone
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
 	}
+	return http.StatusInternalServerError
+}
+
+func urlFromPB(u *pb.URL) (URL, error) {
+	url := URL{
+		Addr:      u.Addr,
+		Redirect:  int(u.Redirect),
+		Alias:     u.Alias,
+		MaxClicks: int(u.MaxClicks),
+		Clear:     u.Clear,
+	}
+	if u.ExpiresAt != nil {
+		expiresAt, err := ptypes.Timestamp(u.ExpiresAt)
+		if err != nil {
+			return url, err
+		}
+		url.ExpiresAt = &expiresAt
+	}
+	return url, nil
+}
+
+func urlToPB(u *URL) (*pb.URL, error) {
+	url := &pb.URL{
+		Addr:      u.Addr,
+		Redirect:  int32(u.Redirect),
+		Alias:     u.Alias,
+		MaxClicks: int64(u.MaxClicks),
+		Clear:     u.Clear,
+	}
+	if u.ExpiresAt != nil {
+		expiresAt, err := ptypes.TimestampProto(*u.ExpiresAt)
+		if err != nil {
+			return nil, err
+		}
+		url.ExpiresAt = expiresAt
+	}
+	return url, nil
 }
 
 func decodeShortenGRPCRequest(_ context.Context, r interface{}) (interface{}, error) {
 	req := r.(*pb.URL)
+	long, err := urlFromPB(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
 	return _shortenRequest{
-		Long: URL{
-			Addr: req.Addr,
-		},
+		Long: long,
 	}, nil
 }
 
 func encodeShortenGRPCResponse(_ context.Context, r interface{}) (interface{}, error) {
 	resp := r.(_shortenResponse)
-	return &pb.URL{
-		Addr: resp.Body.Addr,
-	}, nil
+	return urlToPB(resp.Body)
 }
 func (s *shorterGRPCServer) Shorten(ctx context.Context, r *pb.URL) (*pb.URL, error) {
 	_, resp, err := s.shorter.ServeGRPC(ctx, r)
 	if err != nil {
-		return nil, err
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.URL), nil
+}
+
+func decodeExpandGRPCRequest(_ context.Context, r interface{}) (interface{}, error) {
+	req := r.(*pb.Code)
+	return _expandRequest{
+		Code: req.Code,
+	}, nil
+}
+
+func encodeExpandGRPCResponse(_ context.Context, r interface{}) (interface{}, error) {
+	resp := r.(_expandResponse)
+	return urlToPB(resp.Body)
+}
+func (s *shorterGRPCServer) Expand(ctx context.Context, r *pb.Code) (*pb.URL, error) {
+	_, resp, err := s.expand.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
 	}
 	return resp.(*pb.URL), nil
 }
+
+func errorToPB(e *Error) *pb.Error {
+	err := &pb.Error{
+		Code:    int32(errorCode(e, e.Status)),
+		Message: e.Message,
+	}
+	for _, v := range e.FieldViolations {
+		err.FieldViolations = append(err.FieldViolations, &pb.FieldViolation{
+			Field:       v.Field,
+			Description: v.Description,
+		})
+	}
+	if e.RetryAfter > 0 {
+		err.RetryAfter = ptypes.DurationProto(time.Duration(e.RetryAfter) * time.Second)
+	}
+	return err
+}
+
+func decodeShortenBatchGRPCRequest(_ context.Context, r interface{}) (interface{}, error) {
+	req := r.(*pb.Batch)
+	longs := make([]URL, len(req.Urls))
+	for i, u := range req.Urls {
+		long, err := urlFromPB(u)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "url %d: %v", i, err)
+		}
+		longs[i] = long
+	}
+	return _shortenBatchRequest{
+		Longs: longs,
+	}, nil
+}
+
+func encodeShortenBatchGRPCResponse(_ context.Context, r interface{}) (interface{}, error) {
+	resp := r.(_shortenBatchResponse)
+	results := &pb.BatchResults{Results: make([]*pb.BatchResult, len(resp.Body))}
+	for i, res := range resp.Body {
+		result := &pb.BatchResult{}
+		if res.URL != nil {
+			u, err := urlToPB(res.URL)
+			if err != nil {
+				return nil, err
+			}
+			result.Url = u
+		}
+		if res.Error != nil {
+			result.Error = errorToPB(res.Error)
+		}
+		results.Results[i] = result
+	}
+	return results, nil
+}
+func (s *shorterGRPCServer) ShortenBatch(ctx context.Context, r *pb.Batch) (*pb.BatchResults, error) {
+	_, resp, err := s.shortenBatch.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.BatchResults), nil
+}
+
+func linkToPB(l *Link) (*pb.Link, error) {
+	link := &pb.Link{
+		Code:      l.Code,
+		Short:     l.Short,
+		Addr:      l.Addr,
+		Redirect:  int32(l.Redirect),
+		MaxClicks: int64(l.MaxClicks),
+		Clicks:    int64(l.Clicks),
+	}
+	if l.ExpiresAt != nil {
+		expiresAt, err := ptypes.TimestampProto(*l.ExpiresAt)
+		if err != nil {
+			return nil, err
+		}
+		link.ExpiresAt = expiresAt
+	}
+	return link, nil
+}
+
+func linkFromPB(l *pb.Link) (*Link, error) {
+	link := &Link{
+		Code:      l.Code,
+		Short:     l.Short,
+		Addr:      l.Addr,
+		Redirect:  int(l.Redirect),
+		MaxClicks: int(l.MaxClicks),
+		Clicks:    int(l.Clicks),
+	}
+	if l.ExpiresAt != nil {
+		expiresAt, err := ptypes.Timestamp(l.ExpiresAt)
+		if err != nil {
+			return nil, err
+		}
+		link.ExpiresAt = &expiresAt
+	}
+	return link, nil
+}
+
+// encodeLinkGRPCResponse encodes the response of every operation answering
+// with a single Link.
+func encodeLinkGRPCResponse(_ context.Context, r interface{}) (interface{}, error) {
+	var body *Link
+	switch resp := r.(type) {
+	case _infoResponse:
+		body = resp.Body
+	case _updateResponse:
+		body = resp.Body
+	case _deleteResponse:
+		body = resp.Body
+	}
+	return linkToPB(body)
+}
+
+func decodeInfoGRPCRequest(_ context.Context, r interface{}) (interface{}, error) {
+	req := r.(*pb.Code)
+	return _infoRequest{
+		Code: req.Code,
+	}, nil
+}
+func (s *shorterGRPCServer) Info(ctx context.Context, r *pb.Code) (*pb.Link, error) {
+	_, resp, err := s.info.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.Link), nil
+}
+
+func decodeListGRPCRequest(_ context.Context, r interface{}) (interface{}, error) {
+	req := r.(*pb.ListRequest)
+	return _listRequest{
+		After: req.After,
+		Limit: int(req.Limit),
+	}, nil
+}
+
+func encodeListGRPCResponse(_ context.Context, r interface{}) (interface{}, error) {
+	resp := r.(_listResponse)
+	page := &pb.LinkPage{Next: resp.Body.Next, Links: make([]*pb.Link, len(resp.Body.Links))}
+	for i := range resp.Body.Links {
+		l, err := linkToPB(&resp.Body.Links[i])
+		if err != nil {
+			return nil, err
+		}
+		page.Links[i] = l
+	}
+	return page, nil
+}
+func (s *shorterGRPCServer) List(ctx context.Context, r *pb.ListRequest) (*pb.LinkPage, error) {
+	_, resp, err := s.list.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.LinkPage), nil
+}
+
+func decodeUpdateGRPCRequest(_ context.Context, r interface{}) (interface{}, error) {
+	req := r.(*pb.LinkUpdate)
+	var changes URL
+	if req.Changes != nil {
+		var err error
+		if changes, err = urlFromPB(req.Changes); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	return _updateRequest{
+		Code:    req.Code,
+		Changes: changes,
+	}, nil
+}
+func (s *shorterGRPCServer) Update(ctx context.Context, r *pb.LinkUpdate) (*pb.Link, error) {
+	_, resp, err := s.update.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.Link), nil
+}
+
+func decodeDeleteGRPCRequest(_ context.Context, r interface{}) (interface{}, error) {
+	req := r.(*pb.Code)
+	return _deleteRequest{
+		Code: req.Code,
+	}, nil
+}
+func (s *shorterGRPCServer) Delete(ctx context.Context, r *pb.Code) (*pb.Link, error) {
+	_, resp, err := s.delete.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.Link), nil
+}
+
+// maxStreamInFlight is how many URLs of a ShortenStream are shortened at
+// once. Once that many are in flight the server stops reading the stream, so
+// gRPC's flow control pushes back on a client sending faster than we can
+// shorten.
+const maxStreamInFlight = 32
+
+// idempotencyMetadata is the metadata key shorter.IdempotencyMetadata reads.
+const idempotencyMetadata = "idempotency-key"
+
+// ShortenStream shortens every URL sent on the stream, sending each result
+// back tagged with the id of its request as soon as it's ready, so results
+// can arrive out of order. A URL that can't be shortened gets an error result
+// and the stream carries on.
+func (s *shorterGRPCServer) ShortenStream(stream pb.Shorter_ShortenStreamServer) error {
+	ctx := stream.Context()
+	results := make(chan *pb.StreamResult)
+
+	var sendErr error
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		for res := range results {
+			if sendErr == nil {
+				sendErr = stream.Send(res)
+			}
+		}
+	}()
+
+	var (
+		recvErr error
+		wg      sync.WaitGroup
+		sem     = make(chan struct{}, maxStreamInFlight)
+	)
+	for recvErr == nil {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			recvErr = err
+			break
+		}
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			recvErr = ctx.Err()
+			continue
+		}
+		wg.Add(1)
+		go func(req *pb.StreamRequest) {
+			defer func() { <-sem; wg.Done() }()
+			results <- s.shortenStreamed(ctx, req)
+		}(req)
+	}
+
+	wg.Wait()
+	close(results)
+	<-sent
+	if recvErr != nil {
+		return recvErr
+	}
+	return sendErr
+}
+
+func (s *shorterGRPCServer) shortenStreamed(ctx context.Context, req *pb.StreamRequest) *pb.StreamResult {
+	res := &pb.StreamResult{Id: req.Id}
+	if req.Url == nil {
+		res.Error = statusToPB(status.Error(codes.InvalidArgument, "url is missing"))
+		return res
+	}
+
+	// like ShortenBatch, a key the stream was opened with covers every URL
+	// sent on it
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if keys := md.Get(idempotencyMetadata); len(keys) > 0 {
+			md = md.Copy()
+			md.Set(idempotencyMetadata, fmt.Sprintf("%s/%d", keys[0], req.Id))
+			ctx = metadata.NewIncomingContext(ctx, md)
+		}
+	}
+
+	_, resp, err := s.shorter.ServeGRPC(ctx, req.Url)
+	if err != nil {
+		res.Error = statusToPB(grpcError(err))
+		return res
+	}
+	res.Url = resp.(*pb.URL)
+	return res
+}
+
+// statusToPB describes the gRPC status of err, including its details, as a
+// pb.Error.
+func statusToPB(err error) *pb.Error {
+	st, _ := status.FromError(err)
+	e := &pb.Error{
+		Code:    int32(st.Code()),
+		Message: st.Message(),
+	}
+	for _, detail := range st.Details() {
+		switch detail := detail.(type) {
+		case *pb.BadRequest:
+			e.FieldViolations = append(e.FieldViolations, detail.FieldViolations...)
+		case *pb.RetryInfo:
+			e.RetryAfter = detail.RetryDelay
+		}
+	}
+	return e
+}
+
+// NewShorterGRPCClient returns endpoints calling the Shorter service on conn.
+// Errors are the gRPC statuses the server replied with.
+func NewShorterGRPCClient(conn *grpc.ClientConn, opts ...grpctransport.ClientOption) ShorterEndpoints {
+	return ShorterEndpoints{
+		ShortenEndpoint: grpctransport.NewClient(
+			conn, "pb.Shorter", "Shorten",
+			encodeShortenGRPCRequest,
+			decodeShortenGRPCResponse,
+			pb.URL{},
+			opts...,
+		).Endpoint(),
+		ExpandEndpoint: grpctransport.NewClient(
+			conn, "pb.Shorter", "Expand",
+			encodeExpandGRPCRequest,
+			decodeExpandGRPCResponse,
+			pb.URL{},
+			opts...,
+		).Endpoint(),
+		ShortenBatchEndpoint: grpctransport.NewClient(
+			conn, "pb.Shorter", "ShortenBatch",
+			encodeShortenBatchGRPCRequest,
+			decodeShortenBatchGRPCResponse,
+			pb.BatchResults{},
+			opts...,
+		).Endpoint(),
+		InfoEndpoint: grpctransport.NewClient(
+			conn, "pb.Shorter", "Info",
+			encodeInfoGRPCRequest,
+			decodeLinkGRPCResponse(func(l *Link) interface{} { return _infoResponse{Body: l} }),
+			pb.Link{},
+			opts...,
+		).Endpoint(),
+		ListEndpoint: grpctransport.NewClient(
+			conn, "pb.Shorter", "List",
+			encodeListGRPCRequest,
+			decodeListGRPCResponse,
+			pb.LinkPage{},
+			opts...,
+		).Endpoint(),
+		UpdateEndpoint: grpctransport.NewClient(
+			conn, "pb.Shorter", "Update",
+			encodeUpdateGRPCRequest,
+			decodeLinkGRPCResponse(func(l *Link) interface{} { return _updateResponse{Body: l} }),
+			pb.Link{},
+			opts...,
+		).Endpoint(),
+		DeleteEndpoint: grpctransport.NewClient(
+			conn, "pb.Shorter", "Delete",
+			encodeDeleteGRPCRequest,
+			decodeLinkGRPCResponse(func(l *Link) interface{} { return _deleteResponse{Body: l} }),
+			pb.Link{},
+			opts...,
+		).Endpoint(),
+	}
+}
+
+func encodeShortenGRPCRequest(_ context.Context, r interface{}) (interface{}, error) {
+	req := r.(_shortenRequest)
+	return urlToPB(&req.Long)
+}
+
+func decodeShortenGRPCResponse(_ context.Context, r interface{}) (interface{}, error) {
+	u, err := urlFromPB(r.(*pb.URL))
+	if err != nil {
+		return nil, err
+	}
+	return _shortenResponse{Body: &u}, nil
+}
+
+func encodeExpandGRPCRequest(_ context.Context, r interface{}) (interface{}, error) {
+	req := r.(_expandRequest)
+	return &pb.Code{Code: req.Code}, nil
+}
+
+func decodeExpandGRPCResponse(_ context.Context, r interface{}) (interface{}, error) {
+	u, err := urlFromPB(r.(*pb.URL))
+	if err != nil {
+		return nil, err
+	}
+	return _expandResponse{Body: &u}, nil
+}
+
+func encodeShortenBatchGRPCRequest(_ context.Context, r interface{}) (interface{}, error) {
+	req := r.(_shortenBatchRequest)
+	batch := &pb.Batch{Urls: make([]*pb.URL, len(req.Longs))}
+	for i := range req.Longs {
+		u, err := urlToPB(&req.Longs[i])
+		if err != nil {
+			return nil, err
+		}
+		batch.Urls[i] = u
+	}
+	return batch, nil
+}
+
+func decodeShortenBatchGRPCResponse(_ context.Context, r interface{}) (interface{}, error) {
+	results := r.(*pb.BatchResults)
+	resp := _shortenBatchResponse{Body: make([]BatchResult, len(results.Results))}
+	for i, res := range results.Results {
+		if res.Url != nil {
+			u, err := urlFromPB(res.Url)
+			if err != nil {
+				return nil, err
+			}
+			resp.Body[i].URL = &u
+		}
+		if res.Error != nil {
+			resp.Body[i].Error = errorFromPB(res.Error)
+		}
+	}
+	return resp, nil
+}
+
+func encodeInfoGRPCRequest(_ context.Context, r interface{}) (interface{}, error) {
+	req := r.(_infoRequest)
+	return &pb.Code{Code: req.Code}, nil
+}
+
+func encodeListGRPCRequest(_ context.Context, r interface{}) (interface{}, error) {
+	req := r.(_listRequest)
+	return &pb.ListRequest{After: req.After, Limit: int32(req.Limit)}, nil
+}
+
+func decodeListGRPCResponse(_ context.Context, r interface{}) (interface{}, error) {
+	page := r.(*pb.LinkPage)
+	resp := _listResponse{Body: &LinkPage{Next: page.Next, Links: make([]Link, len(page.Links))}}
+	for i, l := range page.Links {
+		link, err := linkFromPB(l)
+		if err != nil {
+			return nil, err
+		}
+		resp.Body.Links[i] = *link
+	}
+	return resp, nil
+}
+
+func encodeUpdateGRPCRequest(_ context.Context, r interface{}) (interface{}, error) {
+	req := r.(_updateRequest)
+	changes, err := urlToPB(&req.Changes)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.LinkUpdate{Code: req.Code, Changes: changes}, nil
+}
+
+func encodeDeleteGRPCRequest(_ context.Context, r interface{}) (interface{}, error) {
+	req := r.(_deleteRequest)
+	return &pb.Code{Code: req.Code}, nil
+}
+
+// decodeLinkGRPCResponse decodes the Link an operation answers with into the
+// response wrap makes of it.
+func decodeLinkGRPCResponse(wrap func(*Link) interface{}) grpctransport.DecodeResponseFunc {
+	return func(_ context.Context, r interface{}) (interface{}, error) {
+		l, err := linkFromPB(r.(*pb.Link))
+		if err != nil {
+			return nil, err
+		}
+		return wrap(l), nil
+	}
+}
+
+// errorFromPB is the reverse of errorToPB.
+func errorFromPB(e *pb.Error) *Error {
+	code := grpcToHTTP(codes.Code(e.Code))
+	err := &Error{
+		Status:  code,
+		Error:   http.StatusText(code),
+		Message: e.Message,
+		Code:    int(e.Code),
+	}
+	for _, v := range e.FieldViolations {
+		err.FieldViolations = append(err.FieldViolations, FieldViolation{
+			Field:       v.Field,
+			Description: v.Description,
+		})
+	}
+	if e.RetryAfter != nil {
+		if d, derr := ptypes.Duration(e.RetryAfter); derr == nil {
+			err.RetryAfter = int((d + time.Second - 1) / time.Second)
+		}
+	}
+	return err
+}